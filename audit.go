@@ -0,0 +1,267 @@
+package dhop
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+type FindingKind string
+
+const (
+	FindingRogueServer      FindingKind = "rogue-server"
+	FindingStarvation       FindingKind = "starvation"
+	FindingRelayMismatch    FindingKind = "relay-agent-mismatch"
+	FindingClientIDSpoofing FindingKind = "client-id-spoofing"
+)
+
+const (
+	DefaultStarvationWindow    = time.Second
+	DefaultStarvationThreshold = 50
+)
+
+// Finding is a suspicious event found in captured messages.
+type Finding struct {
+	Kind      FindingKind `json:"kind"`
+	Timestamp time.Time   `json:"timestamp"`
+	Message   string      `json:"message"`
+	XID       uint32      `json:"xid,omitempty"`
+	CHAddr    string      `json:"chaddr,omitempty"`
+	Server    string      `json:"server,omitempty"`
+	Count     int         `json:"count,omitempty"`
+}
+
+// AuditConfig configures an Auditor.
+// Servers are not checked against the allowlist if AllowedServers is empty.
+type AuditConfig struct {
+	AllowedServers      []net.IP
+	StarvationWindow    time.Duration
+	StarvationThreshold int
+}
+
+// Auditor finds unauthorized servers, starvation attacks, mismatched
+// Relay Agent Information and spoofed Client Identifiers in captured messages.
+type Auditor struct {
+	config   AuditConfig
+	messages []*CapturedMessage
+}
+
+func NewAuditor(config AuditConfig) *Auditor {
+	if config.StarvationWindow <= 0 {
+		config.StarvationWindow = DefaultStarvationWindow
+	}
+	if config.StarvationThreshold <= 0 {
+		config.StarvationThreshold = DefaultStarvationThreshold
+	}
+	return &Auditor{
+		config: config,
+	}
+}
+
+func (a *Auditor) Add(m *CapturedMessage) {
+	a.messages = append(a.messages, m)
+}
+
+// Findings returns the findings ordered by timestamp.
+func (a *Auditor) Findings() []Finding {
+	messages := make([]*CapturedMessage, len(a.messages))
+	copy(messages, a.messages)
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+	findings := make([]Finding, 0)
+	findings = append(findings, a.rogueServers(messages)...)
+	findings = append(findings, a.starvation(messages)...)
+	findings = append(findings, relayMismatches(messages)...)
+	findings = append(findings, clientIDSpoofing(messages)...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Timestamp.Before(findings[j].Timestamp)
+	})
+	return findings
+}
+
+func (a *Auditor) allowed(ip net.IP) bool {
+	for _, s := range a.config.AllowedServers {
+		if s.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Auditor) rogueServers(messages []*CapturedMessage) []Finding {
+	if len(a.config.AllowedServers) == 0 {
+		return nil
+	}
+	findings := make([]Finding, 0)
+	index := make(map[string]int)
+	for _, c := range messages {
+		typ := c.Message.Type()
+		if typ != MessageTypeOffer && typ != MessageTypeAck {
+			continue
+		}
+		server := serverOf(c)
+		if a.allowed(server) {
+			continue
+		}
+		if i, ok := index[server.String()]; ok {
+			findings[i].Count++
+			continue
+		}
+		index[server.String()] = len(findings)
+		findings = append(findings, Finding{
+			Kind:      FindingRogueServer,
+			Timestamp: c.Timestamp,
+			Message:   fmt.Sprintf("%s from unauthorized server %s", typ, server),
+			XID:       c.Message.XID,
+			CHAddr:    c.Message.CHAddr.String(),
+			Server:    server.String(),
+			Count:     1,
+		})
+	}
+	return findings
+}
+
+// starvation finds windows in which DISCOVERs come from too many distinct client hardware addresses.
+func (a *Auditor) starvation(messages []*CapturedMessage) []Finding {
+	discovers := make([]*CapturedMessage, 0)
+	for _, c := range messages {
+		if c.Message.Type() == MessageTypeDiscover {
+			discovers = append(discovers, c)
+		}
+	}
+	findings := make([]Finding, 0)
+	for start := 0; start < len(discovers); {
+		clients := make(map[string]struct{})
+		end := start
+		for ; end < len(discovers); end++ {
+			if discovers[end].Timestamp.Sub(discovers[start].Timestamp) > a.config.StarvationWindow {
+				break
+			}
+			clients[discovers[end].Message.CHAddr.String()] = struct{}{}
+		}
+		if len(clients) < a.config.StarvationThreshold {
+			start++
+			continue
+		}
+		findings = append(findings, Finding{
+			Kind:      FindingStarvation,
+			Timestamp: discovers[start].Timestamp,
+			Message: fmt.Sprintf(
+				"%d DHCPDISCOVERs from %d client hardware addresses within %s",
+				end-start,
+				len(clients),
+				discovers[end-1].Timestamp.Sub(discovers[start].Timestamp),
+			),
+			Count: len(clients),
+		})
+		start = end
+	}
+	return findings
+}
+
+// relayMismatches finds replies which do not echo the Relay Agent Information of the request (RFC 3046).
+// A reply is paired with the request of the same giaddr which it answers in the opposite direction,
+// so the copies of a capture on the relay between the client side and the server side are not mixed up.
+func relayMismatches(messages []*CapturedMessage) []Finding {
+	type relayKey struct {
+		transactionKey
+		giaddr string
+	}
+	findings := make([]Finding, 0)
+	requests := make(map[relayKey]*CapturedMessage)
+	for _, c := range messages {
+		m := c.Message
+		key := relayKey{
+			transactionKey: transactionKey{
+				xid:    m.XID,
+				chaddr: m.CHAddr.String(),
+			},
+		}
+		if !isUnspecified(m.GIAddr) {
+			key.giaddr = m.GIAddr.String()
+		}
+		if m.Op == OpRequest {
+			requests[key] = c
+			continue
+		}
+		req, ok := requests[key]
+		if !ok {
+			continue
+		}
+		// The reply to a relay is sent back to the source of the request.
+		if !isUnspecified(req.Src) && c.Dst != nil && !c.Dst.Equal(req.Src) {
+			continue
+		}
+		request := req.Message
+		sent, hasSent := request.Option(82)
+		echoed, hasEchoed := m.Option(82)
+		var message string
+		switch {
+		case hasSent && hasEchoed && !bytes.Equal(sent.Encode(), echoed.Encode()):
+			message = "Relay Agent Information in the reply differs from the request"
+		case hasSent && !hasEchoed && !isUnspecified(m.GIAddr):
+			message = "Relay Agent Information of the request is not echoed in the reply"
+		case !hasSent && hasEchoed:
+			message = "Relay Agent Information in the reply is not in the request"
+		default:
+			continue
+		}
+		findings = append(findings, Finding{
+			Kind:      FindingRelayMismatch,
+			Timestamp: c.Timestamp,
+			Message:   fmt.Sprintf("%s: %s", m.Type(), message),
+			XID:       m.XID,
+			CHAddr:    m.CHAddr.String(),
+			Server:    serverOf(c).String(),
+		})
+	}
+	return findings
+}
+
+// clientIDSpoofing finds Client Identifiers which are used by several client hardware addresses,
+// or whose hardware address does not match the client hardware address.
+func clientIDSpoofing(messages []*CapturedMessage) []Finding {
+	findings := make([]Finding, 0)
+	owners := make(map[string]string)
+	reported := make(map[string]bool)
+	for _, c := range messages {
+		m := c.Message
+		if m.Op != OpRequest {
+			continue
+		}
+		o, ok := m.Option(61)
+		if !ok {
+			continue
+		}
+		id := o.Encode()
+		chaddr := m.CHAddr.String()
+		var message string
+		if len(id) > 1 && id[0] == m.HType && m.HType != 0 && !bytes.Equal(id[1:], m.CHAddr) {
+			message = fmt.Sprintf("Client Identifier %x does not match chaddr %s", id, chaddr)
+		} else if owner, ok := owners[string(id)]; ok && owner != chaddr {
+			message = fmt.Sprintf("Client Identifier %x is used by %s and %s", id, owner, chaddr)
+		} else {
+			owners[string(id)] = chaddr
+			continue
+		}
+		if reported[string(id)+chaddr] {
+			continue
+		}
+		reported[string(id)+chaddr] = true
+		findings = append(findings, Finding{
+			Kind:      FindingClientIDSpoofing,
+			Timestamp: c.Timestamp,
+			Message:   message,
+			XID:       m.XID,
+			CHAddr:    chaddr,
+		})
+	}
+	return findings
+}
+
+func isUnspecified(ip net.IP) bool {
+	return ip == nil || ip.IsUnspecified()
+}
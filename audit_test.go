@@ -0,0 +1,99 @@
+package dhop
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAuditRogueServer(t *testing.T) {
+	a := NewAuditor(AuditConfig{
+		AllowedServers: []net.IP{net.IPv4(10, 0, 0, 1)},
+	})
+	a.Add(capturedMessage(0, OpReply, MessageTypeOffer, net.IPv4(10, 0, 0, 1)))
+	a.Add(capturedMessage(time.Second, OpReply, MessageTypeOffer, net.IPv4(10, 0, 0, 66)))
+	a.Add(capturedMessage(2*time.Second, OpReply, MessageTypeAck, net.IPv4(10, 0, 0, 66)))
+	findings := a.Findings()
+	if len(findings) != 1 {
+		t.Fatal(findings)
+	}
+	if findings[0].Kind != FindingRogueServer || findings[0].Server != "10.0.0.66" || findings[0].Count != 2 {
+		t.Error(findings[0])
+	}
+}
+
+func TestAuditStarvation(t *testing.T) {
+	a := NewAuditor(AuditConfig{
+		StarvationWindow:    time.Second,
+		StarvationThreshold: 3,
+	})
+	for i := 0; i < 5; i++ {
+		m := capturedMessage(time.Duration(i)*100*time.Millisecond, OpRequest, MessageTypeDiscover, nil)
+		m.Message.CHAddr = net.HardwareAddr{2, 0, 0, 0, 0, byte(i)}
+		a.Add(m)
+	}
+	a.Add(capturedMessage(10*time.Second, OpRequest, MessageTypeDiscover, nil))
+	findings := a.Findings()
+	if len(findings) != 1 || findings[0].Kind != FindingStarvation || findings[0].Count != 5 {
+		t.Error(findings)
+	}
+}
+
+func TestAuditRelayMismatch(t *testing.T) {
+	sent := String([]byte{1, 1, 'a'})
+	echoed := String([]byte{1, 1, 'b'})
+	a := NewAuditor(AuditConfig{})
+	a.Add(capturedMessage(0, OpRequest, MessageTypeDiscover, nil, Option{OptionData: &sent, Code: 82}))
+	a.Add(capturedMessage(time.Second, OpReply, MessageTypeOffer, net.IPv4(10, 0, 0, 1), Option{OptionData: &echoed, Code: 82}))
+	findings := a.Findings()
+	if len(findings) != 1 || findings[0].Kind != FindingRelayMismatch {
+		t.Error(findings)
+	}
+}
+
+func TestAuditClientIDSpoofing(t *testing.T) {
+	id := String([]byte{1, 0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	a := NewAuditor(AuditConfig{})
+	m := capturedMessage(0, OpRequest, MessageTypeDiscover, nil, Option{OptionData: &id, Code: 61})
+	m.Message.HType = 1
+	a.Add(m)
+	m = capturedMessage(time.Second, OpRequest, MessageTypeDiscover, nil, Option{OptionData: &id, Code: 61})
+	m.Message.HType = 1
+	m.Message.CHAddr = net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x66}
+	a.Add(m)
+	findings := a.Findings()
+	if len(findings) != 1 || findings[0].Kind != FindingClientIDSpoofing || findings[0].CHAddr != "00:11:22:33:44:66" {
+		t.Error(findings)
+	}
+}
+
+func TestAuditRelayCapture(t *testing.T) {
+	agent := String([]byte{1, 1, 'a'})
+	relay := net.IPv4(192, 0, 2, 1)
+	server := net.IPv4(10, 0, 0, 1)
+	a := NewAuditor(AuditConfig{})
+	// The client side of the relay.
+	a.Add(capturedMessage(0, OpRequest, MessageTypeDiscover, nil))
+	// The server side of the relay.
+	m := capturedMessage(time.Millisecond, OpRequest, MessageTypeDiscover, nil, Option{OptionData: &agent, Code: 82})
+	m.Message.GIAddr, m.Src, m.Dst = relay, relay, server
+	a.Add(m)
+	m = capturedMessage(time.Second, OpReply, MessageTypeOffer, server, Option{OptionData: &agent, Code: 82})
+	m.Message.GIAddr, m.Src, m.Dst = relay, server, relay
+	a.Add(m)
+	// The relay strips Relay Agent Information from the reply to the client.
+	m = capturedMessage(time.Second+time.Millisecond, OpReply, MessageTypeOffer, server)
+	m.Message.GIAddr, m.Src = relay, relay
+	a.Add(m)
+	if findings := a.Findings(); len(findings) != 0 {
+		t.Error(findings)
+	}
+
+	// The server does not echo the option.
+	m = capturedMessage(time.Second, OpReply, MessageTypeOffer, server)
+	m.Message.GIAddr, m.Src, m.Dst = relay, server, relay
+	a.messages[2] = m
+	if findings := a.Findings(); len(findings) != 1 || findings[0].Kind != FindingRelayMismatch {
+		t.Error(findings)
+	}
+}
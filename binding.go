@@ -0,0 +1,174 @@
+package dhop
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// InfiniteLeaseTime is the lease time which never expires (RFC 2131).
+const InfiniteLeaseTime = TimeDuration(0xffffffff * time.Second)
+
+type BindingState byte
+
+const (
+	BindingActive BindingState = iota
+	BindingReleased
+	BindingDeclined
+	BindingExpired
+)
+
+func (s BindingState) String() string {
+	switch s {
+	case BindingActive:
+		return "active"
+	case BindingReleased:
+		return "released"
+	case BindingDeclined:
+		return "declined"
+	case BindingExpired:
+		return "expired"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(s))
+}
+
+// Binding is an address leased to a client, like an entry of a DHCP snooping table.
+type Binding struct {
+	IP        net.IP
+	CHAddr    net.HardwareAddr
+	ClientID  []byte
+	LeaseTime time.Duration
+	Server    net.IP
+	GIAddr    net.IP
+	// RelayAgentInformation is the data of option 82 in the request or the reply.
+	RelayAgentInformation []byte
+	FirstSeen             time.Time
+	LastSeen              time.Time
+	// Expires is zero if the lease time is infinite or unknown.
+	Expires time.Time
+	State   BindingState
+}
+
+// BindingTable builds bindings from captured messages.
+type BindingTable struct {
+	bindings map[string]*Binding
+	requests map[transactionKey]*Message
+}
+
+func NewBindingTable() *BindingTable {
+	return &BindingTable{
+		bindings: make(map[string]*Binding),
+		requests: make(map[transactionKey]*Message),
+	}
+}
+
+func (t *BindingTable) Add(c *CapturedMessage) {
+	m := c.Message
+	key := transactionKey{
+		xid:    m.XID,
+		chaddr: m.CHAddr.String(),
+	}
+	switch m.Type() {
+	case MessageTypeDiscover, MessageTypeRequest, MessageTypeInform:
+		t.requests[key] = m
+	case MessageTypeAck:
+		if isUnspecified(m.YIAddr) {
+			return
+		}
+		t.bind(c, t.requests[key])
+	case MessageTypeRelease:
+		t.update(c, m.CIAddr, BindingReleased)
+	case MessageTypeDecline:
+		t.update(c, m.RequestedIPAddress(), BindingDeclined)
+	}
+}
+
+func (t *BindingTable) bind(c *CapturedMessage, request *Message) {
+	m := c.Message
+	b, ok := t.bindings[m.YIAddr.String()]
+	if !ok || !bytes.Equal(b.CHAddr, m.CHAddr) || b.State != BindingActive {
+		b = &Binding{
+			IP:        m.YIAddr,
+			FirstSeen: c.Timestamp,
+		}
+		t.bindings[m.YIAddr.String()] = b
+	}
+	b.CHAddr = m.CHAddr
+	b.LastSeen = c.Timestamp
+	b.Server = serverOf(c)
+	b.GIAddr = m.GIAddr
+	if o, ok := m.Option(61); ok {
+		b.ClientID = o.Encode()
+	} else if request != nil {
+		if o, ok := request.Option(61); ok {
+			b.ClientID = o.Encode()
+		}
+	}
+	if o, ok := m.Option(82); ok {
+		b.RelayAgentInformation = o.Encode()
+	} else if request != nil {
+		if o, ok := request.Option(82); ok {
+			b.RelayAgentInformation = o.Encode()
+		}
+	}
+	b.LeaseTime = 0
+	b.Expires = time.Time{}
+	if o, ok := m.Option(51); ok {
+		if d, ok := o.OptionData.(*TimeDuration); ok {
+			b.LeaseTime = time.Duration(*d)
+			if *d != InfiniteLeaseTime {
+				b.Expires = c.Timestamp.Add(b.LeaseTime)
+			}
+		}
+	}
+}
+
+// update changes the state of the binding of the address by DHCPRELEASE or DHCPDECLINE.
+// Messages from clients other than the holder of the binding are ignored.
+func (t *BindingTable) update(c *CapturedMessage, ip net.IP, state BindingState) {
+	if isUnspecified(ip) {
+		return
+	}
+	b, ok := t.bindings[ip.String()]
+	if ok && !b.heldBy(c.Message) {
+		return
+	}
+	if !ok {
+		b = &Binding{
+			IP:        ip,
+			CHAddr:    c.Message.CHAddr,
+			FirstSeen: c.Timestamp,
+		}
+		t.bindings[ip.String()] = b
+	}
+	b.LastSeen = c.Timestamp
+	b.State = state
+}
+
+// heldBy returns whether the binding belongs to the client of the message,
+// by Client Identifier if both have it, or by chaddr.
+func (b *Binding) heldBy(m *Message) bool {
+	if o, ok := m.Option(61); ok && b.ClientID != nil {
+		return bytes.Equal(b.ClientID, o.Encode())
+	}
+	return bytes.Equal(b.CHAddr, m.CHAddr)
+}
+
+// Bindings returns copies of the bindings ordered by address.
+// Active bindings whose lease ended before now are returned as expired.
+func (t *BindingTable) Bindings(now time.Time) []*Binding {
+	bindings := make([]*Binding, 0, len(t.bindings))
+	for _, b := range t.bindings {
+		c := *b
+		if c.State == BindingActive && !c.Expires.IsZero() && c.Expires.Before(now) {
+			c.State = BindingExpired
+		}
+		bindings = append(bindings, &c)
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bytes.Compare(bindings[i].IP.To16(), bindings[j].IP.To16()) < 0
+	})
+	return bindings
+}
@@ -0,0 +1,72 @@
+package dhop
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBindingTable(t *testing.T) {
+	server := net.IPv4(10, 0, 0, 1)
+	id := String([]byte{1, 0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	lease := TimeDuration(time.Hour)
+	table := NewBindingTable()
+	table.Add(capturedMessage(0, OpRequest, MessageTypeRequest, server, Option{OptionData: &id, Code: 61}))
+	table.Add(capturedMessage(time.Second, OpReply, MessageTypeAck, server, Option{OptionData: &lease, Code: 51}))
+	bindings := table.Bindings(transactionStart.Add(time.Minute))
+	if len(bindings) != 1 {
+		t.Fatal(bindings)
+	}
+	b := bindings[0]
+	if b.IP.String() != "10.0.0.100" || b.CHAddr.String() != "00:11:22:33:44:55" || !b.Server.Equal(server) {
+		t.Error(b)
+	}
+	if bytes.Compare(b.ClientID, id.Encode()) != 0 || b.LeaseTime != time.Hour || b.State != BindingActive {
+		t.Error(b)
+	}
+	if !b.Expires.Equal(transactionStart.Add(time.Hour + time.Second)) {
+		t.Error(b.Expires)
+	}
+	if table.Bindings(transactionStart.Add(2 * time.Hour))[0].State != BindingExpired {
+		t.Error("binding must be expired")
+	}
+	if table.Bindings(transactionStart)[0].State != BindingActive {
+		t.Error("Bindings changes the state")
+	}
+}
+
+func TestBindingTableRelease(t *testing.T) {
+	table := NewBindingTable()
+	table.Add(capturedMessage(time.Second, OpReply, MessageTypeAck, net.IPv4(10, 0, 0, 1)))
+	release := capturedMessage(time.Minute, OpRequest, MessageTypeRelease, nil)
+	release.Message.CIAddr = net.IPv4(10, 0, 0, 100)
+	table.Add(release)
+	decline := capturedMessage(time.Hour, OpRequest, MessageTypeDecline, nil)
+	requested := IPv4(net.IPv4(10, 0, 0, 101).To4())
+	decline.Message.Options = append(decline.Message.Options, Option{OptionData: &requested, Code: 50})
+	table.Add(decline)
+	bindings := table.Bindings(transactionStart)
+	if len(bindings) != 2 || bindings[0].State != BindingReleased || bindings[1].State != BindingDeclined {
+		t.Error(bindings)
+	}
+}
+
+func TestBindingTableReleaseByOtherClient(t *testing.T) {
+	table := NewBindingTable()
+	holder := String([]byte{1, 0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	table.Add(capturedMessage(time.Second, OpReply, MessageTypeAck, net.IPv4(10, 0, 0, 1), Option{OptionData: &holder, Code: 61}))
+	release := capturedMessage(time.Minute, OpRequest, MessageTypeRelease, nil)
+	release.Message.CIAddr = net.IPv4(10, 0, 0, 100)
+	release.Message.CHAddr = net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x66}
+	table.Add(release)
+	decline := capturedMessage(time.Minute, OpRequest, MessageTypeDecline, nil)
+	id := String([]byte{1, 0, 0x11, 0x22, 0x33, 0x44, 0x66})
+	requested := IPv4(net.IPv4(10, 0, 0, 100).To4())
+	decline.Message.Options = append(decline.Message.Options, Option{OptionData: &id, Code: 61}, Option{OptionData: &requested, Code: 50})
+	table.Add(decline)
+	bindings := table.Bindings(transactionStart)
+	if len(bindings) != 1 || bindings[0].State != BindingActive || !bindings[0].LastSeen.Equal(transactionStart.Add(time.Second)) {
+		t.Error(bindings)
+	}
+}
@@ -0,0 +1,132 @@
+package dhop
+
+import (
+	"fmt"
+)
+
+const (
+	snameSize = 64
+	fileSize  = 128
+)
+
+// mandatoryCodes are the options which are never moved nor omitted by EncodeWithBudget.
+var mandatoryCodes = map[Code]bool{
+	51: true,
+	53: true,
+	54: true,
+	61: true,
+	82: true,
+}
+
+// EncodeReport describes where EncodeWithBudget put the options.
+type EncodeReport struct {
+	// Size is the size of the encoded message including the IP and UDP headers.
+	Size int
+	// Overload is the value of the Option Overload option, or 0 if the option is not used.
+	Overload byte
+	// File and SName are the options moved into the file and sname fields.
+	File  []Code
+	SName []Code
+	// Omitted are the options which do not fit anywhere.
+	Omitted []Code
+}
+
+// EncodeWithBudget encodes the message into the IP datagram of the size, such as MinMessageSize
+// or the MaxMessageSize of the request.
+// Mandatory options (53, 54, 51, 61 and 82) come first, then the options in the order of the priority
+// such as the Parameter Request List of the request, and then the other options.
+// Options which do not fit in the options field are moved into the file and sname fields with
+// the Option Overload option if the fields are empty, or omitted.
+// It returns an error if the mandatory options do not fit.
+func (m *Message) EncodeWithBudget(size int, priority []Code) ([]byte, *EncodeReport, error) {
+	var mandatory, rest []Option
+	for _, o := range m.Options {
+		switch {
+		case o.Code == 0 || o.Code == 52 || o.Code == 255:
+		case mandatoryCodes[o.Code]:
+			mandatory = append(mandatory, o)
+		default:
+			rest = append(rest, o)
+		}
+	}
+	ordered := make([]Option, 0, len(rest))
+	for _, code := range priority {
+		for i := 0; i < len(rest); i++ {
+			if rest[i].Code == code {
+				ordered = append(ordered, rest[i])
+				rest = append(rest[:i], rest[i+1:]...)
+				i--
+			}
+		}
+	}
+	ordered = append(ordered, rest...)
+
+	// The options field has the magic cookie and the End option.
+	capacity := size - IPUDPHeaderSize - MessageHeaderSize - len(MagicCookie) - 1
+	main := EncodeOptions(mandatory)
+	if len(main) > capacity {
+		return nil, nil, &InvalidSizeError{
+			Message: fmt.Sprintf("mandatory options need %d bytes, but only %d bytes are available", len(main), capacity),
+		}
+	}
+	if all := EncodeOptions(ordered); len(main)+len(all) <= capacity {
+		b := m.encode(append(main, all...), nil, nil)
+		return b, &EncodeReport{Size: len(b) + IPUDPHeaderSize}, nil
+	}
+
+	report := &EncodeReport{}
+	var file, sname []byte
+	fileCap, snameCap := -1, -1
+	// The Option Overload option takes 3 bytes of the options field, and each overloaded field ends with End.
+	if len(main)+3 <= capacity && (m.File == "" || m.SName == "") {
+		capacity -= 3
+		if m.File == "" {
+			fileCap = fileSize - 1
+		}
+		if m.SName == "" {
+			snameCap = snameSize - 1
+		}
+	}
+	for _, o := range ordered {
+		b := EncodeOptions([]Option{o})
+		switch {
+		case len(main)+len(b) <= capacity:
+			main = append(main, b...)
+		case len(file)+len(b) <= fileCap:
+			file = append(file, b...)
+			report.File = append(report.File, o.Code)
+		case len(sname)+len(b) <= snameCap:
+			sname = append(sname, b...)
+			report.SName = append(report.SName, o.Code)
+		default:
+			report.Omitted = append(report.Omitted, o.Code)
+		}
+	}
+	if file != nil {
+		report.Overload |= 1
+	}
+	if sname != nil {
+		report.Overload |= 2
+	}
+	if report.Overload != 0 {
+		main = append(main, 52, 1, report.Overload)
+	}
+	b := m.encode(main, file, sname)
+	report.Size = len(b) + IPUDPHeaderSize
+	return b, report, nil
+}
+
+// encode encodes the message with the encoded options and the options overloaded into the file and sname fields.
+func (m *Message) encode(options, file, sname []byte) []byte {
+	b := make([]byte, MessageHeaderSize, MessageHeaderSize+len(MagicCookie)+len(options)+1)
+	m.encodeHeader(b)
+	if file != nil {
+		copy(b[108:236], append(file, 255))
+	}
+	if sname != nil {
+		copy(b[44:108], append(sname, 255))
+	}
+	b = append(b, MagicCookie...)
+	b = append(b, options...)
+	return append(b, 255)
+}
@@ -0,0 +1,73 @@
+package dhop
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeWithBudget(t *testing.T) {
+	long := String(strings.Repeat("a", 200))
+	middle := String(strings.Repeat("b", 100))
+	short := String(strings.Repeat("c", 40))
+	small := String(strings.Repeat("d", 50))
+	// The request accepts large replies, so NewReply keeps all the options.
+	size := Size(1500)
+	req := replyRequest(MessageTypeDiscover, Option{OptionData: &size, Code: 57})
+	m, err := NewReply(req, MessageTypeOffer, append(replyServerOptions(),
+		Option{OptionData: &middle, Code: 43},
+		Option{OptionData: &middle, Code: 66},
+		Option{OptionData: &middle, Code: 67},
+		Option{OptionData: &short, Code: 77},
+		Option{OptionData: &long, Code: 78},
+		Option{OptionData: &small, Code: 79},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, report, err := m.EncodeWithBudget(MinMessageSize, []Code{67, 66})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Size != len(b)+IPUDPHeaderSize || report.Size > MinMessageSize {
+		t.Error("size:", report.Size, len(b))
+	}
+	if report.Overload != 3 || len(report.File) != 1 || report.File[0] != 43 ||
+		len(report.SName) != 1 || report.SName[0] != 79 || len(report.Omitted) != 1 || report.Omitted[0] != 78 {
+		t.Errorf("%+v", report)
+	}
+	d := Message{}
+	if err := d.Decode(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []Code{1, 3, 15, 43, 51, 53, 54, 66, 67, 77, 79} {
+		if _, ok := d.Option(code); !ok {
+			t.Error("option is lost:", code)
+		}
+	}
+	if d.File != "" || d.SName != "" {
+		t.Error(d.File, d.SName)
+	}
+
+	b, report, err = m.EncodeWithBudget(1500, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Overload != 0 || len(report.Omitted) != 0 || len(b) != len(m.Encode()) {
+		t.Errorf("%+v", report)
+	}
+
+	m.File = "pxelinux.0"
+	m.SName = "boot"
+	_, report, err = m.EncodeWithBudget(MinMessageSize, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Overload != 0 || len(report.Omitted) != 3 {
+		t.Errorf("fields in use: %+v", report)
+	}
+
+	if _, _, err := m.EncodeWithBudget(IPUDPHeaderSize+MessageHeaderSize+8, nil); err == nil {
+		t.Error("mandatory options over the budget")
+	}
+}
@@ -0,0 +1,718 @@
+package dhop
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Class is a client class which matches the messages satisfying the expression.
+type Class struct {
+	Name string
+	Test *Expression
+}
+
+// Classify returns the names of the classes which the message matches.
+func Classify(m *Message, classes []Class) ([]string, error) {
+	names := make([]string, 0)
+	for _, c := range classes {
+		ok, err := c.Test.Match(m)
+		if err != nil {
+			return nil, fmt.Errorf("class %s: %s", c.Name, err)
+		}
+		if ok {
+			names = append(names, c.Name)
+		}
+	}
+	return names, nil
+}
+
+// Expression is a classification expression in the syntax of ISC dhcpd or Kea, such as
+//
+//	substring(option vendor-class-identifier, 0, 9) = "PXEClient"
+//	option[82].option[1].hex == 0x65746830 and not exists option host-name
+//
+// Data are options by name or code with the accessors .hex, .text and .exists,
+// sub-options of encapsulated options (option agent.circuit-id or option[82].option[1]),
+// the header fields hardware and pkt4.mac, pkt4.htype, pkt4.hlen, pkt4.ciaddr, pkt4.giaddr,
+// pkt4.yiaddr, pkt4.siaddr, pkt4.msgtype and pkt4.transid, strings, numbers, IPv4 addresses and hex literals (0x0102 or 01:02),
+// and the functions substring, suffix, concat, hexstring, lcase and ucase.
+// Conditions are compared with = (==) and !=, and combined with and (&&), or (||) and not (!).
+// A comparison with a missing option is false.
+type Expression struct {
+	source string
+	eval   evaluator
+}
+
+type valueKind byte
+
+const (
+	valueData valueKind = iota
+	valueNumber
+	valueBoolean
+)
+
+type value struct {
+	kind valueKind
+	data []byte
+	num  uint64
+	bool bool
+	// null is true for the data of missing options.
+	null bool
+}
+
+func (v value) bytes() []byte {
+	if v.kind == valueNumber {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v.num)
+		return bytes.TrimLeft(b, "\x00")
+	}
+	return v.data
+}
+
+func (v value) number() (uint64, bool) {
+	if v.kind == valueNumber {
+		return v.num, true
+	}
+	if len(v.data) > 8 {
+		return 0, false
+	}
+	b := make([]byte, 8)
+	copy(b[8-len(v.data):], v.data)
+	return binary.BigEndian.Uint64(b), true
+}
+
+type evaluator func(m *Message) (value, error)
+
+// ParseExpression parses the classification expression.
+func ParseExpression(s string) (*Expression, error) {
+	tokens, err := tokenizeExpression(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &expressionParser{tokens: tokens}
+	eval, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Expression{source: s, eval: eval}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Match evaluates the expression as a condition for the message.
+func (e *Expression) Match(m *Message) (bool, error) {
+	v, err := e.eval(m)
+	if err != nil {
+		return false, err
+	}
+	if v.kind != valueBoolean {
+		return false, fmt.Errorf("expression is not a condition: %s", e.source)
+	}
+	return v.bool, nil
+}
+
+// Eval evaluates the expression as data for the message, such as the value of an option.
+// Conditions are "true" or "false", and numbers are decimal.
+func (e *Expression) Eval(m *Message) ([]byte, error) {
+	v, err := e.eval(m)
+	if err != nil {
+		return nil, err
+	}
+	switch v.kind {
+	case valueBoolean:
+		return []byte(strconv.FormatBool(v.bool)), nil
+	case valueNumber:
+		return []byte(strconv.FormatUint(v.num, 10)), nil
+	}
+	return v.data, nil
+}
+
+type tokenKind byte
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenData
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	data []byte
+	num  uint64
+}
+
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '-' || c == '.' || c == ':'
+}
+
+func tokenizeExpression(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			buf := []byte{}
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				buf = append(buf, s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s[i : j+1], data: buf})
+			i = j + 1
+		case strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!=") ||
+			strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||"):
+			tokens = append(tokens, token{kind: tokenSymbol, text: s[i : i+2]})
+			i += 2
+		case strings.IndexByte("=!()[],.", c) >= 0:
+			tokens = append(tokens, token{kind: tokenSymbol, text: s[i : i+1]})
+			i++
+		case isWordByte(c):
+			j := i
+			for j < len(s) && isWordByte(s[j]) {
+				j++
+			}
+			// A dot after a word is an accessor such as option[82].hex.
+			for j > i+1 && s[j-1] == '.' {
+				j--
+			}
+			t, err := classifyWord(s[i:j])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+func classifyWord(w string) (token, error) {
+	t := token{kind: tokenWord, text: w}
+	switch {
+	case strings.HasPrefix(w, "0x") || strings.HasPrefix(w, "0X"):
+		s := w[2:]
+		if len(s)%2 != 0 {
+			s = "0" + s
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return t, fmt.Errorf("invalid hex %q", w)
+		}
+		t.kind, t.data = tokenData, b
+	case net.ParseIP(w).To4() != nil && strings.Count(w, ".") == 3:
+		t.kind, t.data = tokenData, []byte(net.ParseIP(w).To4())
+	case strings.Contains(w, ":"):
+		b, err := parseColonHex(w)
+		if err != nil {
+			return t, fmt.Errorf("invalid hex %q", w)
+		}
+		t.kind, t.data = tokenData, b
+	case w[0] >= '0' && w[0] <= '9':
+		n, err := strconv.ParseUint(w, 10, 64)
+		if err != nil {
+			return t, fmt.Errorf("invalid number %q", w)
+		}
+		t.kind, t.num = tokenNumber, n
+	}
+	return t, nil
+}
+
+type expressionParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *expressionParser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// accept consumes the next token if it is one of the words or symbols.
+func (p *expressionParser) accept(texts ...string) bool {
+	t := p.peek()
+	if t == nil || (t.kind != tokenWord && t.kind != tokenSymbol) {
+		return false
+	}
+	for _, s := range texts {
+		if strings.EqualFold(t.text, s) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *expressionParser) expect(s string) error {
+	if !p.accept(s) {
+		if t := p.peek(); t != nil {
+			return fmt.Errorf("expected %q, but got %q", s, t.text)
+		}
+		return fmt.Errorf("expected %q, but got the end", s)
+	}
+	return nil
+}
+
+func boolean(e evaluator, m *Message) (bool, error) {
+	v, err := e(m)
+	if err != nil {
+		return false, err
+	}
+	if v.kind != valueBoolean {
+		return false, fmt.Errorf("data is used as a condition")
+	}
+	return v.bool, nil
+}
+
+func (p *expressionParser) or() (evaluator, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		l := left
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = func(m *Message) (value, error) {
+			a, err := boolean(l, m)
+			if err != nil || a {
+				return value{kind: valueBoolean, bool: a}, err
+			}
+			b, err := boolean(r, m)
+			return value{kind: valueBoolean, bool: b}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) and() (evaluator, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		l := left
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = func(m *Message) (value, error) {
+			a, err := boolean(l, m)
+			if err != nil || !a {
+				return value{kind: valueBoolean, bool: a}, err
+			}
+			b, err := boolean(r, m)
+			return value{kind: valueBoolean, bool: b}, err
+		}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) not() (evaluator, error) {
+	if p.accept("not", "!") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(m *Message) (value, error) {
+			b, err := boolean(e, m)
+			return value{kind: valueBoolean, bool: !b}, err
+		}, nil
+	}
+	return p.comparison()
+}
+
+func (p *expressionParser) comparison() (evaluator, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	negate := false
+	switch {
+	case p.accept("=", "=="):
+	case p.accept("!="):
+		negate = true
+	default:
+		return left, nil
+	}
+	right, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	return func(m *Message) (value, error) {
+		a, err := left(m)
+		if err != nil {
+			return value{}, err
+		}
+		b, err := right(m)
+		if err != nil {
+			return value{}, err
+		}
+		if a.kind == valueBoolean || b.kind == valueBoolean {
+			return value{}, fmt.Errorf("condition is compared")
+		}
+		if a.null || b.null {
+			return value{kind: valueBoolean}, nil
+		}
+		var equal bool
+		if a.kind == valueNumber || b.kind == valueNumber {
+			x, ok1 := a.number()
+			y, ok2 := b.number()
+			equal = ok1 && ok2 && x == y
+		} else {
+			equal = bytes.Equal(a.data, b.data)
+		}
+		return value{kind: valueBoolean, bool: equal != negate}, nil
+	}, nil
+}
+
+func (p *expressionParser) primary() (evaluator, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	switch t.kind {
+	case tokenString, tokenData:
+		p.pos++
+		v := value{data: t.data}
+		return func(*Message) (value, error) { return v, nil }, nil
+	case tokenNumber:
+		p.pos++
+		v := value{kind: valueNumber, num: t.num}
+		return func(*Message) (value, error) { return v, nil }, nil
+	}
+	if p.accept("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	p.pos++
+	word := strings.ToLower(t.text)
+	switch word {
+	case "true", "false":
+		v := value{kind: valueBoolean, bool: word == "true"}
+		return func(*Message) (value, error) { return v, nil }, nil
+	case "exists":
+		p.accept("option")
+		ref, err := p.optionRef()
+		if err != nil {
+			return nil, err
+		}
+		return ref.exists, nil
+	case "option":
+		ref, err := p.optionRef()
+		if err != nil {
+			return nil, err
+		}
+		if p.accept(".") {
+			t := p.peek()
+			if t == nil || t.kind != tokenWord {
+				return nil, fmt.Errorf("expected an accessor of the option")
+			}
+			p.pos++
+			switch strings.ToLower(t.text) {
+			case "hex", "text":
+			case "exists":
+				return ref.exists, nil
+			default:
+				return nil, fmt.Errorf("unknown accessor %q", t.text)
+			}
+		}
+		return ref.data, nil
+	}
+	if f, ok := expressionFields[word]; ok {
+		return func(m *Message) (value, error) { return f(m), nil }, nil
+	}
+	if f, ok := expressionFunctions[word]; ok {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var args []evaluator
+		for !p.accept(")") {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			a, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+		}
+		if len(args) < f.min || (f.max >= 0 && len(args) > f.max) {
+			return nil, fmt.Errorf("%s: invalid number of arguments %d", word, len(args))
+		}
+		return func(m *Message) (value, error) {
+			values := make([]value, len(args))
+			for i, a := range args {
+				v, err := a(m)
+				if err != nil {
+					return value{}, err
+				}
+				if v.kind == valueBoolean {
+					return value{}, fmt.Errorf("%s: condition is given as data", word)
+				}
+				if v.null {
+					return value{null: true}, nil
+				}
+				values[i] = v
+			}
+			return f.call(values)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown identifier %q", t.text)
+}
+
+// optionRef is an option or a sub-option of an encapsulated option.
+type optionRef struct {
+	code Code
+	sub  Code
+	// encapsulated is true if sub is the code of a sub-option.
+	encapsulated bool
+}
+
+// optionRef parses a name of ISC dhcpd such as agent.circuit-id, or the codes in brackets such as [82].option[1].
+func (p *expressionParser) optionRef() (*optionRef, error) {
+	if p.accept("[") {
+		code, err := p.code()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		ref := &optionRef{code: code}
+		if p.pos+1 < len(p.tokens) && p.tokens[p.pos].text == "." && strings.EqualFold(p.tokens[p.pos+1].text, "option") {
+			p.pos += 2
+			if err := p.expect("["); err != nil {
+				return nil, err
+			}
+			sub, err := p.code()
+			if err != nil {
+				return nil, err
+			}
+			ref.sub, ref.encapsulated = sub, true
+			return ref, p.expect("]")
+		}
+		return ref, nil
+	}
+	t := p.peek()
+	if t == nil || (t.kind != tokenWord && t.kind != tokenNumber) {
+		return nil, fmt.Errorf("expected an option name")
+	}
+	p.pos++
+	space, name := qualifiedDhcpdName(t.text)
+	if space == "dhcp" {
+		code, err := ParseCode(name)
+		if err != nil {
+			return nil, err
+		}
+		return &optionRef{code: code}, nil
+	}
+	parser := newDhcpdParser()
+	code, ok := parser.encapsulated[space]
+	o, ok2 := parser.types[t.text]
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("unknown option %q", t.text)
+	}
+	return &optionRef{code: code, sub: o.code, encapsulated: true}, nil
+}
+
+func (p *expressionParser) code() (Code, error) {
+	t := p.peek()
+	if t == nil {
+		return 0, fmt.Errorf("expected an option code")
+	}
+	p.pos++
+	if t.kind == tokenNumber {
+		if t.num > 255 {
+			return 0, fmt.Errorf("invalid option code %d", t.num)
+		}
+		return Code(t.num), nil
+	}
+	return ParseCode(t.text)
+}
+
+// lookup returns the data of the option concatenated by RFC 3396.
+func (r *optionRef) lookup(m *Message) ([]byte, bool) {
+	var data []byte
+	found := false
+	for _, o := range m.Options {
+		if o.Code == r.code {
+			data = append(data, o.Encode()...)
+			found = true
+		}
+	}
+	if !found || !r.encapsulated {
+		return data, found
+	}
+	subs, err := DecodeSubOptions(data)
+	if err != nil {
+		return nil, false
+	}
+	for _, s := range subs {
+		if Code(s.Code) == r.sub {
+			return s.Data, true
+		}
+	}
+	return nil, false
+}
+
+func (r *optionRef) data(m *Message) (value, error) {
+	b, ok := r.lookup(m)
+	return value{data: b, null: !ok}, nil
+}
+
+func (r *optionRef) exists(m *Message) (value, error) {
+	_, ok := r.lookup(m)
+	return value{kind: valueBoolean, bool: ok}, nil
+}
+
+var expressionFields = map[string]func(m *Message) value{
+	"hardware": func(m *Message) value {
+		return value{data: append([]byte{m.HType}, m.CHAddr...)}
+	},
+	"pkt4.mac": func(m *Message) value {
+		return value{data: []byte(m.CHAddr)}
+	},
+	"pkt4.htype": func(m *Message) value {
+		return value{kind: valueNumber, num: uint64(m.HType)}
+	},
+	"pkt4.hlen": func(m *Message) value {
+		return value{kind: valueNumber, num: uint64(m.HLen)}
+	},
+	"pkt4.ciaddr": func(m *Message) value {
+		return value{data: addressBytes(m.CIAddr)}
+	},
+	"pkt4.giaddr": func(m *Message) value {
+		return value{data: addressBytes(m.GIAddr)}
+	},
+	"pkt4.yiaddr": func(m *Message) value {
+		return value{data: addressBytes(m.YIAddr)}
+	},
+	"pkt4.siaddr": func(m *Message) value {
+		return value{data: addressBytes(m.SIAddr)}
+	},
+	"pkt4.msgtype": func(m *Message) value {
+		return value{kind: valueNumber, num: uint64(m.Type())}
+	},
+	"pkt4.transid": func(m *Message) value {
+		return value{kind: valueNumber, num: uint64(m.XID)}
+	},
+}
+
+func addressBytes(ip []byte) []byte {
+	if len(ip) == 16 {
+		return ip[12:]
+	}
+	if len(ip) == 0 {
+		return make([]byte, 4)
+	}
+	return ip
+}
+
+type expressionFunction struct {
+	min, max int
+	call     func(args []value) (value, error)
+}
+
+func intArg(v value, name string) (int, error) {
+	n, ok := v.number()
+	if !ok || v.kind != valueNumber {
+		if v.kind == valueData && string(v.data) == "all" {
+			return -1, nil
+		}
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	return int(n), nil
+}
+
+var expressionFunctions = map[string]expressionFunction{
+	"substring": {3, 3, func(args []value) (value, error) {
+		b := args[0].bytes()
+		start, err := intArg(args[1], "start of substring")
+		if err != nil {
+			return value{}, err
+		}
+		length, err := intArg(args[2], "length of substring")
+		if err != nil {
+			return value{}, err
+		}
+		if start < 0 || start > len(b) {
+			return value{data: []byte{}}, nil
+		}
+		b = b[start:]
+		if length >= 0 && length < len(b) {
+			b = b[:length]
+		}
+		return value{data: b}, nil
+	}},
+	"suffix": {2, 2, func(args []value) (value, error) {
+		b := args[0].bytes()
+		length, err := intArg(args[1], "length of suffix")
+		if err != nil {
+			return value{}, err
+		}
+		if length >= 0 && length < len(b) {
+			b = b[len(b)-length:]
+		}
+		return value{data: b}, nil
+	}},
+	"concat": {2, -1, func(args []value) (value, error) {
+		var b []byte
+		for _, a := range args {
+			b = append(b, a.bytes()...)
+		}
+		return value{data: b}, nil
+	}},
+	"hexstring": {1, 2, func(args []value) (value, error) {
+		sep := ""
+		if len(args) > 1 {
+			sep = string(args[1].bytes())
+		}
+		b := args[0].bytes()
+		s := make([]string, len(b))
+		for i, c := range b {
+			s[i] = fmt.Sprintf("%02x", c)
+		}
+		return value{data: []byte(strings.Join(s, sep))}, nil
+	}},
+	"lcase": {1, 1, func(args []value) (value, error) {
+		return value{data: bytes.ToLower(args[0].bytes())}, nil
+	}},
+	"ucase": {1, 1, func(args []value) (value, error) {
+		return value{data: bytes.ToUpper(args[0].bytes())}, nil
+	}},
+}
@@ -0,0 +1,123 @@
+package dhop
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func classMessage() *Message {
+	typ := Byte(MessageTypeDiscover)
+	vendor := String("PXEClient:Arch:00007:UNDI:003016")
+	agent := String(EncodeSubOptions([]SubOption{
+		{Code: 1, Data: []byte("eth0")},
+		{Code: 2, Data: []byte{0x00, 0x11}},
+	}))
+	return &Message{
+		Op:     OpRequest,
+		HType:  1,
+		HLen:   6,
+		XID:    0x1234,
+		GIAddr: net.IPv4(192, 0, 2, 1),
+		CHAddr: net.HardwareAddr{0x52, 0x54, 0x00, 0x12, 0x34, 0x56},
+		Options: []Option{
+			{OptionData: &typ, Code: 53},
+			{OptionData: &vendor, Code: 60},
+			{OptionData: &agent, Code: 82},
+		},
+	}
+}
+
+func TestExpression(t *testing.T) {
+	m := classMessage()
+	matches := []string{
+		`substring(option vendor-class-identifier, 0, 9) = "PXEClient"`,
+		`option[60].text == 'PXEClient:Arch:00007:UNDI:003016'`,
+		`option[82].option[1].hex == 0x65746830`,
+		`option agent.circuit-id = "eth0" and option agent.remote-id = 00:11`,
+		`option[82].option[2].hex == 17`,
+		`substring(hardware, 1, 3) = 52:54:00`,
+		`pkt4.mac == 0x525400123456 && pkt4.htype == 1`,
+		`pkt4.msgtype == 1 and pkt4.transid == 0x1234`,
+		`pkt4.giaddr == 0xc0000201 and pkt4.giaddr = 192.0.2.1`,
+		`not exists option host-name`,
+		`option[12].exists or exists option agent.circuit-id`,
+		`ucase(substring(option[60].hex, 0, 3)) = "PXE" and lcase("AbC") = "abc"`,
+		`suffix(option vendor-class-identifier, 3) = "016"`,
+		`concat(substring(option[60].text, 0, 3), "-", hexstring(pkt4.mac, ':')) = "PXE-52:54:00:12:34:56"`,
+		`substring(option[60].hex, 10, 'all') = "Arch:00007:UNDI:003016"`,
+		`!(option[82].option[3].exists) && (option[61].hex != 'x' || true)`,
+	}
+	for _, s := range matches {
+		e, err := ParseExpression(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if ok, err := e.Match(m); err != nil || !ok {
+			t.Errorf("%s: %v %v", s, ok, err)
+		}
+	}
+
+	mismatches := []string{
+		`option host-name = ""`,
+		`option host-name != "a"`,
+		`substring(option vendor-class-identifier, 0, 9) = "HTTPClient"`,
+		`option[82].option[1].hex == 'eth1'`,
+	}
+	for _, s := range mismatches {
+		e, err := ParseExpression(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if ok, err := e.Match(m); err != nil || ok {
+			t.Errorf("%s: %v %v", s, ok, err)
+		}
+	}
+
+	invalid := []string{
+		`option`,
+		`substring(option[60].hex, 0)`,
+		`option[60].hex ==`,
+		`option unknown.name = "a"`,
+		`"a" = "b")`,
+		`unknown(1)`,
+		`'unterminated`,
+	}
+	for _, s := range invalid {
+		if _, err := ParseExpression(s); err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+
+	e, err := ParseExpression(`option[60].hex`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Match(m); err == nil {
+		t.Error("data is matched as a condition")
+	}
+	if b, err := e.Eval(m); err != nil || !strings.HasPrefix(string(b), "PXEClient") {
+		t.Error(string(b), err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	classes := make([]Class, 0)
+	for _, c := range [][2]string{
+		{"pxe", `substring(option vendor-class-identifier, 0, 9) = "PXEClient"`},
+		{"http", `substring(option vendor-class-identifier, 0, 10) = "HTTPClient"`},
+		{"eth0", `option agent.circuit-id = "eth0"`},
+	} {
+		e, err := ParseExpression(c[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		classes = append(classes, Class{Name: c[0], Test: e})
+	}
+	names, err := Classify(classMessage(), classes)
+	if err != nil || strings.Join(names, ",") != "pxe,eth0" {
+		t.Error(names, err)
+	}
+}
@@ -0,0 +1,379 @@
+// Package client is a DHCPv4 client state machine (RFC 2131 section 4.4)
+// with pluggable Transport and Clock, so it can be tested deterministically.
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+var (
+	// ErrNoResponse is returned when no server replies after all retransmissions.
+	ErrNoResponse = errors.New("no response from DHCP servers")
+	// ErrNak is returned when servers reject the requests with DHCPNAK.
+	ErrNak = errors.New("DHCPNAK is received")
+	// ErrLeaseExpired is returned when the lease expires without being extended.
+	ErrLeaseExpired = errors.New("lease is expired")
+)
+
+type State byte
+
+const (
+	StateInit State = iota
+	StateSelecting
+	StateRequesting
+	StateBound
+	StateRenewing
+	StateRebinding
+	StateInitReboot
+	StateRebooting
+)
+
+func (s State) String() string {
+	switch s {
+	case StateInit:
+		return "INIT"
+	case StateSelecting:
+		return "SELECTING"
+	case StateRequesting:
+		return "REQUESTING"
+	case StateBound:
+		return "BOUND"
+	case StateRenewing:
+		return "RENEWING"
+	case StateRebinding:
+		return "REBINDING"
+	case StateInitReboot:
+		return "INIT-REBOOT"
+	case StateRebooting:
+		return "REBOOTING"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(s))
+}
+
+const (
+	// DefaultInitialTimeout is the first retransmission timeout (RFC 2131 section 4.1).
+	DefaultInitialTimeout = 4 * time.Second
+	// DefaultMaxTimeout is the limit of doubled retransmission timeouts.
+	DefaultMaxTimeout = 64 * time.Second
+	// DefaultMaxAttempts is the number of transmissions of a message.
+	DefaultMaxAttempts = 5
+	// minRetransmission is the lower bound of retransmissions in RENEWING and REBINDING (RFC 2131 section 4.4.5).
+	minRetransmission = 60 * time.Second
+)
+
+// Client acquires and maintains a lease.
+// Methods of a Client must not be called concurrently.
+type Client struct {
+	HardwareAddr net.HardwareAddr
+	// ClientID is sent as the Client Identifier option if not empty.
+	ClientID []byte
+	// Options are added to the requests, such as Parameter Request List and Host Name.
+	Options []dhop.Option
+
+	Transport Transport
+	// Clock is SystemClock if nil.
+	Clock Clock
+
+	// InitialTimeout, MaxTimeout and MaxAttempts control retransmissions in INIT, REQUESTING and INIT-REBOOT.
+	// The defaults are used if zero.
+	InitialTimeout time.Duration
+	MaxTimeout     time.Duration
+	MaxAttempts    int
+	// Jitter returns the randomization of each retransmission timeout,
+	// which RFC 2131 suggests is uniform in [-1s, +1s]. No randomization if nil.
+	Jitter func() time.Duration
+
+	// Lease is the current lease. Set the lease of the last boot to start with INIT-REBOOT.
+	Lease *Lease
+
+	state State
+	xid   uint32
+	start time.Time
+}
+
+// State returns the current state.
+func (c *Client) State() State {
+	return c.state
+}
+
+func (c *Client) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return SystemClock
+}
+
+// Acquire obtains a lease and moves to BOUND.
+// It starts with INIT-REBOOT if Lease is set, and falls back to INIT if the lease is rejected or not answered.
+func (c *Client) Acquire() (*Lease, error) {
+	c.start = c.clock().Now()
+	if c.Lease != nil {
+		c.state = StateInitReboot
+		l, err := c.reboot()
+		if err == nil {
+			return l, nil
+		}
+	}
+	c.Lease = nil
+	naks := 0
+	for {
+		c.state = StateInit
+		offer, err := c.discover()
+		if err != nil {
+			return nil, err
+		}
+		l, err := c.request(offer)
+		if err != ErrNak {
+			return l, err
+		}
+		if naks++; naks >= c.maxAttempts() {
+			return nil, err
+		}
+	}
+}
+
+// Renew waits until T1 of the lease with Clock and extends the lease.
+// It unicasts DHCPREQUEST to the server in RENEWING until T2, then broadcasts in REBINDING until the lease expires.
+// The client moves to INIT and Lease is cleared when it returns ErrNak or ErrLeaseExpired.
+func (c *Client) Renew() (*Lease, error) {
+	if c.Lease == nil || c.state != StateBound {
+		return nil, errors.New("no bound lease")
+	}
+	l := c.Lease
+	clock := c.clock()
+	if d := l.Renew().Sub(clock.Now()); d > 0 {
+		clock.Sleep(d)
+	}
+	c.state = StateRenewing
+	c.start = clock.Now()
+	c.newTransaction()
+	for _, phase := range []struct {
+		state    State
+		server   net.IP
+		deadline time.Time
+	}{
+		{StateRenewing, l.ServerID, l.Rebind()},
+		{StateRebinding, nil, l.Expire()},
+	} {
+		c.state = phase.state
+		for {
+			now := clock.Now()
+			if !now.Before(phase.deadline) {
+				break
+			}
+			timeout := phase.deadline.Sub(now) / 2
+			if timeout < minRetransmission {
+				timeout = phase.deadline.Sub(now)
+				if timeout > minRetransmission {
+					timeout = minRetransmission
+				}
+			}
+			m := c.newRequest(dhop.MessageTypeRequest)
+			m.CIAddr = l.IP
+			reply, err := c.exchange(m, phase.server, now.Add(timeout))
+			if err == ErrTimeout {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return c.bind(reply, now)
+		}
+	}
+	c.state = StateInit
+	c.Lease = nil
+	return nil, ErrLeaseExpired
+}
+
+// Release gives up the lease with DHCPRELEASE and moves to INIT.
+func (c *Client) Release() error {
+	if c.Lease == nil {
+		return errors.New("no lease")
+	}
+	c.newTransaction()
+	m := c.newRequest(dhop.MessageTypeRelease)
+	m.CIAddr = c.Lease.IP
+	m.Options = append(m.Options, ipv4Option(54, c.Lease.ServerID))
+	server := c.Lease.ServerID
+	c.Lease = nil
+	c.state = StateInit
+	return c.Transport.Send(m, server)
+}
+
+// Decline tells the server that the address of the lease is already in use with DHCPDECLINE, and moves to INIT.
+func (c *Client) Decline() error {
+	if c.Lease == nil {
+		return errors.New("no lease")
+	}
+	c.newTransaction()
+	m := c.newRequest(dhop.MessageTypeDecline)
+	m.Options = append(m.Options, ipv4Option(50, c.Lease.IP), ipv4Option(54, c.Lease.ServerID))
+	c.Lease = nil
+	c.state = StateInit
+	return c.Transport.Send(m, nil)
+}
+
+func (c *Client) maxAttempts() int {
+	if c.MaxAttempts > 0 {
+		return c.MaxAttempts
+	}
+	return DefaultMaxAttempts
+}
+
+func (c *Client) newTransaction() {
+	c.xid = rand.Uint32()
+}
+
+func (c *Client) newRequest(t dhop.MessageType) *dhop.Message {
+	typ := dhop.Byte(t)
+	m := &dhop.Message{
+		Op:      dhop.OpRequest,
+		HType:   1,
+		HLen:    byte(len(c.HardwareAddr)),
+		XID:     c.xid,
+		CIAddr:  net.IPv4zero,
+		YIAddr:  net.IPv4zero,
+		SIAddr:  net.IPv4zero,
+		GIAddr:  net.IPv4zero,
+		CHAddr:  c.HardwareAddr,
+		Options: []dhop.Option{{OptionData: &typ, Code: 53}},
+	}
+	if t == dhop.MessageTypeDiscover || t == dhop.MessageTypeRequest {
+		c.setSecs(m)
+	}
+	if len(c.ClientID) > 0 {
+		id := dhop.String(c.ClientID)
+		m.Options = append(m.Options, dhop.Option{OptionData: &id, Code: 61})
+	}
+	m.Options = append(m.Options, c.Options...)
+	return m
+}
+
+// setSecs sets the seconds elapsed since the client began acquisition or renewal.
+func (c *Client) setSecs(m *dhop.Message) {
+	secs := c.clock().Now().Sub(c.start) / time.Second
+	if secs > 0xffff {
+		secs = 0xffff
+	}
+	m.Secs = uint16(secs)
+}
+
+func ipv4Option(code dhop.Code, ip net.IP) dhop.Option {
+	v := dhop.IPv4(ip.To4())
+	return dhop.Option{OptionData: &v, Code: code}
+}
+
+// exchange sends the message and returns the first reply to it until the deadline.
+func (c *Client) exchange(m *dhop.Message, server net.IP, deadline time.Time) (*dhop.Message, error) {
+	if err := c.Transport.Send(m, server); err != nil {
+		return nil, err
+	}
+	for {
+		reply, err := c.Transport.Receive(deadline)
+		if err != nil {
+			return nil, err
+		}
+		if reply.Op != dhop.OpReply || reply.XID != c.xid || reply.CHAddr.String() != c.HardwareAddr.String() {
+			continue
+		}
+		switch reply.Type() {
+		case dhop.MessageTypeOffer:
+			if m.Type() == dhop.MessageTypeDiscover && !isUnspecified(reply.YIAddr) && reply.ServerIdentifier() != nil {
+				return reply, nil
+			}
+		case dhop.MessageTypeAck, dhop.MessageTypeNak:
+			if m.Type() == dhop.MessageTypeRequest {
+				return reply, nil
+			}
+		}
+	}
+}
+
+// retransmit sends the message with exponential backoff until a reply arrives.
+func (c *Client) retransmit(m *dhop.Message, server net.IP) (*dhop.Message, error) {
+	clock := c.clock()
+	timeout := c.InitialTimeout
+	if timeout <= 0 {
+		timeout = DefaultInitialTimeout
+	}
+	max := c.MaxTimeout
+	if max <= 0 {
+		max = DefaultMaxTimeout
+	}
+	for i := 0; i < c.maxAttempts(); i++ {
+		d := timeout
+		if c.Jitter != nil {
+			d += c.Jitter()
+		}
+		c.setSecs(m)
+		reply, err := c.exchange(m, server, clock.Now().Add(d))
+		if err != ErrTimeout {
+			return reply, err
+		}
+		if timeout *= 2; timeout > max {
+			timeout = max
+		}
+	}
+	return nil, ErrNoResponse
+}
+
+func (c *Client) discover() (*dhop.Message, error) {
+	c.newTransaction()
+	c.state = StateSelecting
+	return c.retransmit(c.newRequest(dhop.MessageTypeDiscover), nil)
+}
+
+func (c *Client) request(offer *dhop.Message) (*Lease, error) {
+	c.state = StateRequesting
+	m := c.newRequest(dhop.MessageTypeRequest)
+	m.Options = append(m.Options, ipv4Option(50, offer.YIAddr), ipv4Option(54, offer.ServerIdentifier()))
+	sent := c.clock().Now()
+	reply, err := c.retransmit(m, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.bind(reply, sent)
+}
+
+func (c *Client) reboot() (*Lease, error) {
+	c.newTransaction()
+	c.state = StateRebooting
+	m := c.newRequest(dhop.MessageTypeRequest)
+	m.Options = append(m.Options, ipv4Option(50, c.Lease.IP))
+	sent := c.clock().Now()
+	reply, err := c.retransmit(m, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.bind(reply, sent)
+}
+
+// bind moves to BOUND with DHCPACK, or to INIT with DHCPNAK.
+// The lease starts at the time when the request is sent.
+func (c *Client) bind(reply *dhop.Message, sent time.Time) (*Lease, error) {
+	if reply.Type() == dhop.MessageTypeNak {
+		c.state = StateInit
+		c.Lease = nil
+		return nil, ErrNak
+	}
+	l, err := NewLease(reply, sent)
+	if err != nil {
+		return nil, err
+	}
+	if l.ServerID == nil && c.Lease != nil {
+		l.ServerID = c.Lease.ServerID
+	}
+	c.Lease = l
+	c.state = StateBound
+	return l, nil
+}
+
+func isUnspecified(ip net.IP) bool {
+	return ip == nil || ip.IsUnspecified()
+}
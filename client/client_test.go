@@ -0,0 +1,232 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bgpat/dhop"
+	"github.com/bgpat/dhop/server"
+)
+
+var testMAC = net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+type sent struct {
+	time   time.Time
+	server net.IP
+	m      *dhop.Message
+}
+
+// fakeTransport replies with the handler without delay, and advances the clock to the deadline without replies.
+type fakeTransport struct {
+	clock   *fakeClock
+	handler server.Handler
+	sent    []sent
+	queue   []*dhop.Message
+}
+
+func (t *fakeTransport) Send(m *dhop.Message, server net.IP) error {
+	t.sent = append(t.sent, sent{t.clock.now, server, m})
+	if t.handler == nil {
+		return nil
+	}
+	if reply := t.handler.ServeDHCP(m); reply != nil {
+		t.queue = append(t.queue, reply)
+	}
+	return nil
+}
+
+func (t *fakeTransport) Receive(deadline time.Time) (*dhop.Message, error) {
+	if len(t.queue) == 0 {
+		t.clock.now = deadline
+		return nil, ErrTimeout
+	}
+	m := t.queue[0]
+	t.queue = t.queue[1:]
+	return m, nil
+}
+
+func newPoolHandler(t *testing.T, clock *fakeClock) *server.PoolHandler {
+	p, err := server.NewPool(net.IPv4(10, 0, 0, 100), net.IPv4(10, 0, 0, 199))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Now = clock.Now
+	return &server.PoolHandler{
+		ServerID:  net.IPv4(10, 0, 0, 1),
+		Pool:      p,
+		LeaseTime: time.Hour,
+	}
+}
+
+func TestClientRetransmission(t *testing.T) {
+	start := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	transport := &fakeTransport{clock: clock}
+	c := &Client{HardwareAddr: testMAC, Transport: transport, Clock: clock}
+	if _, err := c.Acquire(); err != ErrNoResponse {
+		t.Fatal(err)
+	}
+	var offsets []time.Duration
+	for _, s := range transport.sent {
+		offsets = append(offsets, s.time.Sub(start))
+	}
+	expected := []time.Duration{0, 4 * time.Second, 12 * time.Second, 28 * time.Second, 60 * time.Second}
+	if len(offsets) != len(expected) {
+		t.Fatal(offsets)
+	}
+	for i := range expected {
+		if offsets[i] != expected[i] {
+			t.Error(offsets)
+			break
+		}
+	}
+	if transport.sent[4].m.Secs != 60 {
+		t.Error("secs:", transport.sent[4].m.Secs)
+	}
+}
+
+func TestClientAcquireAndRenew(t *testing.T) {
+	start := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	h := newPoolHandler(t, clock)
+	transport := &fakeTransport{clock: clock, handler: h}
+	c := &Client{HardwareAddr: testMAC, Transport: transport, Clock: clock}
+	l, err := c.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.State() != StateBound || !l.IP.Equal(net.IPv4(10, 0, 0, 100)) || !l.ServerID.Equal(h.ServerID) {
+		t.Error(c.State(), l.IP, l.ServerID)
+	}
+	if l.LeaseTime != time.Hour || l.T1 != 30*time.Minute || l.T2 != 52*time.Minute+30*time.Second {
+		t.Error(l.LeaseTime, l.T1, l.T2)
+	}
+
+	l, err = c.Renew()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Start.Equal(start.Add(30*time.Minute)) || c.State() != StateBound {
+		t.Error(l.Start, c.State())
+	}
+	last := transport.sent[len(transport.sent)-1]
+	if !last.server.Equal(h.ServerID) || !last.m.CIAddr.Equal(l.IP) {
+		t.Error("renewing request is not unicast:", last.server, last.m.CIAddr)
+	}
+
+	// The server goes away: RENEWING until T2, REBINDING until the lease expires.
+	transport.handler = nil
+	transport.sent = nil
+	if _, err := c.Renew(); err != ErrLeaseExpired {
+		t.Fatal(err)
+	}
+	if c.State() != StateInit || c.Lease != nil {
+		t.Error(c.State(), c.Lease)
+	}
+	var renewing, rebinding int
+	for _, s := range transport.sent {
+		if s.server != nil {
+			renewing++
+		} else {
+			rebinding++
+		}
+	}
+	// Half of the remaining time, but at least 60 seconds:
+	// RENEWING at 30m, 41.25m, 46.875m, 49.6875m, 51.09375m and 52.09375m,
+	// REBINDING at 52.5m, 56.25m, 58.125m and 59.125m.
+	if renewing != 6 || rebinding != 4 {
+		t.Error(renewing, rebinding)
+	}
+	if !clock.now.Equal(start.Add(90 * time.Minute)) {
+		t.Error(clock.now)
+	}
+}
+
+func TestClientInitReboot(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)}
+	h := newPoolHandler(t, clock)
+	transport := &fakeTransport{clock: clock, handler: h}
+	c := &Client{
+		HardwareAddr: testMAC,
+		Transport:    transport,
+		Clock:        clock,
+		Lease:        &Lease{IP: net.IPv4(192, 168, 0, 10)},
+	}
+	l, err := c.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DHCPNAK to the address of another network, then DHCPDISCOVER.
+	if len(transport.sent) != 3 || transport.sent[1].m.Type() != dhop.MessageTypeDiscover {
+		t.Error(len(transport.sent))
+	}
+	c.Lease = l
+	c.state = StateInit
+	transport.sent = nil
+	if _, err := c.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.sent) != 1 || transport.sent[0].m.RequestedIPAddress() == nil || transport.sent[0].m.ServerIdentifier() != nil {
+		t.Error("init-reboot:", transport.sent)
+	}
+}
+
+func TestClientLoopback(t *testing.T) {
+	p, err := server.NewPool(net.IPv4(10, 0, 0, 100), net.IPv4(10, 0, 0, 199))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+	go server.Serve(serverConn, &server.PoolHandler{
+		ServerID:  net.IPv4(127, 0, 0, 1),
+		Pool:      p,
+		LeaseTime: time.Hour,
+	})
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &Client{
+		HardwareAddr: testMAC,
+		Transport: &PacketConnTransport{
+			Conn:      conn,
+			Broadcast: serverConn.LocalAddr(),
+			Port:      serverConn.LocalAddr().(*net.UDPAddr).Port,
+		},
+		InitialTimeout: time.Second,
+	}
+	l, err := c.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.IP.Equal(net.IPv4(10, 0, 0, 100)) {
+		t.Error(l.IP)
+	}
+	if err := c.Release(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && len(p.Leases()) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if leases := p.Leases(); len(leases) != 0 {
+		t.Error("not released:", leases)
+	}
+}
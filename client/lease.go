@@ -0,0 +1,75 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+// Lease is an address bound by DHCPACK.
+// Options are the typed options of DHCPACK.
+type Lease struct {
+	IP       net.IP
+	ServerID net.IP
+	Options  []dhop.Option
+	// Start is the time when the request of the lease was sent (RFC 2131 section 4.4.1).
+	Start     time.Time
+	LeaseTime time.Duration
+	// T1 and T2 are the Renewal and Rebinding Time Values,
+	// or 0.5 and 0.875 times LeaseTime if DHCPACK does not have them (RFC 2131 section 4.4.5).
+	T1 time.Duration
+	T2 time.Duration
+}
+
+// NewLease returns the lease of DHCPACK to the request sent at the time.
+func NewLease(ack *dhop.Message, start time.Time) (*Lease, error) {
+	if isUnspecified(ack.YIAddr) {
+		return nil, errors.New("DHCPACK has no address")
+	}
+	l := &Lease{
+		IP:       ack.YIAddr,
+		ServerID: ack.ServerIdentifier(),
+		Options:  ack.Options,
+		Start:    start,
+	}
+	var ok bool
+	if l.LeaseTime, ok = duration(ack, 51); !ok {
+		return nil, errors.New("DHCPACK has no IP Address Lease Time")
+	}
+	if l.T1, ok = duration(ack, 58); !ok || l.T1 >= l.LeaseTime {
+		l.T1 = l.LeaseTime / 2
+	}
+	if l.T2, ok = duration(ack, 59); !ok || l.T2 >= l.LeaseTime || l.T2 < l.T1 {
+		l.T2 = l.LeaseTime * 7 / 8
+	}
+	return l, nil
+}
+
+func duration(m *dhop.Message, code dhop.Code) (time.Duration, bool) {
+	o, ok := m.Option(code)
+	if !ok {
+		return 0, false
+	}
+	d, ok := o.OptionData.(*dhop.TimeDuration)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(*d), true
+}
+
+// Renew returns the time to move to RENEWING.
+func (l *Lease) Renew() time.Time {
+	return l.Start.Add(l.T1)
+}
+
+// Rebind returns the time to move to REBINDING.
+func (l *Lease) Rebind() time.Time {
+	return l.Start.Add(l.T2)
+}
+
+// Expire returns the time when the lease expires.
+func (l *Lease) Expire() time.Time {
+	return l.Start.Add(l.LeaseTime)
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+// ErrTimeout is returned by Transport.Receive when no message arrives before the deadline.
+var ErrTimeout = errors.New("timeout")
+
+// Transport sends requests to servers and receives their replies.
+type Transport interface {
+	// Send sends the message to the server, or broadcasts it if server is nil.
+	Send(m *dhop.Message, server net.IP) error
+	// Receive returns the next message, or ErrTimeout at the deadline of Clock.
+	Receive(deadline time.Time) (*dhop.Message, error)
+}
+
+// Clock is the source of time of Client.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// SystemClock is the Clock of the system time.
+var SystemClock Clock = systemClock{}
+
+// PacketConnTransport is a Transport over a PacketConn, such as a UDP socket.
+// Messages which cannot be decoded are skipped.
+type PacketConnTransport struct {
+	Conn net.PacketConn
+	// Broadcast is the destination of broadcast messages, such as 255.255.255.255:67,
+	// or the address of a server on loopback in tests.
+	Broadcast net.Addr
+	// Port is the server port of unicast messages. 67 is used if zero.
+	Port int
+}
+
+func (t *PacketConnTransport) Send(m *dhop.Message, server net.IP) error {
+	addr := t.Broadcast
+	if server != nil {
+		port := t.Port
+		if port == 0 {
+			port = 67
+		}
+		a, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(server.String(), strconv.Itoa(port)))
+		if err != nil {
+			return err
+		}
+		addr = a
+	}
+	_, err := t.Conn.WriteTo(m.Encode(), addr)
+	return err
+}
+
+func (t *PacketConnTransport) Receive(deadline time.Time) (*dhop.Message, error) {
+	if err := t.Conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 65536)
+	for {
+		n, _, err := t.Conn.ReadFrom(b)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				return nil, ErrTimeout
+			}
+			return nil, err
+		}
+		m := &dhop.Message{}
+		if err := m.Decode(b[:n]); err != nil {
+			continue
+		}
+		return m, nil
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	analyzeCmd = &cobra.Command{
+		Use:   "analyze <file>",
+		Short: "Report DHCP transactions in a pcap or pcapng file",
+		Long: `analyze groups DHCPv4 messages in a capture file by xid and client hardware address,
+and reports the state, latency, retransmissions and competing offers of each transaction.
+Use "-t json" to write the report in JSON.
+With --audit, analyze reports unauthorized servers, starvation attacks, mismatched
+Relay Agent Information and spoofed Client Identifiers in JSON instead.`,
+		RunE: executeAnalyze,
+	}
+	audit       bool
+	auditConfig dhop.AuditConfig
+)
+
+func init() {
+	analyzeCmd.Flags().BoolVar(&audit, "audit", false, "report security findings")
+	analyzeCmd.Flags().IPSliceVar(&auditConfig.AllowedServers, "allow-server", nil, "authorized server identifiers")
+	analyzeCmd.Flags().DurationVar(&auditConfig.StarvationWindow, "starvation-window", dhop.DefaultStarvationWindow, "window to count DHCPDISCOVERs")
+	analyzeCmd.Flags().IntVar(&auditConfig.StarvationThreshold, "starvation-threshold", dhop.DefaultStarvationThreshold, "distinct clients in the window to report starvation")
+	rootCmd.AddCommand(analyzeCmd)
+}
+
+type transactionReport struct {
+	XID             string   `json:"xid"`
+	CHAddr          string   `json:"chaddr"`
+	State           string   `json:"state"`
+	Failure         string   `json:"failure,omitempty"`
+	Start           string   `json:"start"`
+	End             string   `json:"end"`
+	Messages        []string `json:"messages"`
+	Latency         float64  `json:"latency"`
+	OfferLatency    float64  `json:"offer_latency"`
+	AckLatency      float64  `json:"ack_latency"`
+	Retransmissions int      `json:"retransmissions"`
+	Offers          int      `json:"offers"`
+	Servers         []string `json:"servers"`
+	Server          string   `json:"server,omitempty"`
+	YIAddr          string   `json:"yiaddr,omitempty"`
+}
+
+func newTransactionReport(t *dhop.Transaction) transactionReport {
+	r := transactionReport{
+		XID:             fmt.Sprintf("0x%08x", t.XID),
+		CHAddr:          t.CHAddr.String(),
+		State:           t.State.String(),
+		Failure:         t.Failure,
+		Start:           t.Start.Format(timestampFormat),
+		End:             t.End.Format(timestampFormat),
+		Messages:        make([]string, len(t.Messages)),
+		Latency:         t.Latency.Seconds(),
+		OfferLatency:    t.OfferLatency.Seconds(),
+		AckLatency:      t.AckLatency.Seconds(),
+		Retransmissions: t.Retransmissions,
+		Offers:          len(t.Offers),
+		Servers:         make([]string, 0, len(t.Offers)),
+	}
+	for i, m := range t.Messages {
+		r.Messages[i] = m.Message.Type().String()
+	}
+	for _, s := range t.Servers() {
+		r.Servers = append(r.Servers, ipString(s))
+	}
+	if t.Server != nil {
+		r.Server = t.Server.String()
+	}
+	if t.YIAddr != nil {
+		r.YIAddr = t.YIAddr.String()
+	}
+	return r
+}
+
+func printTransaction(w io.Writer, t *dhop.Transaction) {
+	fmt.Fprintf(w,
+		"%s xid=0x%08x chaddr=%s state=%s\n",
+		t.Start.Format(timestampFormat),
+		t.XID,
+		t.CHAddr,
+		t.State,
+	)
+	names := make([]string, len(t.Messages))
+	for i, m := range t.Messages {
+		names[i] = fmt.Sprintf("%s(+%s)", m.Message.Type(), m.Timestamp.Sub(t.Start))
+	}
+	fmt.Fprintf(w, "  messages: %s\n", strings.Join(names, " "))
+	if t.YIAddr != nil {
+		fmt.Fprintf(w, "  yiaddr: %s\n", t.YIAddr)
+	}
+	if t.Server != nil {
+		fmt.Fprintf(w, "  server: %s\n", t.Server)
+	}
+	fmt.Fprintf(w,
+		"  latency: %s (offer %s, ack %s)\n",
+		t.Latency,
+		t.OfferLatency,
+		t.AckLatency,
+	)
+	fmt.Fprintf(w, "  retransmissions: %d\n", t.Retransmissions)
+	if servers := t.Servers(); len(servers) > 1 {
+		s := make([]string, len(servers))
+		for i, ip := range servers {
+			s[i] = ipString(ip)
+		}
+		fmt.Fprintf(w, "  competing offers: %s\n", strings.Join(s, ", "))
+	}
+	if t.Failure != "" {
+		fmt.Fprintf(w, "  failure: %s\n", t.Failure)
+	}
+}
+
+func executeAnalyze(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("requires exactly one capture file")
+	}
+	if audit {
+		return executeAudit(args[0])
+	}
+	analyzer := dhop.NewAnalyzer()
+	err := readMessages(args[0], func(m *dhop.CapturedMessage) error {
+		analyzer.Add(m)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	transactions := analyzer.Transactions()
+	if outputFormat == FORMAT_TYPE_JSON {
+		reports := make([]transactionReport, len(transactions))
+		for i, t := range transactions {
+			reports[i] = newTransactionReport(t)
+		}
+		return writeJSON(reports)
+	}
+	w, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, t := range transactions {
+		printTransaction(w, t)
+	}
+	return nil
+}
+
+func executeAudit(path string) error {
+	auditor := dhop.NewAuditor(auditConfig)
+	err := readMessages(path, func(m *dhop.CapturedMessage) error {
+		auditor.Add(m)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writeJSON(auditor.Findings())
+}
+
+// writeJSON writes the value in indented JSON to the output.
+func writeJSON(v interface{}) error {
+	w, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bindingsCmd = &cobra.Command{
+		Use:   "bindings <file>",
+		Short: "Build an IP-to-MAC binding table from a pcap or pcapng file",
+		Long: `bindings builds a DHCP snooping style binding table from DHCPv4 messages in a capture file.
+Leases are expired at the time of the last message in the capture.
+The table is written in CSV by default, or in JSON with "-t json".`,
+		RunE: executeBindings,
+	}
+	bindingsActive bool
+)
+
+func init() {
+	bindingsCmd.Flags().BoolVar(&bindingsActive, "active", false, "only output active bindings")
+	rootCmd.AddCommand(bindingsCmd)
+}
+
+type bindingReport struct {
+	IP                    string `json:"ip"`
+	CHAddr                string `json:"chaddr"`
+	ClientID              string `json:"client_id,omitempty"`
+	State                 string `json:"state"`
+	LeaseTime             int64  `json:"lease_time"`
+	Server                string `json:"server,omitempty"`
+	GIAddr                string `json:"giaddr,omitempty"`
+	RelayAgentInformation string `json:"relay_agent_information,omitempty"`
+	FirstSeen             string `json:"first_seen"`
+	LastSeen              string `json:"last_seen"`
+	Expires               string `json:"expires,omitempty"`
+}
+
+func newBindingReport(b *dhop.Binding) bindingReport {
+	r := bindingReport{
+		IP:                    b.IP.String(),
+		CHAddr:                b.CHAddr.String(),
+		ClientID:              hex.EncodeToString(b.ClientID),
+		State:                 b.State.String(),
+		LeaseTime:             int64(b.LeaseTime / time.Second),
+		RelayAgentInformation: hex.EncodeToString(b.RelayAgentInformation),
+		FirstSeen:             b.FirstSeen.Format(timestampFormat),
+		LastSeen:              b.LastSeen.Format(timestampFormat),
+	}
+	if b.Server != nil {
+		r.Server = b.Server.String()
+	}
+	if b.GIAddr != nil && !b.GIAddr.IsUnspecified() {
+		r.GIAddr = b.GIAddr.String()
+	}
+	if !b.Expires.IsZero() {
+		r.Expires = b.Expires.Format(timestampFormat)
+	}
+	return r
+}
+
+func (r *bindingReport) Record() []string {
+	return []string{
+		r.IP,
+		r.CHAddr,
+		r.ClientID,
+		r.State,
+		strconv.FormatInt(r.LeaseTime, 10),
+		r.Server,
+		r.GIAddr,
+		r.RelayAgentInformation,
+		r.FirstSeen,
+		r.LastSeen,
+		r.Expires,
+	}
+}
+
+func executeBindings(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("requires exactly one capture file")
+	}
+	table := dhop.NewBindingTable()
+	var last time.Time
+	err := readMessages(args[0], func(m *dhop.CapturedMessage) error {
+		table.Add(m)
+		if m.Timestamp.After(last) {
+			last = m.Timestamp
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reports := make([]bindingReport, 0)
+	for _, b := range table.Bindings(last) {
+		if bindingsActive && b.State != dhop.BindingActive {
+			continue
+		}
+		reports = append(reports, newBindingReport(b))
+	}
+	if outputFormat == FORMAT_TYPE_JSON {
+		return writeJSON(reports)
+	}
+	out, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := csv.NewWriter(out)
+	w.Write([]string{
+		"ip",
+		"chaddr",
+		"client_id",
+		"state",
+		"lease_time",
+		"server",
+		"giaddr",
+		"relay_agent_information",
+		"first_seen",
+		"last_seen",
+		"expires",
+	})
+	for _, r := range reports {
+		w.Write(r.Record())
+	}
+	w.Flush()
+	return w.Error()
+}
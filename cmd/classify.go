@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	classifyCmd = &cobra.Command{
+		Use:   "classify [file]",
+		Short: "Show which client classes DHCP messages match",
+		Long: `classify evaluates the expressions of client classes given by "--class name=expression" for each message from clients,
+and prints the classes which the message matches. The expressions are in the syntax of ISC dhcpd or Kea, such as
+  --class 'pxe=substring(option vendor-class-identifier, 0, 9) = "PXEClient"'
+  --class 'port1=option[82].option[1].hex == 0x65746830'
+All messages from clients in the capture file are classified, or a message is read from the input of "-i" in the input format if the file is omitted.
+Use "-t json" to write the classes in JSON.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: executeClassify,
+	}
+	classifyClasses []string
+)
+
+func init() {
+	classifyCmd.Flags().StringArrayVar(&classifyClasses, "class", nil, "client class in name=expression")
+	rootCmd.AddCommand(classifyCmd)
+}
+
+type classifyReport struct {
+	Timestamp string   `json:"timestamp,omitempty"`
+	XID       string   `json:"xid"`
+	Type      string   `json:"type"`
+	CHAddr    string   `json:"chaddr"`
+	Classes   []string `json:"classes"`
+}
+
+func executeClassify(cmd *cobra.Command, args []string) error {
+	if len(classifyClasses) == 0 {
+		return fmt.Errorf("requires at least one class")
+	}
+	classes := make([]dhop.Class, len(classifyClasses))
+	for i, s := range classifyClasses {
+		a := strings.SplitN(s, "=", 2)
+		if len(a) != 2 || a[0] == "" {
+			return fmt.Errorf("invalid class argument \"%s\"", s)
+		}
+		e, err := dhop.ParseExpression(a[1])
+		if err != nil {
+			return fmt.Errorf("class %s: %s", a[0], err)
+		}
+		classes[i] = dhop.Class{Name: a[0], Test: e}
+	}
+	newReport := func(m *dhop.Message) (classifyReport, error) {
+		names, err := dhop.Classify(m, classes)
+		return classifyReport{
+			XID:     fmt.Sprintf("0x%08x", m.XID),
+			Type:    m.Type().String(),
+			CHAddr:  m.CHAddr.String(),
+			Classes: names,
+		}, err
+	}
+
+	var reports []classifyReport
+	if len(args) == 0 {
+		m, err := readMessage(args)
+		if err != nil {
+			return err
+		}
+		r, err := newReport(m)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, r)
+	} else {
+		err := readMessages(args[0], func(c *dhop.CapturedMessage) error {
+			if c.Message.Op != dhop.OpRequest {
+				return nil
+			}
+			r, err := newReport(c.Message)
+			if err != nil {
+				return err
+			}
+			r.Timestamp = c.Timestamp.Format(timestampFormat)
+			reports = append(reports, r)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if outputFormat == FORMAT_TYPE_JSON {
+		return writeJSON(reports)
+	}
+	w, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, r := range reports {
+		if r.Timestamp != "" {
+			fmt.Fprintf(w, "%s ", r.Timestamp)
+		}
+		fmt.Fprintf(w, "%s xid=%s chaddr=%s: %s\n", r.Type, r.XID, r.CHAddr, strings.Join(r.Classes, ","))
+	}
+	return nil
+}
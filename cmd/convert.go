@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DIALECT_DHCPD    = "dhcpd"
+	DIALECT_DNSMASQ  = "dnsmasq"
+	DIALECT_KEA      = "kea"
+	DIALECT_NETWORKD = "networkd"
+)
+
+type dialect string
+
+func (d *dialect) String() string {
+	return string(*d)
+}
+
+func (d *dialect) Set(v string) error {
+	switch dialect(v) {
+	case DIALECT_DHCPD, DIALECT_DNSMASQ, DIALECT_KEA, DIALECT_NETWORKD:
+		*d = dialect(v)
+	default:
+		return fmt.Errorf("invalid dialect argument \"%s\"", v)
+	}
+	return nil
+}
+
+func (d *dialect) Type() string {
+	return "{dhcpd,dnsmasq,kea,networkd}"
+}
+
+// Unmarshal returns the options in the configuration, and warnings about the settings which are dropped or flattened.
+func (d *dialect) Unmarshal(b []byte) ([]dhop.Option, []string, error) {
+	switch *d {
+	case DIALECT_DHCPD:
+		cfg, err := dhop.UnmarshalDhcpd(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cfg.Options, cfg.Warnings, nil
+	case DIALECT_DNSMASQ:
+		cfg, err := dhop.UnmarshalDnsmasq(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings := make([]string, 0)
+		for _, l := range cfg.Lines {
+			switch {
+			case len(l.Tags) > 0:
+				warnings = append(warnings, fmt.Sprintf("option %d: tag conditions %s are dropped", l.Option.Code, strings.Join(l.Tags, ",")))
+			case l.Vendor != "":
+				warnings = append(warnings, fmt.Sprintf("option %d: vendor class condition %q is dropped", l.Option.Code, l.Vendor))
+			}
+		}
+		return cfg.Options, warnings, nil
+	case DIALECT_KEA:
+		cfg, err := dhop.UnmarshalKea(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cfg.Options, cfg.Warnings, nil
+	case DIALECT_NETWORKD:
+		cfg, err := dhop.UnmarshalNetworkd(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cfg.Options, []string{}, nil
+	}
+	return nil, nil, fmt.Errorf("dialect is not specified")
+}
+
+func (d *dialect) Marshal(options []dhop.Option) ([]byte, error) {
+	switch *d {
+	case DIALECT_DHCPD:
+		return dhop.MarshalDhcpd(options)
+	case DIALECT_DNSMASQ:
+		return dhop.MarshalDnsmasq(options)
+	case DIALECT_KEA:
+		b, err := dhop.MarshalKea(options)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case DIALECT_NETWORKD:
+		return dhop.MarshalNetworkd(options)
+	}
+	return nil, fmt.Errorf("dialect is not specified")
+}
+
+// check returns why the option does not translate cleanly, or an empty string.
+// The option is marshaled alone and parsed again to compare the data.
+func (d *dialect) check(o dhop.Option) (string, error) {
+	b, err := d.Marshal([]dhop.Option{o})
+	if err != nil {
+		return "", err
+	}
+	if *d == DIALECT_KEA {
+		cfg, err := dhop.NewKeaConfig([]dhop.Option{o})
+		if err != nil {
+			return "", err
+		}
+		if f := cfg.OptionData[0].CSVFormat; f != nil && !*f {
+			return "written as hex data with csv-format false", nil
+		}
+	}
+	options, _, err := d.Unmarshal(b)
+	if err != nil {
+		return fmt.Sprintf("cannot be read again: %s", err), nil
+	}
+	if len(options) != 1 || options[0].Code != o.Code || !bytes.Equal(options[0].Encode(), o.Encode()) {
+		return "changes when read again", nil
+	}
+	return "", nil
+}
+
+var (
+	convertCmd = &cobra.Command{
+		Use:   "convert [file]",
+		Short: "Translate DHCP options between server configurations",
+		Long: `convert reads option declarations in the configuration syntax of a DHCP server and writes them in the syntax of another server.
+The dialects are ISC dhcpd, dnsmasq, Kea and systemd-networkd.
+Options which the output dialect cannot express are skipped, and options which change in the translation are written anyway.
+Options of the scopes such as subnets are flattened into one list, and options in spaces which no option encapsulates are dropped.
+All of them and duplicated codes are reported to stderr, and "--strict" makes them fail the command without writing the output.
+The file is read from the input of "-i" if it is omitted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: executeConvert,
+	}
+	convertFrom   dialect
+	convertTo     dialect
+	convertStrict bool
+)
+
+func init() {
+	convertCmd.Flags().Var(&convertFrom, "from", "dialect of the input")
+	convertCmd.Flags().Var(&convertTo, "to", "dialect of the output")
+	convertCmd.Flags().BoolVar(&convertStrict, "strict", false, "fail if some options do not translate cleanly")
+	rootCmd.AddCommand(convertCmd)
+}
+
+func executeConvert(cmd *cobra.Command, args []string) error {
+	if convertFrom == "" || convertTo == "" {
+		return fmt.Errorf("both --from and --to are required")
+	}
+	path := inputPath
+	if len(args) > 0 {
+		path = args[0]
+	}
+	var input []byte
+	var err error
+	if path == "-" {
+		input, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		input, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	options, warnings, err := convertFrom.Unmarshal(input)
+	if err != nil {
+		return err
+	}
+	counts := make(map[dhop.Code]int)
+	for _, o := range options {
+		counts[o.Code]++
+	}
+	for _, o := range options {
+		if n := counts[o.Code]; n > 1 && codes.Contains(byte(o.Code)) {
+			warnings = append(warnings, fmt.Sprintf("option %d is declared %d times", o.Code, n))
+			counts[o.Code] = 0
+		}
+	}
+	translated := make([]dhop.Option, 0, len(options))
+	for _, o := range options {
+		if !codes.Contains(byte(o.Code)) {
+			continue
+		}
+		reason, err := convertTo.check(o)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("not translated: %s", err))
+			continue
+		}
+		if reason != "" {
+			warnings = append(warnings, fmt.Sprintf("option %d: %s", o.Code, reason))
+		}
+		translated = append(translated, o)
+	}
+	output, err := convertTo.Marshal(translated)
+	if err != nil {
+		return err
+	}
+	for _, s := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", s)
+	}
+	if convertStrict && len(warnings) > 0 {
+		return fmt.Errorf("%d settings do not translate cleanly", len(warnings))
+	}
+	w, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	_, err = w.Write(output)
+	return err
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bgpat/dhop"
+	"github.com/bgpat/dhop/pcap"
+	"github.com/spf13/cobra"
+)
+
+var (
+	editCmd = &cobra.Command{
+		Use:   "edit <file>",
+		Short: "Rewrite DHCP options in a pcap or pcapng file",
+		Long: `edit rewrites the options of each DHCPv4 message in a capture file and writes a pcap file.
+Options are specified by code or name, and "code.subcode" edits a sub-option (e.g. 82.1 for Circuit ID).
+Values are text data, or encoded data in the input format with --decode.
+Deletions, sets and appends are applied in this order.
+The edited message is padded to the original size or 300 bytes, and replaces the payload in the captured frame,
+whose IP and UDP lengths and checksums are recomputed. The other headers are kept as captured.
+Other records are copied as is, and the number of records skipped for the link type of the output is reported.`,
+		RunE: executeEdit,
+	}
+	editSets    []string
+	editDeletes []string
+	editAppends []string
+)
+
+// bootpMessageSize is the size of a BOOTP message with the 64-byte vend field (RFC 951),
+// which some relay agents and clients require.
+const bootpMessageSize = 300
+
+func init() {
+	editCmd.Flags().StringArrayVar(&editSets, "set", nil, "set option data (e.g. router=10.0.0.1)")
+	editCmd.Flags().StringArrayVar(&editDeletes, "delete", nil, "delete option (e.g. 82)")
+	editCmd.Flags().StringArrayVar(&editAppends, "append", nil, "append option data (e.g. 6=8.8.8.8)")
+	rootCmd.AddCommand(editCmd)
+}
+
+func parseEdit(op dhop.EditOperation, s string) (dhop.Edit, error) {
+	key, value := s, ""
+	if op != dhop.EditDelete {
+		a := strings.SplitN(s, "=", 2)
+		if len(a) != 2 {
+			return dhop.Edit{}, fmt.Errorf("invalid %s argument \"%s\"", op, s)
+		}
+		key, value = a[0], a[1]
+	}
+	code, subCode, err := dhop.ParseEditKey(key)
+	if err != nil {
+		return dhop.Edit{}, err
+	}
+	e := dhop.Edit{
+		Operation: op,
+		Code:      code,
+		SubCode:   subCode,
+	}
+	if op == dhop.EditDelete {
+		return e, nil
+	}
+	switch {
+	case isDecode:
+		e.Data, err = inputFormat.Decode([]byte(value))
+	case subCode >= 0:
+		e.Data = []byte(value)
+	default:
+		var o dhop.Option
+		o, err = dhop.Unmarshal(byte(code), []byte(value))
+		e.Data = o.Encode()
+	}
+	return e, err
+}
+
+func parseEdits() ([]dhop.Edit, error) {
+	edits := make([]dhop.Edit, 0, len(editDeletes)+len(editSets)+len(editAppends))
+	for _, arg := range []struct {
+		op   dhop.EditOperation
+		args []string
+	}{
+		{dhop.EditDelete, editDeletes},
+		{dhop.EditSet, editSets},
+		{dhop.EditAppend, editAppends},
+	} {
+		for _, s := range arg.args {
+			e, err := parseEdit(arg.op, s)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, e)
+		}
+	}
+	return edits, nil
+}
+
+func executeEdit(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("requires exactly one capture file")
+	}
+	if isDecode && inputFormat == FORMAT_TYPE_DEFAULT {
+		inputFormat = FORMAT_TYPE_HEX
+	}
+	edits, err := parseEdits()
+	if err != nil {
+		return err
+	}
+	w, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	var pw *pcap.Writer
+	skipped := 0
+	writeRecord := func(record *pcap.Record) error {
+		if record.LinkType != pw.LinkType() {
+			skipped++
+			return nil
+		}
+		return pw.WriteRecord(record)
+	}
+	err = readRecords(args[0], func(record *pcap.Record) error {
+		if pw == nil {
+			pw, err = pcap.NewWriter(w, record.LinkType)
+			if err != nil {
+				return err
+			}
+		}
+		p, err := record.Packet()
+		if err != nil || !p.IsDHCPv4() {
+			return writeRecord(record)
+		}
+		m := dhop.Message{}
+		if err := m.Decode(p.Payload); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p.Timestamp.Format(timestampFormat), err)
+			return writeRecord(record)
+		}
+		for _, e := range edits {
+			if err := e.Apply(&m); err != nil {
+				return fmt.Errorf("%s: %s", p.Timestamp.Format(timestampFormat), err)
+			}
+		}
+		payload := m.Encode()
+		size := len(p.Payload)
+		if size < bootpMessageSize {
+			size = bootpMessageSize
+		}
+		if len(payload) < size {
+			payload = append(payload, make([]byte, size-len(payload))...)
+		}
+		spliced, err := record.Splice(payload)
+		if err != nil {
+			return err
+		}
+		return writeRecord(spliced)
+	})
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "%d records are skipped because the link type differs from the output\n", skipped)
+	}
+	return err
+}
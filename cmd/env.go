@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envCmd = &cobra.Command{
+		Use:   "env [file]",
+		Short: "Print hook script variables of DHCP clients for a message",
+		Long: `env prints the environment variables which dhclient-script, or the script of udhcpc with "--udhcpc", receives for a DHCP message.
+The message is the last DHCPACK in the capture file, or is read from the input of "-i" in the input format if the file is omitted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: executeEnv,
+	}
+	udhcpc    bool
+	envPrefix string
+)
+
+func init() {
+	envCmd.Flags().BoolVar(&udhcpc, "udhcpc", false, "print variables of busybox udhcpc")
+	envCmd.Flags().StringVar(&envPrefix, "prefix", "new_", "prefix of dhclient-script variables")
+	rootCmd.AddCommand(envCmd)
+}
+
+func readMessage(args []string) (*dhop.Message, error) {
+	if len(args) == 0 {
+		if inputFormat == FORMAT_TYPE_DEFAULT {
+			inputFormat = FORMAT_TYPE_BINARY
+			noTrimSpace = true
+		}
+		input, err := readInput()
+		if err != nil {
+			return nil, err
+		}
+		m := &dhop.Message{}
+		if err := m.Decode(input); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	var ack *dhop.Message
+	err := readMessages(args[0], func(c *dhop.CapturedMessage) error {
+		if c.Message.Type() == dhop.MessageTypeAck {
+			ack = c.Message
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ack == nil {
+		return nil, fmt.Errorf("no DHCPACK in %s", args[0])
+	}
+	return ack, nil
+}
+
+func executeEnv(cmd *cobra.Command, args []string) error {
+	m, err := readMessage(args)
+	if err != nil {
+		return err
+	}
+	filterOptions(m)
+	var env []dhop.EnvVar
+	if udhcpc {
+		env = dhop.UdhcpcEnv(m)
+	} else {
+		env = dhop.DhclientEnv(m, envPrefix)
+	}
+	for _, v := range env {
+		fmt.Println(v.String())
+	}
+	return nil
+}
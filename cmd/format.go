@@ -15,6 +15,9 @@ const (
 	FORMAT_TYPE_JSON    = "json"
 	FORMAT_TYPE_HEX     = "hex"
 	FORMAT_TYPE_BASE64  = "base64"
+	FORMAT_TYPE_PCAP    = "pcap"
+	FORMAT_TYPE_CSV     = "csv"
+	FORMAT_TYPE_DNSMASQ = "dnsmasq"
 )
 
 type formatType string
@@ -25,7 +28,7 @@ func (f *formatType) String() string {
 
 func (f *formatType) Set(v string) error {
 	switch formatType(v) {
-	case FORMAT_TYPE_BINARY, FORMAT_TYPE_JSON, FORMAT_TYPE_HEX, FORMAT_TYPE_BASE64:
+	case FORMAT_TYPE_BINARY, FORMAT_TYPE_JSON, FORMAT_TYPE_HEX, FORMAT_TYPE_BASE64, FORMAT_TYPE_PCAP, FORMAT_TYPE_CSV, FORMAT_TYPE_DNSMASQ:
 		*f = formatType(v)
 	default:
 		return fmt.Errorf("invalid format argument \"%s\"", v)
@@ -34,7 +37,7 @@ func (f *formatType) Set(v string) error {
 }
 
 func (f *formatType) Type() string {
-	return "{binary,json,hex,base64}"
+	return "{binary,json,hex,base64,pcap,csv,dnsmasq}"
 }
 
 func (f *formatType) Encode(src []byte) (string, error) {
@@ -55,6 +58,12 @@ func (f *formatType) Encode(src []byte) (string, error) {
 		return strings.Join(strings.Split(fmt.Sprintf("% x", src), " "), separator), nil
 	case FORMAT_TYPE_BASE64:
 		return base64.StdEncoding.EncodeToString(src), nil
+	case FORMAT_TYPE_PCAP:
+		return "", fmt.Errorf("pcap format is only available for DHCP messages")
+	case FORMAT_TYPE_CSV:
+		return "", fmt.Errorf("csv format is only available for reports")
+	case FORMAT_TYPE_DNSMASQ:
+		return "", fmt.Errorf("dnsmasq format is only available for options")
 	}
 	return "", fmt.Errorf("invalid input \"%s\"", src)
 }
@@ -100,6 +109,12 @@ func (f *formatType) Decode(src []byte) ([]byte, error) {
 			return nil, err
 		}
 		return dst[:n], nil
+	case FORMAT_TYPE_PCAP:
+		return nil, fmt.Errorf("pcap format is only available for DHCP messages")
+	case FORMAT_TYPE_CSV:
+		return nil, fmt.Errorf("csv format is only available for reports")
+	case FORMAT_TYPE_DNSMASQ:
+		return nil, fmt.Errorf("dnsmasq format is only available for output")
 	}
 	return nil, fmt.Errorf("invalid input \"%s\"", src)
 }
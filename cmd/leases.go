@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	leasesCmd = &cobra.Command{
+		Use:   "leases <file>",
+		Short: "Print leases in a dhclient.leases or dhcpd.leases file",
+		Long: `leases parses the lease declarations of ISC dhclient and dhcpd lease files and prints them.
+The leases are printed as a table by default, or in JSON with "-t json".
+Use "-" as the file to read from stdin.`,
+		RunE: executeLeases,
+	}
+	leaseIP     net.IP
+	leaseMAC    string
+	leaseState  string
+	latestLease bool
+	leaseActive bool
+)
+
+func init() {
+	leasesCmd.Flags().BoolVar(&leaseActive, "active", false, "only output leases which are bound now")
+	leasesCmd.Flags().IPVar(&leaseIP, "ip", nil, "only output leases of the address")
+	leasesCmd.Flags().StringVar(&leaseMAC, "mac", "", "only output leases of the hardware address")
+	leasesCmd.Flags().StringVar(&leaseState, "state", "", "only output leases in the binding state")
+	leasesCmd.Flags().BoolVar(&latestLease, "latest", false, "only output the last declaration of each address")
+	rootCmd.AddCommand(leasesCmd)
+}
+
+type optionReport struct {
+	Code  dhop.Code `json:"code"`
+	Name  string    `json:"name"`
+	Value string    `json:"value"`
+}
+
+type leaseReport struct {
+	IP             string            `json:"ip,omitempty"`
+	Interface      string            `json:"interface,omitempty"`
+	HardwareAddr   string            `json:"hardware_address,omitempty"`
+	ClientID       string            `json:"client_id,omitempty"`
+	Hostname       string            `json:"hostname,omitempty"`
+	State          string            `json:"state,omitempty"`
+	NextState      string            `json:"next_state,omitempty"`
+	Starts         string            `json:"starts,omitempty"`
+	Ends           string            `json:"ends,omitempty"`
+	Renew          string            `json:"renew,omitempty"`
+	Rebind         string            `json:"rebind,omitempty"`
+	Expire         string            `json:"expire,omitempty"`
+	Variables      map[string]string `json:"variables,omitempty"`
+	Options        []optionReport    `json:"options"`
+	UnknownOptions []string          `json:"unknown_options,omitempty"`
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timestampFormat)
+}
+
+func newLeaseReport(l *dhop.Lease) leaseReport {
+	r := leaseReport{
+		Interface:      l.Interface,
+		HardwareAddr:   l.HardwareAddr.String(),
+		ClientID:       hex.EncodeToString(l.ClientID),
+		Hostname:       l.Hostname,
+		State:          l.State,
+		NextState:      l.NextState,
+		Starts:         formatTime(l.Starts),
+		Ends:           formatTime(l.Ends),
+		Renew:          formatTime(l.Renew),
+		Rebind:         formatTime(l.Rebind),
+		Expire:         formatTime(l.Expire),
+		Variables:      l.Variables,
+		Options:        make([]optionReport, len(l.Options)),
+		UnknownOptions: l.UnknownOptions,
+	}
+	if l.IP != nil {
+		r.IP = l.IP.String()
+	}
+	for i, o := range l.Options {
+		r.Options[i] = optionReport{
+			Code:  o.Code,
+			Name:  o.Code.String(),
+			Value: string(o.Marshal()),
+		}
+	}
+	return r
+}
+
+func filterLeases(leases []*dhop.Lease, now time.Time) []*dhop.Lease {
+	if latestLease {
+		index := make(map[string]int)
+		latest := make([]*dhop.Lease, 0, len(leases))
+		for _, l := range leases {
+			key := l.Interface + "/" + l.IP.String()
+			if i, ok := index[key]; ok {
+				latest[i] = l
+				continue
+			}
+			index[key] = len(latest)
+			latest = append(latest, l)
+		}
+		leases = latest
+	}
+	filtered := make([]*dhop.Lease, 0, len(leases))
+	for _, l := range leases {
+		switch {
+		case leaseActive && !l.Active(now):
+		case leaseIP != nil && !leaseIP.Equal(l.IP):
+		case leaseMAC != "" && !strings.EqualFold(leaseMAC, l.HardwareAddr.String()):
+		case leaseState != "" && leaseState != l.State:
+		default:
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
+
+func executeLeases(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("requires exactly one lease file")
+	}
+	var b []byte
+	var err error
+	if args[0] == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	leases, err := dhop.UnmarshalLeases(b)
+	if err != nil {
+		return err
+	}
+	reports := make([]leaseReport, 0)
+	for _, l := range filterLeases(leases, time.Now()) {
+		reports = append(reports, newLeaseReport(l))
+	}
+	if outputFormat == FORMAT_TYPE_JSON {
+		return writeJSON(reports)
+	}
+	out, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tHARDWARE\tSTATE\tSTART\tEND\tHOSTNAME\tINTERFACE\tOPTIONS")
+	for _, r := range reports {
+		end := r.Ends
+		if end == "" {
+			end = r.Expire
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			orDash(r.IP), orDash(r.HardwareAddr), orDash(r.State), orDash(r.Starts),
+			orDash(end), orDash(r.Hostname), orDash(r.Interface), len(r.Options))
+	}
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
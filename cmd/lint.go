@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintCmd = &cobra.Command{
+		Use:   "lint [file]",
+		Short: "Check options of DHCP messages against RFC 2131",
+		Long: `lint checks which options must, should not or must not appear in each message type (RFC 2131 Table 3 and Table 5),
+the header fields and duplicated options, and prints the violations.
+It also reports semantic inconsistencies such as routers outside of the subnet and T1 not less than T2.
+All messages in the capture file are checked, or a message is read from the input of "-i" in the input format if the file is omitted.
+Use "-t json" to write the violations in JSON. lint exits with 1 if there are errors or inconsistencies.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: executeLint,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
+
+type lintReport struct {
+	Timestamp  string           `json:"timestamp,omitempty"`
+	XID        string           `json:"xid"`
+	Type       string           `json:"type"`
+	Violations []dhop.Violation `json:"violations"`
+	// Inconsistencies are the results of semantic checks of the options.
+	Inconsistencies []dhop.Inconsistency `json:"inconsistencies"`
+}
+
+func newLintReport(m *dhop.Message) lintReport {
+	return lintReport{
+		XID:             fmt.Sprintf("0x%08x", m.XID),
+		Type:            m.Type().String(),
+		Violations:      dhop.Lint(m),
+		Inconsistencies: dhop.CheckOptions(m.Options, m.YIAddr),
+	}
+}
+
+func executeLint(cmd *cobra.Command, args []string) error {
+	var reports []lintReport
+	if len(args) == 0 {
+		m, err := readMessage(args)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, newLintReport(m))
+	} else {
+		err := readMessages(args[0], func(c *dhop.CapturedMessage) error {
+			r := newLintReport(c.Message)
+			r.Timestamp = c.Timestamp.Format(timestampFormat)
+			reports = append(reports, r)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	errors := 0
+	for _, r := range reports {
+		errors += len(r.Inconsistencies)
+		for _, v := range r.Violations {
+			if v.Severity == dhop.SeverityError {
+				errors++
+			}
+		}
+	}
+	if outputFormat == FORMAT_TYPE_JSON {
+		if err := writeJSON(reports); err != nil {
+			return err
+		}
+	} else {
+		w, err := createOutput()
+		if err != nil {
+			return err
+		}
+		for _, r := range reports {
+			if len(r.Violations) == 0 && len(r.Inconsistencies) == 0 {
+				continue
+			}
+			if r.Timestamp != "" {
+				fmt.Fprintf(w, "%s ", r.Timestamp)
+			}
+			fmt.Fprintf(w, "%s xid=%s\n", r.Type, r.XID)
+			for _, v := range r.Violations {
+				fmt.Fprintf(w, "  %s: %s\n", v.Severity, v.Message)
+			}
+			for _, c := range r.Inconsistencies {
+				codes := make([]string, len(c.Codes))
+				for i, code := range c.Codes {
+					codes[i] = strconv.Itoa(int(code))
+				}
+				fmt.Fprintf(w, "  inconsistent (%s): %s\n", strings.Join(codes, ", "), c.Message)
+			}
+		}
+		w.Close()
+	}
+	if errors > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
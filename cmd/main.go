@@ -2,10 +2,14 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"time"
 
 	"github.com/bgpat/dhop"
+	"github.com/bgpat/dhop/pcap"
 	"github.com/spf13/cobra"
 )
 
@@ -78,24 +82,59 @@ func writeOutput(output []byte, code dhop.Code) error {
 	return nil
 }
 
-func encode(input []byte, code byte) error {
-	op, err := dhop.Unmarshal(code, input)
-	if err != nil {
-		return err
+// writeOption writes the option in the output format.
+// The data is marshaled as text if text is true, or encoded otherwise.
+func writeOption(op dhop.Option, text bool) error {
+	if outputFormat == FORMAT_TYPE_DNSMASQ {
+		lines, err := dhop.MarshalDnsmasq([]dhop.Option{op})
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(lines))
+		return nil
+	}
+	if text {
+		return writeOutput(op.Marshal(), op.Code)
 	}
 	return writeOutput(op.Encode(), op.Code)
 }
 
-func decode(input []byte, code byte) error {
-	op, err := dhop.Decode(code, input)
+func createOutput() (io.WriteCloser, error) {
+	if outputPath == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(outputPath)
+}
+
+func writeMessages(messages []*dhop.Message) error {
+	w, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	pw, err := pcap.NewWriter(w, pcap.LinkTypeEthernet)
 	if err != nil {
 		return err
 	}
-	return writeOutput(op.Marshal(), op.Code)
+	now := time.Now()
+	for _, m := range messages {
+		if err := pw.WritePacket(pcap.NewPacket(m, now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encode(input []byte, code byte) (dhop.Option, error) {
+	return dhop.Unmarshal(code, input)
+}
+
+func decode(input []byte, code byte) (dhop.Option, error) {
+	return dhop.Decode(code, input)
 }
 
 func execute(cmd *cobra.Command, args []string) error {
-	var convert func([]byte, byte) error
+	var convert func([]byte, byte) (dhop.Option, error)
 	if isDecode {
 		convert = decode
 		if inputFormat == FORMAT_TYPE_DEFAULT {
@@ -118,8 +157,22 @@ func execute(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	var success bool
+	message := &dhop.Message{
+		Op:     dhop.OpRequest,
+		HType:  1,
+		HLen:   6,
+		CHAddr: make(net.HardwareAddr, 6),
+	}
 	for _, code := range codes.Slice() {
-		err := convert(input, code)
+		op, err := convert(input, code)
+		if err != nil {
+			continue
+		}
+		if outputFormat == FORMAT_TYPE_PCAP {
+			message.Options = append(message.Options, op)
+		} else {
+			err = writeOption(op, isDecode)
+		}
 		if err != nil {
 			continue
 		}
@@ -128,5 +181,8 @@ func execute(cmd *cobra.Command, args []string) error {
 	if !success {
 		os.Exit(1)
 	}
+	if outputFormat == FORMAT_TYPE_PCAP {
+		return writeMessages([]*dhop.Message{message})
+	}
 	return nil
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeCmd = &cobra.Command{
+		Use:   "merge [name=]file...",
+		Short: "Merge layered option scopes and show which layer supplied each option",
+		Long: `merge reads option declarations of scopes from the files in the order of precedence,
+such as global=global.conf subnet=subnet.conf host=host.conf, and prints the final options with the layers which supplied them.
+The name of a layer is the file name if it is omitted. Options of a later layer override the options of the earlier layers,
+or are appended with "--append". "--remove name=code" removes the option of the code from the lower layers at the layer.
+The files are read in the dialect of "--from". Use "-t json" to write the options in JSON.`,
+		RunE: executeMerge,
+	}
+	mergeFrom   = dialect(DIALECT_DHCPD)
+	mergeAppend codeRanges
+	mergeRemove []string
+)
+
+func init() {
+	mergeCmd.Flags().Var(&mergeFrom, "from", "dialect of the files")
+	mergeCmd.Flags().Var(&mergeAppend, "append", "codes whose data is appended to the lower layers")
+	mergeCmd.Flags().StringSliceVar(&mergeRemove, "remove", nil, "options removed at a layer in name=code")
+	rootCmd.AddCommand(mergeCmd)
+}
+
+type mergedOptionReport struct {
+	optionReport
+	Sources []string `json:"sources"`
+}
+
+func executeMerge(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("requires at least one file")
+	}
+	removes := make(map[string][]dhop.Code)
+	for _, s := range mergeRemove {
+		a := strings.SplitN(s, "=", 2)
+		if len(a) != 2 {
+			return fmt.Errorf("invalid remove argument \"%s\"", s)
+		}
+		code, err := strconv.ParseUint(a[1], 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid remove argument \"%s\"", s)
+		}
+		removes[a[0]] = append(removes[a[0]], dhop.Code(code))
+	}
+	layers := make([]dhop.Layer, len(args))
+	for i, arg := range args {
+		name, path := arg, arg
+		if a := strings.SplitN(arg, "=", 2); len(a) == 2 {
+			name, path = a[0], a[1]
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		options, warnings, err := mergeFrom.Unmarshal(b)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		for _, s := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", path, s)
+		}
+		l := dhop.Layer{
+			Name:    name,
+			Options: options,
+			Modes:   make(map[dhop.Code]dhop.MergeMode),
+		}
+		for _, code := range mergeAppend.Slice() {
+			l.Modes[dhop.Code(code)] = dhop.MergeAppend
+		}
+		for _, code := range removes[name] {
+			l.Modes[code] = dhop.MergeRemove
+		}
+		layers[i] = l
+	}
+	reports := make([]mergedOptionReport, 0)
+	for _, m := range dhop.MergeLayers(layers) {
+		if !codes.Contains(byte(m.Code)) {
+			continue
+		}
+		reports = append(reports, mergedOptionReport{
+			optionReport: optionReport{
+				Code:  m.Code,
+				Name:  m.Code.String(),
+				Value: string(m.Marshal()),
+			},
+			Sources: m.Sources,
+		})
+	}
+	if outputFormat == FORMAT_TYPE_JSON {
+		return writeJSON(reports)
+	}
+	out, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tNAME\tVALUE\tSOURCES")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Code, r.Name, r.Value, strings.Join(r.Sources, ","))
+	}
+	return w.Flush()
+}
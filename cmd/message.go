@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/bgpat/dhop"
+)
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return "-"
+	}
+	return ip.String()
+}
+
+func printMessage(m *dhop.Message) error {
+	fmt.Printf("op: %d\n", m.Op)
+	fmt.Printf("htype: %d\n", m.HType)
+	fmt.Printf("hlen: %d\n", m.HLen)
+	fmt.Printf("hops: %d\n", m.Hops)
+	fmt.Printf("xid: 0x%08x\n", m.XID)
+	fmt.Printf("secs: %d\n", m.Secs)
+	fmt.Printf("flags: 0x%04x\n", m.Flags)
+	fmt.Printf("ciaddr: %s\n", ipString(m.CIAddr))
+	fmt.Printf("yiaddr: %s\n", ipString(m.YIAddr))
+	fmt.Printf("siaddr: %s\n", ipString(m.SIAddr))
+	fmt.Printf("giaddr: %s\n", ipString(m.GIAddr))
+	fmt.Printf("chaddr: %s\n", m.CHAddr)
+	fmt.Printf("sname: %q\n", m.SName)
+	fmt.Printf("file: %q\n", m.File)
+	for _, op := range m.Options {
+		if err := writeOption(op, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printMessage6(m *dhop.Message6) error {
+	if m.IsRelay() {
+		fmt.Printf("hop-count: %d\n", m.HopCount)
+		fmt.Printf("link-address: %s\n", ipString(m.LinkAddress))
+		fmt.Printf("peer-address: %s\n", ipString(m.PeerAddress))
+	} else {
+		fmt.Printf("transaction-id: 0x%06x\n", m.TransactionID)
+	}
+	hex := formatType(FORMAT_TYPE_HEX)
+	for _, op := range m.Options {
+		encoded, err := hex.Encode(op.Data)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d: %s\n", op.Code, encoded)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/bgpat/dhop"
+	"github.com/bgpat/dhop/pcap"
+	"github.com/spf13/cobra"
+)
+
+const timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+var pcapCmd = &cobra.Command{
+	Use:   "pcap <file>",
+	Short: "Decode DHCP messages in a pcap or pcapng file",
+	Long: `pcap reads DHCPv4 and DHCPv6 messages from a pcap or pcapng file and prints each decoded message.
+Use "-" as the file to read from stdin.`,
+	RunE: executePcap,
+}
+
+func init() {
+	rootCmd.AddCommand(pcapCmd)
+}
+
+func openCapture(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(path)
+}
+
+func readRecords(path string, f func(*pcap.Record) error) error {
+	file, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r, err := pcap.NewReader(file)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(record); err != nil {
+			return err
+		}
+	}
+}
+
+func readCapture(path string, f func(*pcap.Packet) error) error {
+	return readRecords(path, func(record *pcap.Record) error {
+		p, err := record.Packet()
+		if err != nil {
+			return nil
+		}
+		return f(p)
+	})
+}
+
+func readMessages(path string, f func(*dhop.CapturedMessage) error) error {
+	return readCapture(path, func(p *pcap.Packet) error {
+		if !p.IsDHCPv4() {
+			return nil
+		}
+		m := &dhop.Message{}
+		if err := m.Decode(p.Payload); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p.Timestamp.Format(timestampFormat), err)
+			return nil
+		}
+		return f(&dhop.CapturedMessage{
+			Timestamp: p.Timestamp,
+			Src:       p.Src,
+			Dst:       p.Dst,
+			Message:   m,
+		})
+	})
+}
+
+func printPacket(p *pcap.Packet, summary string) {
+	fmt.Printf(
+		"%s %s > %s %s\n",
+		p.Timestamp.Format(timestampFormat),
+		endpoint(p.Src, p.SrcPort),
+		endpoint(p.Dst, p.DstPort),
+		summary,
+	)
+}
+
+func endpoint(ip net.IP, port uint16) string {
+	if ip.To4() == nil {
+		return fmt.Sprintf("[%s]:%d", ipString(ip), port)
+	}
+	return fmt.Sprintf("%s:%d", ipString(ip), port)
+}
+
+func decodePacket(p *pcap.Packet) (*dhop.Message, *dhop.Message6, error) {
+	switch {
+	case p.IsDHCPv4():
+		m := &dhop.Message{}
+		if err := m.Decode(p.Payload); err != nil {
+			return nil, nil, err
+		}
+		return m, nil, nil
+	case p.IsDHCPv6():
+		m := &dhop.Message6{}
+		if err := m.Decode(p.Payload); err != nil {
+			return nil, nil, err
+		}
+		return nil, m, nil
+	}
+	return nil, nil, nil
+}
+
+func messageSummary(m *dhop.Message) string {
+	if m.Type() == 0 {
+		return fmt.Sprintf("BOOTP op=%d xid=0x%08x", m.Op, m.XID)
+	}
+	return fmt.Sprintf("DHCPv4 %s xid=0x%08x", m.Type(), m.XID)
+}
+
+func filterOptions(m *dhop.Message) {
+	options := make([]dhop.Option, 0, len(m.Options))
+	for _, op := range m.Options {
+		if codes.Contains(byte(op.Code)) {
+			options = append(options, op)
+		}
+	}
+	m.Options = options
+}
+
+func executePcap(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("requires exactly one capture file")
+	}
+	if outputFormat == FORMAT_TYPE_DEFAULT {
+		outputFormat = FORMAT_TYPE_BINARY
+	}
+	var pw *pcap.Writer
+	if outputFormat == FORMAT_TYPE_PCAP {
+		w, err := createOutput()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		pw, err = pcap.NewWriter(w, pcap.LinkTypeEthernet)
+		if err != nil {
+			return err
+		}
+	}
+	return readCapture(args[0], func(p *pcap.Packet) error {
+		m, m6, err := decodePacket(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", p.Timestamp.Format(timestampFormat), err)
+			return nil
+		}
+		if m != nil {
+			filterOptions(m)
+		}
+		if pw != nil {
+			switch {
+			case m != nil:
+				p.Payload = m.Encode()
+			case m6 != nil:
+				p.Payload = m6.Encode()
+			default:
+				return nil
+			}
+			return pw.WritePacket(p)
+		}
+		switch {
+		case m != nil:
+			printPacket(p, messageSummary(m))
+			err = printMessage(m)
+		case m6 != nil:
+			printPacket(p, fmt.Sprintf("DHCPv6 %s", m6.Type))
+			err = printMessage6(m6)
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		return nil
+	})
+}
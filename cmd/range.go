@@ -112,3 +112,12 @@ func (r *codeRanges) Slice() []byte {
 	}
 	return s
 }
+
+func (r *codeRanges) Contains(code byte) bool {
+	for _, codes := range *r {
+		if codes.From <= code && code <= codes.To {
+			return true
+		}
+	}
+	return false
+}
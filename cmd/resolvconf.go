@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bgpat/dhop"
+	"github.com/bgpat/dhop/pcap"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resolvconfCmd = &cobra.Command{
+		Use:   "resolvconf [file]",
+		Short: "Generate resolver configuration from DNS options",
+		Long: `resolvconf generates resolv.conf, or a drop-in file of systemd-resolved with "--resolved", from DNS options.
+The options are read from the last DHCPACK and the last DHCPv6 Reply in the capture file,
+or from a DHCPv4 message in the input of "-i" if the file is omitted.
+Warnings about ignored servers and the limits of glibc are printed to stderr.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: executeResolvconf,
+	}
+	resolved bool
+)
+
+func init() {
+	resolvconfCmd.Flags().BoolVar(&resolved, "resolved", false, "generate a drop-in file of systemd-resolved")
+	rootCmd.AddCommand(resolvconfCmd)
+}
+
+func executeResolvconf(cmd *cobra.Command, args []string) error {
+	var options []dhop.Option
+	var options6 []dhop.Option6
+	if len(args) == 0 {
+		m, err := readMessage(args)
+		if err != nil {
+			return err
+		}
+		options = m.Options
+	} else {
+		err := readCapture(args[0], func(p *pcap.Packet) error {
+			m, m6, err := decodePacket(p)
+			if err != nil {
+				return nil
+			}
+			switch {
+			case m != nil && m.Type() == dhop.MessageTypeAck:
+				options = m.Options
+			case m6 != nil && m6.Type == dhop.MessageType6Reply:
+				options6 = m6.Options
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if options == nil && options6 == nil {
+			return fmt.Errorf("no DHCPACK or DHCPv6 Reply in %s", args[0])
+		}
+	}
+	c := dhop.NewResolverConfig(options, options6)
+	for _, s := range c.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", s)
+	}
+	w, err := createOutput()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if resolved {
+		_, err = w.Write(c.Resolved())
+	} else {
+		_, err = w.Write(c.ResolvConf())
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bgpat/dhop"
+	"github.com/spf13/cobra"
+)
+
+var (
+	routesCmd = &cobra.Command{
+		Use:   "routes [file]",
+		Short: "Print the routes which a client installs for a message",
+		Long: `routes computes the routing table which a DHCP client installs from Router, Static Route and Classless Static Route options,
+and prints it as "ip route add" commands, or in the format of "ip route show" with "--show" to diff with the actual table.
+The message is read as the "env" command does.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: executeRoutes,
+	}
+	windowsRoutes bool
+	routeDevice   string
+	showRoutes    bool
+)
+
+func init() {
+	routesCmd.Flags().BoolVar(&windowsRoutes, "windows", false, "handle option 249 and routers as Windows does")
+	routesCmd.Flags().StringVar(&routeDevice, "dev", "", "device of the routes")
+	routesCmd.Flags().BoolVar(&showRoutes, "show", false, "print routes in the format of \"ip route show\"")
+	rootCmd.AddCommand(routesCmd)
+}
+
+func executeRoutes(cmd *cobra.Command, args []string) error {
+	m, err := readMessage(args)
+	if err != nil {
+		return err
+	}
+	mode := dhop.RouteModeRFC3442
+	if windowsRoutes {
+		mode = dhop.RouteModeWindows
+	}
+	for _, r := range dhop.EffectiveRoutes(m.Options, mode) {
+		if showRoutes {
+			fmt.Println(r.Spec(routeDevice))
+		} else {
+			fmt.Println(r.Command(routeDevice))
+		}
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package dhop
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type Code byte
@@ -327,3 +329,36 @@ func (c *Code) String() string {
 	}
 	return fmt.Sprintf("N/A (%d)", *c)
 }
+
+// ParseCode parses a code number, a name returned by Code.String or a name of ISC dhcpd.
+// Names are compared case-insensitively ignoring spaces and punctuation.
+func ParseCode(s string) (Code, error) {
+	if n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 8); err == nil {
+		return Code(n), nil
+	}
+	name := normalizeCodeName(s)
+	for i := 0; i < 256; i++ {
+		c := Code(i)
+		if normalizeCodeName(c.String()) == name {
+			return c, nil
+		}
+	}
+	for c, n := range dhcpdOptionNames {
+		if normalizeCodeName(n) == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown option code \"%s\"", s)
+}
+
+func normalizeCodeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			return r
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, s)
+}
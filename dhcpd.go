@@ -0,0 +1,937 @@
+package dhop
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// https://kb.isc.org/docs/isc-dhcp-44-manual-pages-dhcp-options
+var dhcpdOptionNames = map[Code]string{
+	1:   "subnet-mask",
+	2:   "time-offset",
+	3:   "routers",
+	4:   "time-servers",
+	5:   "ien116-name-servers",
+	6:   "domain-name-servers",
+	7:   "log-servers",
+	8:   "cookie-servers",
+	9:   "lpr-servers",
+	10:  "impress-servers",
+	11:  "resource-location-servers",
+	12:  "host-name",
+	13:  "boot-size",
+	14:  "merit-dump",
+	15:  "domain-name",
+	16:  "swap-server",
+	17:  "root-path",
+	18:  "extensions-path",
+	19:  "ip-forwarding",
+	20:  "non-local-source-routing",
+	21:  "policy-filter",
+	22:  "max-dgram-reassembly",
+	23:  "default-ip-ttl",
+	24:  "path-mtu-aging-timeout",
+	25:  "path-mtu-plateau-table",
+	26:  "interface-mtu",
+	27:  "all-subnets-local",
+	28:  "broadcast-address",
+	29:  "perform-mask-discovery",
+	30:  "mask-supplier",
+	31:  "router-discovery",
+	32:  "router-solicitation-address",
+	33:  "static-routes",
+	34:  "trailer-encapsulation",
+	35:  "arp-cache-timeout",
+	36:  "ieee802-3-encapsulation",
+	37:  "default-tcp-ttl",
+	38:  "tcp-keepalive-interval",
+	39:  "tcp-keepalive-garbage",
+	40:  "nis-domain",
+	41:  "nis-servers",
+	42:  "ntp-servers",
+	43:  "vendor-encapsulated-options",
+	44:  "netbios-name-servers",
+	45:  "netbios-dd-server",
+	46:  "netbios-node-type",
+	47:  "netbios-scope",
+	48:  "font-servers",
+	49:  "x-display-manager",
+	50:  "dhcp-requested-address",
+	51:  "dhcp-lease-time",
+	52:  "dhcp-option-overload",
+	53:  "dhcp-message-type",
+	54:  "dhcp-server-identifier",
+	55:  "dhcp-parameter-request-list",
+	56:  "dhcp-message",
+	57:  "dhcp-max-message-size",
+	58:  "dhcp-renewal-time",
+	59:  "dhcp-rebinding-time",
+	60:  "vendor-class-identifier",
+	61:  "dhcp-client-identifier",
+	62:  "nwip-domain",
+	64:  "nisplus-domain",
+	65:  "nisplus-servers",
+	66:  "tftp-server-name",
+	67:  "bootfile-name",
+	68:  "mobile-ip-home-agent",
+	69:  "smtp-server",
+	70:  "pop-server",
+	71:  "nntp-server",
+	72:  "www-server",
+	73:  "finger-server",
+	74:  "irc-server",
+	75:  "streettalk-server",
+	76:  "streettalk-directory-assistance-server",
+	77:  "user-class",
+	78:  "slp-directory-agent",
+	79:  "slp-service-scope",
+	81:  "fqdn",
+	82:  "relay-agent-information",
+	85:  "nds-servers",
+	86:  "nds-tree-name",
+	87:  "nds-context",
+	91:  "client-last-transaction-time",
+	92:  "associated-ip",
+	93:  "pxe-system-type",
+	94:  "pxe-interface-id",
+	97:  "pxe-client-id",
+	98:  "uap-servers",
+	100: "pcode",
+	101: "tcode",
+	112: "netinfo-server-address",
+	113: "netinfo-server-tag",
+	114: "default-url",
+	116: "auto-config",
+	117: "name-service-search",
+	118: "subnet-selection",
+	119: "domain-search",
+	121: "rfc3442-classless-static-routes",
+	145: "forcerenew-nonce-capable",
+	249: "ms-classless-static-routes",
+}
+
+// dhcpdTextOptions are the options of the text type, which are always quoted.
+var dhcpdTextOptions = map[Code]bool{
+	12: true, 14: true, 15: true, 17: true, 18: true, 40: true, 47: true,
+	56: true, 62: true, 64: true, 66: true, 67: true, 86: true, 87: true,
+	98: true, 100: true, 101: true, 113: true, 114: true,
+}
+
+// dhcpdByteArrayOptions are the options of arrays of unsigned integer 8, whose data are not typed by dhop.
+var dhcpdByteArrayOptions = map[Code]bool{
+	55:  true,
+	145: true,
+}
+
+// dhcpdCustomOptions are not defined by dhcpd by default, so their definitions are marshaled with them.
+var dhcpdCustomOptions = map[Code]bool{
+	121: true,
+	249: true,
+}
+
+// DhcpdName returns the option name of ISC dhcpd, or "unknown-N" for undefined codes.
+func DhcpdName(code Code) string {
+	if name, ok := dhcpdOptionNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown-%d", code)
+}
+
+// DhcpdDefinition is an option definition such as `option foo code 224 = text;`.
+type DhcpdDefinition struct {
+	Space string
+	Name  string
+	Code  Code
+	Type  string
+}
+
+func (d *DhcpdDefinition) String() string {
+	name := d.Name
+	if d.Space != "" && d.Space != "dhcp" {
+		name = d.Space + "." + name
+	}
+	return fmt.Sprintf("option %s code %d = %s;", name, d.Code, d.Type)
+}
+
+// DhcpdConfig is the option statements in dhcpd.conf.
+// Options in the dhcp space are collected into Options regardless of their scope.
+// Options in other spaces are collected into SpaceOptions, and also encapsulated
+// into Options by `vendor-option-space` or options of the encapsulate type.
+// Warnings are the scopes whose options are flattened into Options, and the spaces which are not encapsulated.
+type DhcpdConfig struct {
+	Spaces       []string
+	Definitions  []DhcpdDefinition
+	Options      []Option
+	SpaceOptions map[string][]SubOption
+	Warnings     []string
+}
+
+func dhcpdTypeOf(code Code) dhcpdType {
+	var s string
+	switch NewOptionData(byte(code)).(type) {
+	case *IPv4:
+		s = "ip-address"
+	case *IPv4s:
+		s = "array of ip-address"
+	case *IPv4Pair:
+		s = "{ ip-address, ip-address }"
+	case *IPv4Pairs:
+		s = "array of { ip-address, ip-address }"
+	case *Boolean:
+		s = "boolean"
+	case *Byte:
+		s = "unsigned integer 8"
+	case *Size:
+		s = "unsigned integer 16"
+	case *Sizes:
+		s = "array of unsigned integer 16"
+	case *TimeDuration:
+		s = "unsigned integer 32"
+	case *TimeOffset:
+		s = "signed integer 32"
+	case *Routes:
+		s = "array of unsigned integer 8"
+	case *DomainNames:
+		s = "domain-list"
+	default:
+		switch {
+		case dhcpdTextOptions[code]:
+			s = "text"
+		case dhcpdByteArrayOptions[code]:
+			s = "array of unsigned integer 8"
+		default:
+			s = "string"
+		}
+	}
+	t, _ := parseDhcpdType(tokenizeWords(s))
+	return t
+}
+
+// MarshalDhcpdOption returns the statement of the option such as `option routers 10.0.0.1;`.
+// Options which dhcpd does not define by default are preceded by their definitions.
+func MarshalDhcpdOption(o Option) (string, error) {
+	switch o.Code {
+	case 0, 255:
+		return "", fmt.Errorf("%s cannot be configured", o.Code.String())
+	}
+	t := dhcpdTypeOf(o.Code)
+	value, err := t.format(o.Encode())
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", DhcpdName(o.Code), err)
+	}
+	s := fmt.Sprintf("option %s %s;", DhcpdName(o.Code), value)
+	if _, ok := dhcpdOptionNames[o.Code]; !ok || dhcpdCustomOptions[o.Code] {
+		d := DhcpdDefinition{
+			Name: DhcpdName(o.Code),
+			Code: o.Code,
+			Type: t.String(),
+		}
+		s = d.String() + "\n" + s
+	}
+	return s, nil
+}
+
+// MarshalDhcpd returns the statements of the options, one per line.
+func MarshalDhcpd(options []Option) ([]byte, error) {
+	buf := bytes.Buffer{}
+	defined := make(map[Code]bool)
+	for _, o := range options {
+		s, err := MarshalDhcpdOption(o)
+		if err != nil {
+			return nil, err
+		}
+		if defined[o.Code] {
+			s = s[strings.LastIndex(s, "\n")+1:]
+		}
+		defined[o.Code] = true
+		buf.WriteString(s)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalDhcpd parses `option` statements in dhcpd.conf and ignores the other statements.
+func UnmarshalDhcpd(b []byte) (*DhcpdConfig, error) {
+	tokens, err := tokenizeDhcpd(b)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := parseDhcpdStatements(tokens)
+	if err != nil {
+		return nil, err
+	}
+	p := newDhcpdParser()
+	if err := p.parse(statements); err != nil {
+		return nil, err
+	}
+	return p.config()
+}
+
+type dhcpdOptionType struct {
+	code Code
+	typ  dhcpdType
+}
+
+type dhcpdParser struct {
+	cfg          DhcpdConfig
+	types        map[string]dhcpdOptionType
+	encapsulated map[string]Code
+	order        []string
+	// scopes are the heads of the blocks of the current statement.
+	scopes    []string
+	flattened map[string]bool
+}
+
+func newDhcpdParser() *dhcpdParser {
+	p := &dhcpdParser{
+		cfg: DhcpdConfig{
+			SpaceOptions: make(map[string][]SubOption),
+		},
+		types:        make(map[string]dhcpdOptionType),
+		encapsulated: make(map[string]Code),
+		flattened:    make(map[string]bool),
+	}
+	for code, name := range dhcpdOptionNames {
+		p.types["dhcp."+name] = dhcpdOptionType{code: code, typ: dhcpdTypeOf(code)}
+	}
+	for i := 1; i < 255; i++ {
+		code := Code(i)
+		p.types[fmt.Sprintf("dhcp.unknown-%d", i)] = dhcpdOptionType{code: code, typ: dhcpdTypeOf(code)}
+	}
+	p.types["agent.circuit-id"] = dhcpdOptionType{code: 1, typ: dhcpdType{fields: []dhcpdField{{kind: "string"}}}}
+	p.types["agent.remote-id"] = dhcpdOptionType{code: 2, typ: dhcpdType{fields: []dhcpdField{{kind: "string"}}}}
+	p.encapsulated["agent"] = 82
+	return p
+}
+
+func qualifiedDhcpdName(name string) (string, string) {
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "dhcp", name
+}
+
+func (p *dhcpdParser) parse(statements []dhcpdStatement) error {
+	for _, s := range statements {
+		if s.block != nil {
+			head := make([]string, len(s.tokens))
+			for i, t := range s.tokens {
+				head[i] = t.text
+			}
+			p.scopes = append(p.scopes, strings.Join(head, " "))
+			err := p.parse(s.block)
+			p.scopes = p.scopes[:len(p.scopes)-1]
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if len(s.tokens) == 0 {
+			continue
+		}
+		var err error
+		switch s.tokens[0].text {
+		case "option":
+			err = p.parseOption(s.tokens[1:])
+		case "vendor-option-space":
+			if len(s.tokens) == 2 {
+				p.encapsulated[s.tokens[1].text] = 43
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s", s.tokens[0].line, err)
+		}
+	}
+	return nil
+}
+
+func (p *dhcpdParser) parseOption(tokens []dhcpdToken) error {
+	if len(tokens) == 0 {
+		return fmt.Errorf("missing option name")
+	}
+	if tokens[0].text == "space" && !tokens[0].quoted {
+		if len(tokens) < 2 {
+			return fmt.Errorf("missing option space name")
+		}
+		p.cfg.Spaces = append(p.cfg.Spaces, tokens[1].text)
+		return nil
+	}
+	space, name := qualifiedDhcpdName(tokens[0].text)
+	if len(tokens) >= 4 && tokens[1].text == "code" && tokens[3].text == "=" {
+		n, err := strconv.ParseUint(tokens[2].text, 10, 8)
+		if err != nil {
+			return err
+		}
+		t, err := parseDhcpdType(tokens[4:])
+		if err != nil {
+			return err
+		}
+		d := DhcpdDefinition{
+			Space: space,
+			Name:  name,
+			Code:  Code(n),
+			Type:  t.String(),
+		}
+		p.cfg.Definitions = append(p.cfg.Definitions, d)
+		p.types[space+"."+name] = dhcpdOptionType{code: d.Code, typ: t}
+		if len(t.fields) == 1 && t.fields[0].kind == "encapsulate" && space == "dhcp" {
+			p.encapsulated[t.fields[0].space] = d.Code
+		}
+		return nil
+	}
+	ot, ok := p.types[space+"."+name]
+	if !ok {
+		return fmt.Errorf("unknown option \"%s\"", tokens[0].text)
+	}
+	data, err := ot.typ.encode(tokens[1:])
+	if err != nil {
+		return fmt.Errorf("%s: %s", tokens[0].text, err)
+	}
+	if len(p.scopes) > 0 {
+		scope := strings.Join(p.scopes, " > ")
+		if !p.flattened[scope] {
+			p.flattened[scope] = true
+			p.cfg.Warnings = append(p.cfg.Warnings, fmt.Sprintf("options in scope \"%s\" are flattened", scope))
+		}
+	}
+	if space != "dhcp" {
+		if _, ok := p.cfg.SpaceOptions[space]; !ok {
+			p.order = append(p.order, space)
+		}
+		p.cfg.SpaceOptions[space] = append(p.cfg.SpaceOptions[space], SubOption{Code: byte(ot.code), Data: data})
+		return nil
+	}
+	o, err := Decode(byte(ot.code), data)
+	if err != nil {
+		return fmt.Errorf("%s: %s", tokens[0].text, err)
+	}
+	p.cfg.Options = append(p.cfg.Options, o)
+	return nil
+}
+
+func (p *dhcpdParser) config() (*DhcpdConfig, error) {
+	for _, space := range p.order {
+		code, ok := p.encapsulated[space]
+		if !ok {
+			p.cfg.Warnings = append(p.cfg.Warnings, fmt.Sprintf(
+				"%d options in space \"%s\" are dropped because no option encapsulates the space",
+				len(p.cfg.SpaceOptions[space]), space,
+			))
+			continue
+		}
+		o, err := Decode(byte(code), EncodeSubOptions(p.cfg.SpaceOptions[space]))
+		if err != nil {
+			return nil, err
+		}
+		p.cfg.Options = append(p.cfg.Options, o)
+	}
+	return &p.cfg, nil
+}
+
+type dhcpdField struct {
+	kind   string
+	bits   int
+	signed bool
+	space  string
+}
+
+// dhcpdType is an option type of dhcpd such as `array of ip-address`.
+type dhcpdType struct {
+	array  bool
+	fields []dhcpdField
+}
+
+func (f *dhcpdField) String() string {
+	switch f.kind {
+	case "integer":
+		if f.signed {
+			return fmt.Sprintf("signed integer %d", f.bits)
+		}
+		return fmt.Sprintf("unsigned integer %d", f.bits)
+	case "encapsulate":
+		return "encapsulate " + f.space
+	}
+	return f.kind
+}
+
+func (t *dhcpdType) String() string {
+	s := make([]string, len(t.fields))
+	for i, f := range t.fields {
+		s[i] = f.String()
+	}
+	v := s[0]
+	if len(s) > 1 {
+		v = "{ " + strings.Join(s, ", ") + " }"
+	}
+	if t.array {
+		return "array of " + v
+	}
+	return v
+}
+
+func parseDhcpdType(tokens []dhcpdToken) (dhcpdType, error) {
+	t := dhcpdType{}
+	words := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if token.text != "," {
+			words = append(words, token.text)
+		}
+	}
+	if len(words) >= 2 && words[0] == "array" && words[1] == "of" {
+		t.array = true
+		words = words[2:]
+	}
+	if len(words) > 0 && words[0] == "{" {
+		if words[len(words)-1] != "}" {
+			return t, fmt.Errorf("unterminated record type")
+		}
+		words = words[1 : len(words)-1]
+	}
+	for len(words) > 0 {
+		f := dhcpdField{}
+		explicit := false
+		switch words[0] {
+		case "signed", "unsigned":
+			f.signed = words[0] == "signed"
+			explicit = true
+			words = words[1:]
+			if len(words) == 0 || words[0] != "integer" {
+				return t, fmt.Errorf("invalid option type")
+			}
+			fallthrough
+		case "integer":
+			if len(words) < 2 {
+				return t, fmt.Errorf("missing integer width")
+			}
+			n, err := strconv.Atoi(words[1])
+			if err != nil || (n != 8 && n != 16 && n != 32) {
+				return t, fmt.Errorf("invalid integer width \"%s\"", words[1])
+			}
+			f.kind = "integer"
+			f.bits = n
+			if !explicit {
+				f.signed = true
+			}
+			words = words[2:]
+		case "domain-list":
+			f.kind = "domain-list"
+			words = words[1:]
+			if len(words) > 0 && words[0] == "compressed" {
+				words = words[1:]
+			}
+		case "encapsulate":
+			if len(words) < 2 {
+				return t, fmt.Errorf("missing option space")
+			}
+			f.kind = "encapsulate"
+			f.space = words[1]
+			words = words[2:]
+		case "boolean", "ip-address", "ip6-address", "text", "string":
+			f.kind = words[0]
+			words = words[1:]
+		default:
+			return t, fmt.Errorf("unsupported option type \"%s\"", words[0])
+		}
+		t.fields = append(t.fields, f)
+	}
+	if len(t.fields) == 0 {
+		return t, fmt.Errorf("missing option type")
+	}
+	return t, nil
+}
+
+// encode converts the values of a statement into the wire format.
+func (t *dhcpdType) encode(tokens []dhcpdToken) ([]byte, error) {
+	values := make([]dhcpdToken, 0, len(tokens))
+	for _, token := range tokens {
+		if token.quoted || token.text != "," {
+			values = append(values, token)
+		}
+	}
+	b := make([]byte, 0, 16)
+	for {
+		for _, f := range t.fields {
+			if f.kind == "domain-list" {
+				dns := DomainNames{}
+				for _, v := range values {
+					a := DomainNames{}
+					if err := a.Unmarshal([]byte(v.text)); err != nil {
+						return nil, err
+					}
+					dns = append(dns, a...)
+				}
+				values = nil
+				b = append(b, dns.Encode()...)
+				continue
+			}
+			if len(values) == 0 {
+				return nil, fmt.Errorf("missing value of %s", f.String())
+			}
+			data, err := f.encode(values[0])
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, data...)
+			values = values[1:]
+		}
+		if !t.array || len(values) == 0 {
+			break
+		}
+	}
+	if len(values) > 0 {
+		return nil, fmt.Errorf("too many values")
+	}
+	return b, nil
+}
+
+func (f *dhcpdField) encode(v dhcpdToken) ([]byte, error) {
+	switch f.kind {
+	case "boolean":
+		switch strings.ToLower(v.text) {
+		case "true", "on":
+			return []byte{1}, nil
+		case "false", "off":
+			return []byte{0}, nil
+		}
+		return nil, fmt.Errorf("invalid boolean \"%s\"", v.text)
+	case "integer":
+		var (
+			n   uint64
+			err error
+		)
+		if f.signed && strings.HasPrefix(v.text, "-") {
+			var i int64
+			i, err = strconv.ParseInt(v.text, 0, f.bits)
+			n = uint64(i)
+		} else {
+			n, err = strconv.ParseUint(v.text, 0, f.bits)
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, f.bits/8)
+		for i := range b {
+			b[len(b)-1-i] = byte(n >> uint(8*i))
+		}
+		return b, nil
+	case "ip-address":
+		ip := net.ParseIP(v.text).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address \"%s\"", v.text)
+		}
+		return []byte(ip), nil
+	case "ip6-address":
+		ip := net.ParseIP(v.text)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv6 address \"%s\"", v.text)
+		}
+		return []byte(ip.To16()), nil
+	case "text":
+		return []byte(v.text), nil
+	case "string":
+		if v.quoted {
+			return []byte(v.text), nil
+		}
+		return parseColonHex(v.text)
+	}
+	return nil, fmt.Errorf("%s cannot be configured directly", f.String())
+}
+
+// format converts the data in the wire format into the values of a statement.
+func (t *dhcpdType) format(b []byte) (string, error) {
+	elements := make([]string, 0, 4)
+	for {
+		fields := make([]string, 0, len(t.fields))
+		for _, f := range t.fields {
+			s, n, err := f.format(b)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, s)
+			b = b[n:]
+		}
+		elements = append(elements, strings.Join(fields, " "))
+		if !t.array || len(b) == 0 {
+			break
+		}
+	}
+	if len(b) > 0 {
+		return "", fmt.Errorf("%d bytes of trailing data", len(b))
+	}
+	return strings.Join(elements, ", "), nil
+}
+
+func (f *dhcpdField) format(b []byte) (string, int, error) {
+	size := 0
+	switch f.kind {
+	case "boolean":
+		size = 1
+	case "integer":
+		size = f.bits / 8
+	case "ip-address":
+		size = 4
+	case "ip6-address":
+		size = 16
+	}
+	if len(b) < size {
+		return "", 0, fmt.Errorf("too short data for %s", f.String())
+	}
+	switch f.kind {
+	case "boolean":
+		switch b[0] {
+		case 0:
+			return "false", 1, nil
+		case 1:
+			return "true", 1, nil
+		}
+		return "", 0, fmt.Errorf("invalid boolean %d", b[0])
+	case "integer":
+		var n uint64
+		for _, c := range b[:size] {
+			n = n<<8 | uint64(c)
+		}
+		if f.signed {
+			shift := uint(64 - f.bits)
+			return strconv.FormatInt(int64(n<<shift)>>shift, 10), size, nil
+		}
+		return strconv.FormatUint(n, 10), size, nil
+	case "ip-address", "ip6-address":
+		return net.IP(b[:size]).String(), size, nil
+	case "text":
+		return quoteDhcpd(b), len(b), nil
+	case "string":
+		if isPrintable(b) {
+			return quoteDhcpd(b), len(b), nil
+		}
+		return formatColonHex(b), len(b), nil
+	case "domain-list":
+		dns := DomainNames{}
+		if err := dns.Decode(b); err != nil {
+			return "", 0, err
+		}
+		s := make([]string, len(dns))
+		for i, dn := range dns {
+			s[i] = quoteDhcpd(dn.Marshal())
+		}
+		return strings.Join(s, ", "), len(b), nil
+	}
+	return "", 0, fmt.Errorf("%s cannot be configured directly", f.String())
+}
+
+func isPrintable(b []byte) bool {
+	if len(b) == 0 {
+		return true
+	}
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteDhcpd(b []byte) string {
+	buf := bytes.Buffer{}
+	buf.WriteByte('"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&buf, "\\%03o", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func formatColonHex(b []byte) string {
+	s := make([]string, len(b))
+	for i, c := range b {
+		s[i] = fmt.Sprintf("%02x", c)
+	}
+	return strings.Join(s, ":")
+}
+
+func parseColonHex(s string) ([]byte, error) {
+	a := strings.Split(s, ":")
+	b := make([]byte, len(a))
+	for i, h := range a {
+		n, err := strconv.ParseUint(h, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid colon separated hex \"%s\"", s)
+		}
+		b[i] = byte(n)
+	}
+	return b, nil
+}
+
+type dhcpdToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+func tokenizeWords(s string) []dhcpdToken {
+	tokens, _ := tokenizeDhcpd([]byte(s))
+	return tokens
+}
+
+// tokenizeDhcpd splits the text of dhcpd.conf or lease files into tokens.
+func tokenizeDhcpd(b []byte) ([]dhcpdToken, error) {
+	tokens := make([]dhcpdToken, 0, 64)
+	line := 1
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+		case c == ';' || c == '{' || c == '}' || c == ',' || c == '=':
+			tokens = append(tokens, dhcpdToken{text: string(c), line: line})
+			i++
+		case c == '"':
+			buf := bytes.Buffer{}
+			start := line
+			i++
+			for {
+				if i >= len(b) {
+					return nil, fmt.Errorf("line %d: unterminated string", start)
+				}
+				c := b[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\n' {
+					line++
+				}
+				if c != '\\' || i+1 >= len(b) {
+					buf.WriteByte(c)
+					i++
+					continue
+				}
+				i++
+				switch e := b[i]; {
+				case '0' <= e && e <= '7':
+					n := 0
+					j := 0
+					for ; j < 3 && i < len(b) && '0' <= b[i] && b[i] <= '7'; j++ {
+						n = n<<3 | int(b[i]-'0')
+						i++
+					}
+					buf.WriteByte(byte(n))
+				case e == 'n':
+					buf.WriteByte('\n')
+					i++
+				case e == 't':
+					buf.WriteByte('\t')
+					i++
+				case e == 'r':
+					buf.WriteByte('\r')
+					i++
+				default:
+					buf.WriteByte(e)
+					i++
+				}
+			}
+			tokens = append(tokens, dhcpdToken{text: buf.String(), quoted: true, line: start})
+		default:
+			start := i
+			for i < len(b) && !bytes.ContainsAny(b[i:i+1], " \t\r\n#;{},=\"") {
+				i++
+			}
+			tokens = append(tokens, dhcpdToken{text: string(b[start:i]), line: line})
+		}
+	}
+	return tokens, nil
+}
+
+// dhcpdStatement is a statement terminated by a semicolon, or a block whose head is tokens.
+type dhcpdStatement struct {
+	tokens []dhcpdToken
+	block  []dhcpdStatement
+}
+
+func parseDhcpdStatements(tokens []dhcpdToken) ([]dhcpdStatement, error) {
+	statements, rest, err := parseDhcpdBlock(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("line %d: unexpected \"}\"", rest[0].line)
+	}
+	return statements, nil
+}
+
+func parseDhcpdBlock(tokens []dhcpdToken) ([]dhcpdStatement, []dhcpdToken, error) {
+	statements := make([]dhcpdStatement, 0, 8)
+	current := make([]dhcpdToken, 0, 8)
+	for len(tokens) > 0 {
+		t := tokens[0]
+		tokens = tokens[1:]
+		if t.quoted {
+			current = append(current, t)
+			continue
+		}
+		switch t.text {
+		case ";":
+			statements = append(statements, dhcpdStatement{tokens: current})
+			current = make([]dhcpdToken, 0, 8)
+		case "{":
+			if isDhcpdDefinition(current) {
+				current = append(current, t)
+				continue
+			}
+			block, rest, err := parseDhcpdBlock(tokens)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rest) == 0 {
+				return nil, nil, fmt.Errorf("line %d: unterminated block", t.line)
+			}
+			statements = append(statements, dhcpdStatement{tokens: current, block: block})
+			current = make([]dhcpdToken, 0, 8)
+			tokens = rest[1:]
+		case "}":
+			if isDhcpdDefinition(current) {
+				current = append(current, t)
+				continue
+			}
+			if len(current) > 0 {
+				return nil, nil, fmt.Errorf("line %d: missing \";\"", t.line)
+			}
+			return statements, append([]dhcpdToken{t}, tokens...), nil
+		default:
+			current = append(current, t)
+		}
+	}
+	if len(current) > 0 {
+		return nil, nil, fmt.Errorf("line %d: missing \";\"", current[len(current)-1].line)
+	}
+	return statements, nil, nil
+}
+
+func isDhcpdDefinition(tokens []dhcpdToken) bool {
+	if len(tokens) == 0 || tokens[0].text != "option" {
+		return false
+	}
+	for _, t := range tokens {
+		if t.text == "=" && !t.quoted {
+			return true
+		}
+	}
+	return false
+}
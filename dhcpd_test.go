@@ -0,0 +1,157 @@
+package dhop
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var (
+	dhcpdConfig = []byte(`# example
+option space pxelinux;
+option pxelinux.magic code 208 = string;
+option pxelinux.reboot-time code 211 = unsigned integer 32;
+option rfc3442-classless-static-routes code 121 = array of integer 8;
+option foo code 224 = { ip-address, text };
+vendor-option-space pxelinux;
+
+subnet 10.0.0.0 netmask 255.255.255.0 {
+	range 10.0.0.100 10.0.0.200;
+	option routers 10.0.0.1;
+	option domain-name-servers 10.0.0.2, 10.0.0.3;
+	option domain-name "example.com";
+	option domain-search "example.com", "example.net";
+	option dhcp-lease-time 3600;
+	option ip-forwarding off;
+	option dhcp-client-identifier 01:00:11:22:33:44:55;
+	option rfc3442-classless-static-routes 24, 192, 168, 100, 10, 0, 0, 1;
+	option foo 10.0.0.4 "bar";
+	option pxelinux.magic f1:00:74:7e;
+	option pxelinux.reboot-time 30;
+}
+`)
+	dhcpdOptions = []string{
+		"option routers 10.0.0.1;",
+		"option domain-name-servers 10.0.0.2, 10.0.0.3;",
+		"option domain-name \"example.com\";",
+		"option domain-search \"example.com\", \"example.net\";",
+		"option dhcp-lease-time 3600;",
+		"option ip-forwarding false;",
+		"option dhcp-client-identifier 01:00:11:22:33:44:55;",
+		"option rfc3442-classless-static-routes code 121 = array of unsigned integer 8;\noption rfc3442-classless-static-routes 24, 192, 168, 100, 10, 0, 0, 1;",
+		"option unknown-224 code 224 = string;\noption unknown-224 0a:00:00:04:62:61:72;",
+		"option vendor-encapsulated-options d0:04:f1:00:74:7e:d3:04:00:00:00:1e;",
+	}
+)
+
+func TestUnmarshalDhcpd(t *testing.T) {
+	cfg, err := UnmarshalDhcpd(dhcpdConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Spaces) != 1 || cfg.Spaces[0] != "pxelinux" || len(cfg.Definitions) != 4 {
+		t.Error(cfg.Spaces, cfg.Definitions)
+	}
+	if cfg.Definitions[2].Type != "array of signed integer 8" {
+		t.Error(cfg.Definitions[2].String())
+	}
+	if len(cfg.SpaceOptions["pxelinux"]) != 2 {
+		t.Error(cfg.SpaceOptions)
+	}
+	if len(cfg.Options) != len(dhcpdOptions) {
+		t.Fatal(cfg.Options)
+	}
+	for i, o := range cfg.Options {
+		s, err := MarshalDhcpdOption(o)
+		if err != nil {
+			t.Error(err)
+		}
+		if s != dhcpdOptions[i] {
+			t.Error(s)
+		}
+	}
+	if string(cfg.Options[7].Marshal()) != "192.168.100.0/24 10.0.0.1" {
+		t.Error(string(cfg.Options[7].Marshal()))
+	}
+}
+
+func TestUnmarshalDhcpdError(t *testing.T) {
+	for _, s := range []string{
+		`option routers 10.0.0.1`,
+		`option unknown-option 1;`,
+		`option routers "example.com";`,
+		`option domain-name "unterminated;`,
+		`subnet 10.0.0.0 netmask 255.255.255.0 {`,
+		`option foo code 224 = record;`,
+	} {
+		if _, err := UnmarshalDhcpd([]byte(s)); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestMarshalDhcpd(t *testing.T) {
+	ip := IPv4(ipBytes)
+	s := String("a \"b\"\n")
+	b, err := MarshalDhcpd([]Option{
+		{OptionData: &ip, Code: 1},
+		{OptionData: &s, Code: 15},
+		{OptionData: &s, Code: 230},
+		{OptionData: &s, Code: 230},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte(`option subnet-mask 192.168.100.1;
+option domain-name "a \"b\"\012";
+option unknown-230 code 230 = string;
+option unknown-230 61:20:22:62:22:0a;
+option unknown-230 61:20:22:62:22:0a;
+`)
+	if bytes.Compare(b, expected) != 0 {
+		t.Error(string(b))
+	}
+	if _, err := MarshalDhcpdOption(Option{OptionData: &End{}, Code: 255}); err == nil {
+		t.Error()
+	}
+}
+
+func TestUnmarshalDhcpdWarnings(t *testing.T) {
+	cfg, err := UnmarshalDhcpd([]byte(`
+option space foo;
+option foo.bar code 1 = text;
+option foo.bar "x";
+option domain-name "example.com";
+subnet 10.0.0.0 netmask 255.255.255.0 {
+  option routers 10.0.0.1;
+  pool {
+    option routers 10.0.0.2;
+  }
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`options in scope "subnet 10.0.0.0 netmask 255.255.255.0" are flattened`,
+		`options in scope "subnet 10.0.0.0 netmask 255.255.255.0 > pool" are flattened`,
+		`1 options in space "foo" are dropped because no option encapsulates the space`,
+	}
+	if strings.Join(cfg.Warnings, "\n") != strings.Join(expected, "\n") {
+		t.Error(cfg.Warnings)
+	}
+}
+
+func TestDhcpdParameterRequestList(t *testing.T) {
+	cfg, err := UnmarshalDhcpd([]byte(`option dhcp-parameter-request-list 1,3,6;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Options) != 1 || bytes.Compare(cfg.Options[0].Encode(), []byte{1, 3, 6}) != 0 {
+		t.Fatal(cfg.Options)
+	}
+	s, err := MarshalDhcpdOption(cfg.Options[0])
+	if err != nil || s != "option dhcp-parameter-request-list 1, 3, 6;" {
+		t.Error(s, err)
+	}
+}
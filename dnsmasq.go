@@ -0,0 +1,605 @@
+package dhop
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// dnsmasqOptionNames are the names listed by `dnsmasq --help dhcp`.
+var dnsmasqOptionNames = map[Code]string{
+	1:   "netmask",
+	2:   "time-offset",
+	3:   "router",
+	6:   "dns-server",
+	7:   "log-server",
+	9:   "lpr-server",
+	13:  "boot-file-size",
+	15:  "domain-name",
+	16:  "swap-server",
+	17:  "root-path",
+	18:  "extension-path",
+	19:  "ip-forward-enable",
+	20:  "non-local-source-routing",
+	21:  "policy-filter",
+	22:  "max-datagram-reassembly",
+	23:  "default-ttl",
+	26:  "mtu",
+	27:  "all-subnets-local",
+	31:  "router-discovery",
+	32:  "router-solicitation",
+	33:  "static-route",
+	34:  "trailer-encapsulation",
+	35:  "arp-timeout",
+	36:  "ethernet-encap",
+	37:  "tcp-ttl",
+	38:  "tcp-keepalive",
+	40:  "nis-domain",
+	41:  "nis-server",
+	42:  "ntp-server",
+	44:  "netbios-ns",
+	45:  "netbios-dd",
+	46:  "netbios-nodetype",
+	47:  "netbios-scope",
+	48:  "x-windows-fs",
+	49:  "x-windows-dm",
+	58:  "T1",
+	59:  "T2",
+	60:  "vendor-class",
+	64:  "nis+-domain",
+	65:  "nis+-server",
+	66:  "tftp-server",
+	67:  "bootfile-name",
+	68:  "mobile-ip-home",
+	69:  "smtp-server",
+	70:  "pop3-server",
+	71:  "nntp-server",
+	74:  "irc-server",
+	77:  "user-class",
+	80:  "rapid-commit",
+	93:  "client-arch",
+	94:  "client-interface-id",
+	97:  "client-machine-id",
+	100: "posix-timezone",
+	101: "tzdb-timezone",
+	119: "domain-search",
+	120: "sip-server",
+	121: "classless-static-route",
+	125: "vendor-id-encap",
+	150: "tftp-server-address",
+}
+
+// DnsmasqOption is a `dhcp-option` or `dhcp-option-force` line of dnsmasq.
+// Option is a sub-option of Encap or VIEncap if either is not 0,
+// and its data is String because dnsmasq guesses the type of sub-options.
+type DnsmasqOption struct {
+	Force   bool
+	Tags    []string
+	Encap   Code
+	VIEncap uint32
+	Vendor  string
+	Option  Option
+}
+
+// DnsmasqConfig is the `dhcp-option` lines of dnsmasq.conf.
+// Options has the options of Lines, and encapsulates the sub-options into
+// Vendor Specific Information or V-I Vendor-Specific Information options.
+type DnsmasqConfig struct {
+	Lines   []DnsmasqOption
+	Options []Option
+}
+
+// MarshalDnsmasqOption returns the line of the option such as `dhcp-option=option:router,10.0.0.1`.
+func MarshalDnsmasqOption(o Option) (string, error) {
+	return DnsmasqOption{Option: o}.Marshal()
+}
+
+// Marshal returns the line such as `dhcp-option=tag:lan,option:router,10.0.0.1` or `dhcp-option=encap:175,190,"user"`.
+// The data of sub-options is written as a quoted string or colon-separated hex.
+func (o DnsmasqOption) Marshal() (string, error) {
+	encapsulated := o.Encap != 0 || o.VIEncap != 0 || o.Vendor != ""
+	switch o.Option.Code {
+	case 0, 255:
+		if !encapsulated {
+			return "", fmt.Errorf("%s cannot be configured", o.Option.Code.String())
+		}
+	}
+	line := "dhcp-option="
+	if o.Force {
+		line = "dhcp-option-force="
+	}
+	fields := make([]string, 0, len(o.Tags)+3)
+	for _, t := range o.Tags {
+		fields = append(fields, "tag:"+t)
+	}
+	key := strconv.Itoa(int(o.Option.Code))
+	switch {
+	case o.Encap != 0:
+		fields = append(fields, fmt.Sprintf("encap:%d", o.Encap))
+	case o.VIEncap != 0:
+		fields = append(fields, fmt.Sprintf("vi-encap:%d", o.VIEncap))
+	case o.Vendor != "":
+		fields = append(fields, "vendor:"+o.Vendor)
+	}
+	var values []string
+	if encapsulated {
+		// Sub-options have no names and types in dnsmasq.
+		if o.Option.OptionData != nil {
+			values = []string{formatDnsmasqString(o.Option.Encode(), false)}
+		}
+	} else {
+		if name, ok := dnsmasqOptionNames[o.Option.Code]; ok {
+			key = "option:" + name
+		}
+		var err error
+		values, err = dnsmasqValues(o.Option)
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", key, err)
+		}
+	}
+	fields = append(fields, key)
+	fields = append(fields, values...)
+	return line + strings.Join(fields, ","), nil
+}
+
+// MarshalDnsmasq returns the lines of the options.
+// The sub-options in the options of dnsmasqEncapCodes and V-I Vendor-Specific Information
+// are written as `encap:` and `vi-encap:` lines.
+func MarshalDnsmasq(options []Option) ([]byte, error) {
+	return MarshalDnsmasqLines(dnsmasqLines(options))
+}
+
+// MarshalDnsmasqLines returns the lines with their tags and encapsulations, such as the Lines of DnsmasqConfig.
+func MarshalDnsmasqLines(lines []DnsmasqOption) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for _, l := range lines {
+		s, err := l.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(s)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// dnsmasqEncapCodes are the options whose sub-options are written as `encap:` lines.
+// 175 is the Etherboot option in the example of the dnsmasq manual.
+var dnsmasqEncapCodes = map[Code]bool{43: true, 175: true}
+
+// dnsmasqLines splits the options into lines as encapsulateDnsmasq joins them.
+// Options whose data is not a list of sub-options are kept in one line.
+func dnsmasqLines(options []Option) []DnsmasqOption {
+	lines := make([]DnsmasqOption, 0, len(options))
+	for _, o := range options {
+		var sub []DnsmasqOption
+		switch {
+		case o.Code == 125:
+			sub = dnsmasqVIEncapLines(o.Encode())
+		case dnsmasqEncapCodes[o.Code]:
+			for _, s := range dnsmasqSubOptions(o.Encode()) {
+				sub = append(sub, DnsmasqOption{Encap: o.Code, Option: s})
+			}
+		}
+		if len(sub) == 0 {
+			sub = []DnsmasqOption{{Option: o}}
+		}
+		lines = append(lines, sub...)
+	}
+	return lines
+}
+
+func dnsmasqVIEncapLines(b []byte) []DnsmasqOption {
+	lines := make([]DnsmasqOption, 0, 2)
+	for len(b) > 0 {
+		if len(b) < 5 || len(b) < 5+int(b[4]) {
+			return nil
+		}
+		enterprise := binary.BigEndian.Uint32(b[:4])
+		sub := dnsmasqSubOptions(b[5 : 5+int(b[4])])
+		if enterprise == 0 || sub == nil {
+			return nil
+		}
+		for _, s := range sub {
+			lines = append(lines, DnsmasqOption{VIEncap: enterprise, Option: s})
+		}
+		b = b[5+int(b[4]):]
+	}
+	return lines
+}
+
+// dnsmasqSubOptions returns the sub-options in b with String data, or nil if b has none.
+func dnsmasqSubOptions(b []byte) []Option {
+	subs, err := DecodeSubOptions(b)
+	if err != nil || len(subs) == 0 {
+		return nil
+	}
+	options := make([]Option, len(subs))
+	for i, s := range subs {
+		v := String(s.Data)
+		options[i] = Option{OptionData: &v, Code: Code(s.Code)}
+	}
+	return options
+}
+
+func dnsmasqValues(o Option) ([]string, error) {
+	switch v := o.OptionData.(type) {
+	case *IPv4:
+		return []string{net.IP(*v).String()}, nil
+	case *IPv4s:
+		s := make([]string, len(*v))
+		for i, ip := range *v {
+			s[i] = net.IP(ip).String()
+		}
+		return s, nil
+	case *IPv4Pair:
+		return []string{net.IP(v[0]).String(), net.IP(v[1]).String()}, nil
+	case *IPv4Pairs:
+		s := make([]string, 0, len(*v)*2)
+		for _, p := range *v {
+			s = append(s, net.IP(p[0]).String(), net.IP(p[1]).String())
+		}
+		return s, nil
+	case *Boolean:
+		if *v {
+			return []string{"1"}, nil
+		}
+		return []string{"0"}, nil
+	case *Byte:
+		return []string{strconv.Itoa(int(*v))}, nil
+	case *Size:
+		return []string{strconv.Itoa(int(*v))}, nil
+	case *Sizes:
+		s := make([]string, len(*v))
+		for i, n := range *v {
+			s[i] = strconv.Itoa(int(n))
+		}
+		return s, nil
+	case *TimeDuration, *TimeOffset:
+		b := o.Encode()
+		n := binary.BigEndian.Uint32(b)
+		if _, ok := v.(*TimeOffset); ok {
+			return []string{strconv.Itoa(int(int32(n)))}, nil
+		}
+		return []string{strconv.FormatUint(uint64(n), 10)}, nil
+	case *Routes:
+		s := make([]string, 0, len(*v)*2)
+		for _, r := range *v {
+			s = append(s, r.Source.String(), r.Destination.String())
+		}
+		return s, nil
+	case *DomainNames:
+		s := make([]string, len(*v))
+		for i, dn := range *v {
+			s[i] = string(dn.Marshal())
+		}
+		return s, nil
+	case *String:
+		return []string{formatDnsmasqString([]byte(*v), dhcpdTextOptions[o.Code])}, nil
+	}
+	return nil, fmt.Errorf("unsupported option data %T", o.OptionData)
+}
+
+// formatDnsmasqString quotes the strings of non-text options
+// so that dnsmasq does not guess they are addresses or integers.
+func formatDnsmasqString(b []byte, text bool) string {
+	if len(b) == 0 {
+		return `""`
+	}
+	if !isPrintable(b) || isColonHex(string(b)) {
+		return formatColonHex(b)
+	}
+	if !text || bytes.ContainsAny(b, ", \"\\#") {
+		return quoteDhcpd(b)
+	}
+	return string(b)
+}
+
+func isColonHex(s string) bool {
+	if !strings.Contains(s, ":") {
+		return false
+	}
+	_, err := parseColonHex(s)
+	return err == nil
+}
+
+// UnmarshalDnsmasq parses `dhcp-option` and `dhcp-option-force` lines and ignores the other lines.
+// The lines may be prefixed by "--" as command line arguments.
+func UnmarshalDnsmasq(b []byte) (*DnsmasqConfig, error) {
+	cfg := &DnsmasqConfig{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "--")
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		if key != "dhcp-option" && key != "dhcp-option-force" {
+			continue
+		}
+		o, err := parseDnsmasqOption(line[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		o.Force = key == "dhcp-option-force"
+		cfg.Lines = append(cfg.Lines, o)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	options, err := encapsulateDnsmasq(cfg.Lines)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Options = options
+	return cfg, nil
+}
+
+func parseDnsmasqOption(s string) (DnsmasqOption, error) {
+	o := DnsmasqOption{}
+	fields, quoted, err := splitDnsmasq(s)
+	if err != nil {
+		return o, err
+	}
+	for len(fields) > 0 {
+		f := fields[0]
+		switch {
+		case strings.HasPrefix(f, "tag:"):
+			o.Tags = append(o.Tags, f[4:])
+		case strings.HasPrefix(f, "net:"):
+			o.Tags = append(o.Tags, f[4:])
+		case strings.HasPrefix(f, "encap:"):
+			n, err := strconv.ParseUint(f[6:], 10, 8)
+			if err != nil {
+				return o, err
+			}
+			o.Encap = Code(n)
+		case strings.HasPrefix(f, "vi-encap:"):
+			n, err := strconv.ParseUint(f[9:], 10, 32)
+			if err != nil {
+				return o, err
+			}
+			o.VIEncap = uint32(n)
+		case strings.HasPrefix(f, "vendor:"):
+			o.Vendor = f[7:]
+		default:
+			code, err := parseDnsmasqCode(f)
+			if err != nil {
+				return o, err
+			}
+			values := fields[1:]
+			encapsulated := o.Encap != 0 || o.VIEncap != 0 || o.Vendor != ""
+			var data []byte
+			if encapsulated {
+				data, err = guessDnsmasqData(values, quoted)
+			} else {
+				data, err = encodeDnsmasqValues(code, values, quoted)
+			}
+			if err != nil {
+				return o, err
+			}
+			if encapsulated {
+				v := String(data)
+				o.Option = Option{OptionData: &v, Code: code}
+				return o, nil
+			}
+			o.Option, err = Decode(byte(code), data)
+			return o, err
+		}
+		fields = fields[1:]
+	}
+	return o, fmt.Errorf("missing option")
+}
+
+func parseDnsmasqCode(s string) (Code, error) {
+	if strings.HasPrefix(s, "option6:") {
+		return 0, fmt.Errorf("DHCPv6 option \"%s\" is not supported", s)
+	}
+	if strings.HasPrefix(s, "option:") {
+		name := s[7:]
+		if n, err := strconv.ParseUint(name, 10, 8); err == nil {
+			return Code(n), nil
+		}
+		for code, v := range dnsmasqOptionNames {
+			if strings.EqualFold(v, name) {
+				return code, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown option \"%s\"", name)
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid option \"%s\"", s)
+	}
+	return Code(n), nil
+}
+
+// splitDnsmasq splits the value by commas except in quoted strings.
+// It also reports whether any of the fields is quoted.
+func splitDnsmasq(s string) ([]string, bool, error) {
+	fields := make([]string, 0, 4)
+	buf := bytes.Buffer{}
+	quoting, quoted := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quoting && i+1 < len(s):
+			i++
+			buf.WriteByte(s[i])
+		case c == '"':
+			quoting = !quoting
+			quoted = true
+		case c == ',' && !quoting:
+			fields = append(fields, strings.TrimSpace(buf.String()))
+			buf.Reset()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	if quoting {
+		return nil, false, fmt.Errorf("unterminated string")
+	}
+	return append(fields, strings.TrimSpace(buf.String())), quoted, nil
+}
+
+func encodeDnsmasqValues(code Code, values []string, quoted bool) ([]byte, error) {
+	var b []byte
+	switch NewOptionData(byte(code)).(type) {
+	case *IPv4, *IPv4s, *IPv4Pair, *IPv4Pairs:
+		for _, v := range values {
+			ip := net.ParseIP(v).To4()
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IPv4 address \"%s\"", v)
+			}
+			b = append(b, ip...)
+		}
+	case *Boolean, *Byte:
+		return encodeDnsmasqIntegers(values, 8)
+	case *Size, *Sizes:
+		return encodeDnsmasqIntegers(values, 16)
+	case *TimeDuration, *TimeOffset:
+		return encodeDnsmasqIntegers(values, 32)
+	case *Routes:
+		if len(values)%2 != 0 {
+			return nil, fmt.Errorf("routes must be pairs of destination and gateway")
+		}
+		routes := make(Routes, 0, len(values)/2)
+		for i := 0; i < len(values); i += 2 {
+			r := Route{}
+			if err := r.Unmarshal([]byte(values[i] + " " + values[i+1])); err != nil {
+				return nil, err
+			}
+			routes = append(routes, r)
+		}
+		b = routes.Encode()
+	case *DomainNames:
+		dns := DomainNames{}
+		if err := dns.Unmarshal([]byte(strings.Join(values, ","))); err != nil {
+			return nil, err
+		}
+		b = dns.Encode()
+	default:
+		if !dhcpdTextOptions[code] {
+			return guessDnsmasqData(values, quoted)
+		}
+		b = []byte(strings.Join(values, ","))
+	}
+	return b, nil
+}
+
+func encodeDnsmasqIntegers(values []string, bits int) ([]byte, error) {
+	b := make([]byte, 0, len(values)*bits/8)
+	for _, v := range values {
+		var n uint64
+		if i, err := strconv.ParseInt(v, 0, bits); err == nil && i < 0 {
+			n = uint64(i)
+		} else {
+			u, err := strconv.ParseUint(v, 0, bits)
+			if err != nil {
+				return nil, err
+			}
+			n = u
+		}
+		for i := bits/8 - 1; i >= 0; i-- {
+			b = append(b, byte(n>>uint(8*i)))
+		}
+	}
+	return b, nil
+}
+
+// guessDnsmasqData encodes the values of a sub-option as dnsmasq does:
+// IPv4 addresses, colon separated hex, an integer in the smallest size, or a string.
+// Quoted values are always strings.
+func guessDnsmasqData(values []string, quoted bool) ([]byte, error) {
+	if len(values) == 0 {
+		return []byte{}, nil
+	}
+	if quoted {
+		return []byte(strings.Join(values, ",")), nil
+	}
+	ips := make([]byte, 0, len(values)*4)
+	for _, v := range values {
+		ip := net.ParseIP(v).To4()
+		if ip == nil {
+			ips = nil
+			break
+		}
+		ips = append(ips, ip...)
+	}
+	if ips != nil {
+		return ips, nil
+	}
+	s := strings.Join(values, ",")
+	if isColonHex(s) {
+		return parseColonHex(s)
+	}
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		switch {
+		case n <= 0xff:
+			return encodeDnsmasqIntegers(values, 8)
+		case n <= 0xffff:
+			return encodeDnsmasqIntegers(values, 16)
+		}
+		return encodeDnsmasqIntegers(values, 32)
+	}
+	return []byte(s), nil
+}
+
+func encapsulateDnsmasq(lines []DnsmasqOption) ([]Option, error) {
+	options := make([]Option, 0, len(lines))
+	encap := make(map[Code][]SubOption)
+	encapOrder := make([]Code, 0)
+	viEncap := make(map[uint32][]SubOption)
+	viEncapOrder := make([]uint32, 0)
+	for _, l := range lines {
+		sub := SubOption{Code: byte(l.Option.Code), Data: l.Option.Encode()}
+		switch {
+		case l.VIEncap != 0:
+			if _, ok := viEncap[l.VIEncap]; !ok {
+				viEncapOrder = append(viEncapOrder, l.VIEncap)
+			}
+			viEncap[l.VIEncap] = append(viEncap[l.VIEncap], sub)
+		case l.Encap != 0 || l.Vendor != "":
+			code := l.Encap
+			if code == 0 {
+				code = 43
+			}
+			if _, ok := encap[code]; !ok {
+				encapOrder = append(encapOrder, code)
+			}
+			encap[code] = append(encap[code], sub)
+		default:
+			options = append(options, l.Option)
+		}
+	}
+	for _, code := range encapOrder {
+		o, err := Decode(byte(code), EncodeSubOptions(encap[code]))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+	}
+	if len(viEncapOrder) > 0 {
+		b := make([]byte, 0, 16)
+		for _, enterprise := range viEncapOrder {
+			data := EncodeSubOptions(viEncap[enterprise])
+			var h [5]byte
+			binary.BigEndian.PutUint32(h[:4], enterprise)
+			h[4] = byte(len(data))
+			b = append(b, h[:]...)
+			b = append(b, data...)
+		}
+		o, err := Decode(125, b)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+	}
+	return options, nil
+}
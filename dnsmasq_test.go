@@ -0,0 +1,205 @@
+package dhop
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	dnsmasqConfig = []byte(`# example
+interface=eth0
+dhcp-range=10.0.0.100,10.0.0.200,12h
+dhcp-option=option:router,10.0.0.1
+dhcp-option=tag:lan,tag:!guest,6,10.0.0.2,10.0.0.3
+dhcp-option=option:domain-name,example.com
+dhcp-option=option:domain-search,example.com,example.net
+dhcp-option=51,3600
+dhcp-option=19,0
+dhcp-option=121,10.1.0.0/16,10.0.0.254
+dhcp-option-force=224,"1"
+--dhcp-option=225,01:02:03
+dhcp-option=encap:175,190,"user"
+dhcp-option=encap:175,191,10.0.0.5
+dhcp-option=vi-encap:4491,1,10.0.0.6
+dhcp-option=vendor:PXEClient,6,2
+`)
+	dnsmasqOptions = []string{
+		"dhcp-option=option:router,10.0.0.1",
+		"dhcp-option=option:dns-server,10.0.0.2,10.0.0.3",
+		"dhcp-option=option:domain-name,example.com",
+		"dhcp-option=option:domain-search,example.com,example.net",
+		"dhcp-option=51,3600",
+		"dhcp-option=option:ip-forward-enable,0",
+		"dhcp-option=option:classless-static-route,10.1.0.0/16,10.0.0.254",
+		`dhcp-option=224,"1"`,
+		"dhcp-option=225,01:02:03",
+		"dhcp-option=175,be:04:75:73:65:72:bf:04:0a:00:00:05",
+		"dhcp-option=43,06:01:02",
+		"dhcp-option=option:vendor-id-encap,00:00:11:8b:06:01:04:0a:00:00:06",
+	}
+)
+
+func TestUnmarshalDnsmasq(t *testing.T) {
+	cfg, err := UnmarshalDnsmasq(dnsmasqConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Lines) != 13 {
+		t.Fatal(cfg.Lines)
+	}
+	if l := cfg.Lines[1]; len(l.Tags) != 2 || l.Tags[0] != "lan" || l.Tags[1] != "!guest" {
+		t.Error(l.Tags)
+	}
+	if !cfg.Lines[7].Force || cfg.Lines[6].Force {
+		t.Error(cfg.Lines[7])
+	}
+	if l := cfg.Lines[9]; l.Encap != 175 || l.Option.Code != 190 || string(l.Option.Encode()) != "user" {
+		t.Error(l)
+	}
+	if l := cfg.Lines[12]; l.Vendor != "PXEClient" || !bytes.Equal(l.Option.Encode(), []byte{2}) {
+		t.Error(l)
+	}
+	if len(cfg.Options) != len(dnsmasqOptions) {
+		t.Fatal(cfg.Options)
+	}
+	for i, o := range cfg.Options {
+		s, err := MarshalDnsmasqOption(o)
+		if err != nil {
+			t.Error(err)
+		}
+		if s != dnsmasqOptions[i] {
+			t.Error(s)
+		}
+	}
+}
+
+func TestUnmarshalDnsmasqError(t *testing.T) {
+	for _, s := range []string{
+		`dhcp-option=option:unknown-option,1`,
+		`dhcp-option=option6:dns-server,[::1]`,
+		`dhcp-option=3,example.com`,
+		`dhcp-option=15,"unterminated`,
+		`dhcp-option=121,10.1.0.0/16`,
+		`dhcp-option=tag:lan`,
+		`dhcp-option=encap:256,1,1`,
+	} {
+		if _, err := UnmarshalDnsmasq([]byte(s)); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestMarshalDnsmasq(t *testing.T) {
+	ip := IPv4(ipBytes)
+	s := String("a, \"b\"")
+	b, err := MarshalDnsmasq([]Option{
+		{OptionData: &ip, Code: 1},
+		{OptionData: &s, Code: 15},
+		{OptionData: &s, Code: 230},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte(`dhcp-option=option:netmask,192.168.100.1
+dhcp-option=option:domain-name,"a, \"b\""
+dhcp-option=230,"a, \"b\""
+`)
+	if bytes.Compare(b, expected) != 0 {
+		t.Error(string(b))
+	}
+	cfg, err := UnmarshalDnsmasq(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Options) != 3 || string(cfg.Options[2].Encode()) != string(s) {
+		t.Error(cfg.Options)
+	}
+	if _, err := MarshalDnsmasqOption(Option{OptionData: &End{}, Code: 255}); err == nil {
+		t.Error()
+	}
+}
+
+func TestMarshalDnsmasqEncap(t *testing.T) {
+	cfg, err := UnmarshalDnsmasq([]byte(`dhcp-option=tag:lan,3,10.0.0.1
+dhcp-option=encap:175,190,user
+dhcp-option=vi-encap:2,10,text
+dhcp-option=43,01:05:00
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalDnsmasq(cfg.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `dhcp-option=option:router,10.0.0.1
+dhcp-option=43,01:05:00
+dhcp-option=encap:175,190,"user"
+dhcp-option=vi-encap:2,10,"text"
+`
+	if string(b) != expected {
+		t.Error(string(b))
+	}
+	c, err := UnmarshalDnsmasq(b)
+	if err != nil || len(c.Options) != len(cfg.Options) {
+		t.Fatal(c, err)
+	}
+	for i, o := range c.Options {
+		if o.Code != cfg.Options[i].Code || !bytes.Equal(o.Encode(), cfg.Options[i].Encode()) {
+			t.Error(o, cfg.Options[i])
+		}
+	}
+
+	b, err = MarshalDnsmasqLines(cfg.Lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `dhcp-option=tag:lan,option:router,10.0.0.1
+dhcp-option=encap:175,190,"user"
+dhcp-option=vi-encap:2,10,"text"
+dhcp-option=43,01:05:00
+`
+	if string(b) != expected {
+		t.Error(string(b))
+	}
+}
+
+func TestMarshalDnsmasqLines(t *testing.T) {
+	cfg, err := UnmarshalDnsmasq(dnsmasqConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"dhcp-option=option:router,10.0.0.1",
+		"dhcp-option=tag:lan,tag:!guest,option:dns-server,10.0.0.2,10.0.0.3",
+		"dhcp-option=option:domain-name,example.com",
+		"dhcp-option=option:domain-search,example.com,example.net",
+		"dhcp-option=51,3600",
+		"dhcp-option=option:ip-forward-enable,0",
+		"dhcp-option=option:classless-static-route,10.1.0.0/16,10.0.0.254",
+		`dhcp-option-force=224,"1"`,
+		"dhcp-option=225,01:02:03",
+		`dhcp-option=encap:175,190,"user"`,
+		"dhcp-option=encap:175,191,0a:00:00:05",
+		"dhcp-option=vi-encap:4491,1,0a:00:00:06",
+		"dhcp-option=vendor:PXEClient,6,02",
+	}
+	for i, l := range cfg.Lines {
+		s, err := l.Marshal()
+		if err != nil || s != expected[i] {
+			t.Error(s, err)
+			continue
+		}
+		// The line is parsed as the same option.
+		c, err := UnmarshalDnsmasq([]byte(s))
+		if err != nil || len(c.Lines) != 1 || !bytes.Equal(c.Lines[0].Option.Encode(), l.Option.Encode()) ||
+			c.Lines[0].Encap != l.Encap || c.Lines[0].VIEncap != l.VIEncap || c.Lines[0].Vendor != l.Vendor {
+			t.Error(s, c, err)
+		}
+	}
+
+	c, err := UnmarshalDnsmasq([]byte("dhcp-option=option:Router,10.0.0.1\ndhcp-option=option:t1,60"))
+	if err != nil || len(c.Options) != 2 || c.Options[0].Code != 3 {
+		t.Error("case-insensitive names:", c, err)
+	}
+}
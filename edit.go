@@ -0,0 +1,136 @@
+package dhop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type EditOperation byte
+
+const (
+	EditSet EditOperation = 1 + iota
+	EditDelete
+	EditAppend
+)
+
+func (op EditOperation) String() string {
+	switch op {
+	case EditSet:
+		return "set"
+	case EditDelete:
+		return "delete"
+	case EditAppend:
+		return "append"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(op))
+}
+
+// Edit is an operation on the options of a message.
+// Data is encoded in the wire format, and is ignored by EditDelete.
+// EditAppend concatenates Data to the existing data of the option.
+type Edit struct {
+	Operation EditOperation
+	Code      Code
+	// SubCode is the code of the sub-option to edit, or -1 to edit the whole option.
+	SubCode int
+	Data    []byte
+}
+
+// ParseEditKey parses "code" or "code.subcode", where code is a number or a name accepted by ParseCode.
+func ParseEditKey(s string) (Code, int, error) {
+	subCode := -1
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		n, err := strconv.ParseUint(s[i+1:], 10, 8)
+		if err == nil {
+			subCode = int(n)
+			s = s[:i]
+		}
+	}
+	code, err := ParseCode(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return code, subCode, nil
+}
+
+func (e *Edit) Apply(m *Message) error {
+	if e.SubCode < 0 {
+		return e.applyOption(m)
+	}
+	return e.applySubOption(m)
+}
+
+func (e *Edit) applyOption(m *Message) error {
+	var data []byte
+	switch e.Operation {
+	case EditDelete:
+		m.DeleteOption(e.Code)
+		return nil
+	case EditSet:
+		data = e.Data
+	case EditAppend:
+		for _, o := range m.Options {
+			if o.Code == e.Code {
+				data = append(data, o.Encode()...)
+			}
+		}
+		data = append(data, e.Data...)
+	default:
+		return fmt.Errorf("invalid edit operation: %s", e.Operation)
+	}
+	o, err := Decode(byte(e.Code), data)
+	if err != nil {
+		return err
+	}
+	m.SetOption(o)
+	return nil
+}
+
+func (e *Edit) applySubOption(m *Message) error {
+	var subOptions []SubOption
+	if o, ok := m.Option(e.Code); ok {
+		a, err := DecodeSubOptions(o.Encode())
+		if err != nil {
+			return err
+		}
+		subOptions = a
+	}
+	code := byte(e.SubCode)
+	switch e.Operation {
+	case EditDelete:
+		a := make([]SubOption, 0, len(subOptions))
+		for _, o := range subOptions {
+			if o.Code != code {
+				a = append(a, o)
+			}
+		}
+		subOptions = a
+	case EditSet:
+		replaced := false
+		for i, o := range subOptions {
+			if o.Code == code {
+				subOptions[i].Data = e.Data
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			subOptions = append(subOptions, SubOption{Code: code, Data: e.Data})
+		}
+	case EditAppend:
+		subOptions = append(subOptions, SubOption{Code: code, Data: e.Data})
+	default:
+		return fmt.Errorf("invalid edit operation: %s", e.Operation)
+	}
+	if len(subOptions) == 0 {
+		m.DeleteOption(e.Code)
+		return nil
+	}
+	o, err := Decode(byte(e.Code), EncodeSubOptions(subOptions))
+	if err != nil {
+		return err
+	}
+	m.SetOption(o)
+	return nil
+}
@@ -0,0 +1,87 @@
+package dhop
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseCode(t *testing.T) {
+	for s, expected := range map[string]Code{
+		"3":                       3,
+		"router":                  3,
+		"Domain Server":           6,
+		"relay-agent-information": 82,
+	} {
+		code, err := ParseCode(s)
+		if err != nil {
+			t.Error(err)
+		}
+		if code != expected {
+			t.Error(s, code)
+		}
+	}
+	if _, err := ParseCode("unknown"); err == nil {
+		t.Error()
+	}
+}
+
+func TestParseEditKey(t *testing.T) {
+	code, subCode, err := ParseEditKey("82.1")
+	if err != nil || code != 82 || subCode != 1 {
+		t.Error(code, subCode, err)
+	}
+	code, subCode, err = ParseEditKey("router")
+	if err != nil || code != 3 || subCode != -1 {
+		t.Error(code, subCode, err)
+	}
+}
+
+func TestEditOption(t *testing.T) {
+	m := Message{
+		Options: []Option{
+			{OptionData: newByte(1), Code: 53},
+			{OptionData: &IPv4s{IPv4(ipBytes)}, Code: 3},
+		},
+	}
+	edits := []Edit{
+		{Operation: EditDelete, Code: 53, SubCode: -1},
+		{Operation: EditSet, Code: 3, SubCode: -1, Data: []byte{10, 0, 0, 1}},
+		{Operation: EditAppend, Code: 3, SubCode: -1, Data: []byte{10, 0, 0, 2}},
+	}
+	for _, e := range edits {
+		if err := e.Apply(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(m.Options) != 1 || string(m.Options[0].Marshal()) != "10.0.0.1,10.0.0.2" {
+		t.Error(m.Options)
+	}
+	e := Edit{Operation: EditSet, Code: 1, SubCode: -1, Data: []byte{255}}
+	if err := e.Apply(&m); err == nil {
+		t.Error()
+	}
+}
+
+func TestEditSubOption(t *testing.T) {
+	s := String([]byte{1, 3, 'a', 'b', 'c', 2, 1, 'x'})
+	m := Message{
+		Options: []Option{{OptionData: &s, Code: 82}},
+	}
+	e := Edit{Operation: EditSet, Code: 82, SubCode: 1, Data: []byte("port1")}
+	if err := e.Apply(&m); err != nil {
+		t.Fatal(err)
+	}
+	o, _ := m.Option(82)
+	if bytes.Compare(o.Encode(), []byte{1, 5, 'p', 'o', 'r', 't', '1', 2, 1, 'x'}) != 0 {
+		t.Error(o.Encode())
+	}
+	for _, code := range []int{1, 2} {
+		e := Edit{Operation: EditDelete, Code: 82, SubCode: code}
+		if err := e.Apply(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := m.Option(82); ok {
+		t.Error("empty option must be removed")
+	}
+}
@@ -0,0 +1,205 @@
+package dhop
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// EnvVar is an environment variable passed to the hook scripts of DHCP clients.
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// String returns the assignment quoted for shells such as `new_routers='10.0.0.1'`.
+func (v EnvVar) String() string {
+	return fmt.Sprintf("%s='%s'", v.Name, strings.Replace(v.Value, "'", `'\''`, -1))
+}
+
+// udhcpcNames are the variable names of busybox udhcpc.
+var udhcpcNames = map[Code]string{
+	1:   "subnet",
+	2:   "timezone",
+	3:   "router",
+	4:   "timesrv",
+	5:   "namesrv",
+	6:   "dns",
+	7:   "logsrv",
+	8:   "cookiesrv",
+	9:   "lprsrv",
+	12:  "hostname",
+	13:  "bootsize",
+	15:  "domain",
+	16:  "swapsrv",
+	17:  "rootpath",
+	23:  "ipttl",
+	26:  "mtu",
+	28:  "broadcast",
+	33:  "routes",
+	40:  "nisdomain",
+	41:  "nissrv",
+	42:  "ntpsrv",
+	44:  "wins",
+	51:  "lease",
+	54:  "serverid",
+	56:  "message",
+	66:  "tftp",
+	67:  "bootfile",
+	119: "search",
+	120: "sipsrv",
+	121: "staticroutes",
+	249: "msstaticroutes",
+}
+
+// DhclientEnv returns the variables which ISC dhclient passes to dhclient-script for the message,
+// such as new_ip_address and new_routers. The prefix is "new_" or "old_".
+// Options are named after dhcpd with underscores, and lists are separated by spaces.
+// Classless static routes are also given as pairs of the destination and the gateway
+// in new_classless_static_routes.
+func DhclientEnv(m *Message, prefix string) []EnvVar {
+	env := make([]EnvVar, 0, len(m.Options)+4)
+	ip := m.YIAddr
+	if isUnspecified(ip) {
+		ip = m.CIAddr
+	}
+	if !isUnspecified(ip) {
+		env = append(env, EnvVar{prefix + "ip_address", ip.String()})
+	}
+	if m.SName != "" {
+		env = append(env, EnvVar{prefix + "server_name", m.SName})
+	}
+	if m.File != "" {
+		env = append(env, EnvVar{prefix + "filename", m.File})
+	}
+	if mask := m.ipv4Option(1); mask != nil && !isUnspecified(ip) {
+		network := ip.Mask(net.IPMask(mask.To4()))
+		env = append(env, EnvVar{prefix + "network_number", network.String()})
+		if _, ok := m.Option(28); !ok {
+			broadcast := make(net.IP, 4)
+			for i := range broadcast {
+				broadcast[i] = network.To4()[i] | ^mask.To4()[i]
+			}
+			env = append(env, EnvVar{prefix + "broadcast_address", broadcast.String()})
+		}
+	}
+	for _, o := range m.Options {
+		switch o.Code {
+		case 0, 255:
+			continue
+		}
+		name := prefix + strings.Replace(DhcpdName(o.Code), "-", "_", -1)
+		value := envValue(o)
+		switch o.OptionData.(type) {
+		case *Routes:
+			// dhclient passes the routes as the bytes of the option.
+			b := o.Encode()
+			s := make([]string, len(b))
+			for i, c := range b {
+				s[i] = strconv.Itoa(int(c))
+			}
+			env = append(env, EnvVar{name, strings.Join(s, " ")})
+			if o.Code == 121 {
+				env = append(env, EnvVar{prefix + "classless_static_routes", value})
+			}
+			continue
+		case *DomainNames:
+			// dhclient passes the domain names as absolute names.
+			names := strings.Fields(value)
+			for i := range names {
+				names[i] += "."
+			}
+			value = strings.Join(names, " ")
+		}
+		env = append(env, EnvVar{name, value})
+	}
+	return env
+}
+
+// UdhcpcEnv returns the variables which busybox udhcpc passes to its script for the message,
+// such as ip, router, dns, staticroutes and lease. Options without names are given as optN in hex.
+func UdhcpcEnv(m *Message) []EnvVar {
+	env := make([]EnvVar, 0, len(m.Options)+4)
+	if !isUnspecified(m.YIAddr) {
+		env = append(env, EnvVar{"ip", m.YIAddr.String()})
+	}
+	if !isUnspecified(m.SIAddr) {
+		env = append(env, EnvVar{"siaddr", m.SIAddr.String()})
+	}
+	if m.SName != "" {
+		env = append(env, EnvVar{"sname", m.SName})
+	}
+	if m.File != "" {
+		env = append(env, EnvVar{"boot_file", m.File})
+	}
+	for _, o := range m.Options {
+		switch o.Code {
+		case 0, 53, 255:
+			continue
+		}
+		name, ok := udhcpcNames[o.Code]
+		if !ok {
+			env = append(env, EnvVar{fmt.Sprintf("opt%d", o.Code), fmt.Sprintf("%x", o.Encode())})
+			continue
+		}
+		env = append(env, EnvVar{name, envValue(o)})
+		if o.Code == 1 {
+			if mask, ok := o.OptionData.(*IPv4); ok {
+				ones, _ := net.IPMask(net.IP(*mask).To4()).Size()
+				env = append(env, EnvVar{"mask", strconv.Itoa(ones)})
+			}
+		}
+	}
+	return env
+}
+
+// envValue formats the option data as a list separated by spaces.
+func envValue(o Option) string {
+	switch v := o.OptionData.(type) {
+	case *IPv4s:
+		s := make([]string, len(*v))
+		for i, ip := range *v {
+			s[i] = net.IP(ip).String()
+		}
+		return strings.Join(s, " ")
+	case *IPv4Pair:
+		return net.IP(v[0]).String() + " " + net.IP(v[1]).String()
+	case *IPv4Pairs:
+		s := make([]string, 0, len(*v)*2)
+		for _, p := range *v {
+			s = append(s, net.IP(p[0]).String(), net.IP(p[1]).String())
+		}
+		return strings.Join(s, " ")
+	case *Sizes:
+		s := make([]string, len(*v))
+		for i, n := range *v {
+			s[i] = strconv.Itoa(int(n))
+		}
+		return strings.Join(s, " ")
+	case *TimeDuration:
+		return strconv.FormatUint(uint64(binary.BigEndian.Uint32(o.Encode())), 10)
+	case *TimeOffset:
+		return strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(o.Encode()))), 10)
+	case *Routes:
+		s := make([]string, 0, len(*v)*2)
+		for _, r := range *v {
+			s = append(s, r.Source.String(), r.Destination.String())
+		}
+		return strings.Join(s, " ")
+	case *DomainNames:
+		s := make([]string, len(*v))
+		for i, dn := range *v {
+			s[i] = string(dn.Marshal())
+		}
+		return strings.Join(s, " ")
+	case *String:
+		b := []byte(*v)
+		if dhcpdTextOptions[o.Code] || isPrintable(b) {
+			return string(b)
+		}
+		return formatColonHex(b)
+	}
+	return string(o.Marshal())
+}
@@ -0,0 +1,89 @@
+package dhop
+
+import (
+	"net"
+	"testing"
+)
+
+func envMessage() *Message {
+	mask := IPv4(net.IPv4(255, 255, 255, 0).To4())
+	routers := IPv4s{IPv4(net.IPv4(10, 0, 0, 1).To4()), IPv4(net.IPv4(10, 0, 0, 2).To4())}
+	dns := IPv4s{IPv4(net.IPv4(10, 0, 0, 3).To4())}
+	domain := String("example.com")
+	search := DomainNames{DomainName{"example", "com"}, DomainName{"example", "net"}}
+	lease := TimeDuration(0)
+	lease.Unmarshal([]byte("1h"))
+	routes := Routes{}
+	routes.Unmarshal([]byte("192.168.100.0/24 10.0.0.1"))
+	unknown := String("\x01\x02")
+	return &Message{
+		Op:     OpReply,
+		YIAddr: net.IPv4(10, 0, 0, 5).To4(),
+		File:   "pxelinux.0",
+		Options: []Option{
+			{OptionData: &mask, Code: 1},
+			{OptionData: &routers, Code: 3},
+			{OptionData: &dns, Code: 6},
+			{OptionData: &domain, Code: 15},
+			{OptionData: &search, Code: 119},
+			{OptionData: &lease, Code: 51},
+			{OptionData: &routes, Code: 121},
+			{OptionData: &unknown, Code: 224},
+		},
+	}
+}
+
+func TestDhclientEnv(t *testing.T) {
+	expected := []string{
+		"new_ip_address='10.0.0.5'",
+		"new_filename='pxelinux.0'",
+		"new_network_number='10.0.0.0'",
+		"new_broadcast_address='10.0.0.255'",
+		"new_subnet_mask='255.255.255.0'",
+		"new_routers='10.0.0.1 10.0.0.2'",
+		"new_domain_name_servers='10.0.0.3'",
+		"new_domain_name='example.com'",
+		"new_domain_search='example.com. example.net.'",
+		"new_dhcp_lease_time='3600'",
+		"new_rfc3442_classless_static_routes='24 192 168 100 10 0 0 1'",
+		"new_classless_static_routes='192.168.100.0/24 10.0.0.1'",
+		"new_unknown_224='01:02'",
+	}
+	env := DhclientEnv(envMessage(), "new_")
+	if len(env) != len(expected) {
+		t.Fatal(env)
+	}
+	for i, v := range env {
+		if v.String() != expected[i] {
+			t.Error(v.String())
+		}
+	}
+}
+
+func TestUdhcpcEnv(t *testing.T) {
+	expected := []string{
+		"ip='10.0.0.5'",
+		"boot_file='pxelinux.0'",
+		"subnet='255.255.255.0'",
+		"mask='24'",
+		"router='10.0.0.1 10.0.0.2'",
+		"dns='10.0.0.3'",
+		"domain='example.com'",
+		"search='example.com example.net'",
+		"lease='3600'",
+		"staticroutes='192.168.100.0/24 10.0.0.1'",
+		"opt224='0102'",
+	}
+	env := UdhcpcEnv(envMessage())
+	if len(env) != len(expected) {
+		t.Fatal(env)
+	}
+	for i, v := range env {
+		if v.String() != expected[i] {
+			t.Error(v.String())
+		}
+	}
+	if s := (EnvVar{"message", "it's"}).String(); s != `message='it'\''s'` {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,774 @@
+package dhop
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// KeaSpace is the option space of the standard DHCPv4 options in Kea.
+const KeaSpace = "dhcp4"
+
+// https://kea.readthedocs.io/en/latest/arm/dhcp4-srv.html#standard-dhcpv4-options
+var keaOptionNames = map[Code]string{
+	1:   "subnet-mask",
+	2:   "time-offset",
+	3:   "routers",
+	4:   "time-servers",
+	5:   "name-servers",
+	6:   "domain-name-servers",
+	7:   "log-servers",
+	8:   "cookie-servers",
+	9:   "lpr-servers",
+	10:  "impress-servers",
+	11:  "resource-location-servers",
+	12:  "host-name",
+	13:  "boot-size",
+	14:  "merit-dump",
+	15:  "domain-name",
+	16:  "swap-server",
+	17:  "root-path",
+	18:  "extensions-path",
+	19:  "ip-forwarding",
+	20:  "non-local-source-routing",
+	21:  "policy-filter",
+	22:  "max-dgram-reassembly",
+	23:  "default-ip-ttl",
+	24:  "path-mtu-aging-timeout",
+	25:  "path-mtu-plateau-table",
+	26:  "interface-mtu",
+	27:  "all-subnets-local",
+	28:  "broadcast-address",
+	29:  "perform-mask-discovery",
+	30:  "mask-supplier",
+	31:  "router-discovery",
+	32:  "router-solicitation-address",
+	33:  "static-routes",
+	34:  "trailer-encapsulation",
+	35:  "arp-cache-timeout",
+	36:  "ieee802-3-encapsulation",
+	37:  "default-tcp-ttl",
+	38:  "tcp-keepalive-interval",
+	39:  "tcp-keepalive-garbage",
+	40:  "nis-domain",
+	41:  "nis-servers",
+	42:  "ntp-servers",
+	43:  "vendor-encapsulated-options",
+	44:  "netbios-name-servers",
+	45:  "netbios-dd-server",
+	46:  "netbios-node-type",
+	47:  "netbios-scope",
+	48:  "font-servers",
+	49:  "x-display-manager",
+	50:  "dhcp-requested-address",
+	51:  "dhcp-lease-time",
+	52:  "dhcp-option-overload",
+	53:  "dhcp-message-type",
+	54:  "dhcp-server-identifier",
+	55:  "dhcp-parameter-request-list",
+	56:  "dhcp-message",
+	57:  "dhcp-max-message-size",
+	58:  "dhcp-renewal-time",
+	59:  "dhcp-rebinding-time",
+	60:  "vendor-class-identifier",
+	61:  "dhcp-client-identifier",
+	62:  "nwip-domain-name",
+	63:  "nwip-suboptions",
+	64:  "nisplus-domain-name",
+	65:  "nisplus-servers",
+	66:  "tftp-server-name",
+	67:  "boot-file-name",
+	68:  "mobile-ip-home-agent",
+	69:  "smtp-server",
+	70:  "pop-server",
+	71:  "nntp-server",
+	72:  "www-server",
+	73:  "finger-server",
+	74:  "irc-server",
+	75:  "streettalk-server",
+	76:  "streettalk-directory-assistance-server",
+	77:  "user-class",
+	78:  "slp-directory-agent",
+	79:  "slp-service-scope",
+	81:  "fqdn",
+	82:  "dhcp-agent-options",
+	85:  "nds-servers",
+	86:  "nds-tree-name",
+	87:  "nds-context",
+	88:  "bcms-controller-names",
+	89:  "bcms-controller-address",
+	93:  "client-system",
+	94:  "client-ndi",
+	97:  "uuid-guid",
+	98:  "uap-servers",
+	100: "pcode",
+	101: "tcode",
+	112: "netinfo-server-address",
+	113: "netinfo-server-tag",
+	114: "v4-captive-portal",
+	116: "auto-config",
+	117: "name-service-search",
+	118: "subnet-selection",
+	119: "domain-search",
+	121: "classless-static-route",
+	124: "vivco-suboptions",
+	125: "vivso-suboptions",
+	138: "capwap-ac-v4",
+}
+
+// keaSpaces are the option spaces which Kea encapsulates into the standard options by default.
+var keaSpaces = map[string]Code{
+	"vendor-encapsulated-options-space": 43,
+	"dhcp-agent-options-space":          82,
+}
+
+// keaSubOptionDefs are the definitions of the sub-options in keaSpaces.
+var keaSubOptionDefs = []KeaOptionDef{
+	{Name: "circuit-id", Code: 1, Space: "dhcp-agent-options-space", Type: "binary"},
+	{Name: "remote-id", Code: 2, Space: "dhcp-agent-options-space", Type: "binary"},
+	{Name: "link-selection", Code: 5, Space: "dhcp-agent-options-space", Type: "ipv4-address"},
+	{Name: "subscriber-id", Code: 6, Space: "dhcp-agent-options-space", Type: "binary"},
+	{Name: "server-id-override", Code: 11, Space: "dhcp-agent-options-space", Type: "ipv4-address"},
+}
+
+// KeaOptionData is an entry of `option-data` in the Kea configuration.
+// CSVFormat is nil if `csv-format` is omitted, which means true.
+type KeaOptionData struct {
+	Name       string `json:"name,omitempty"`
+	Code       int    `json:"code,omitempty"`
+	Space      string `json:"space,omitempty"`
+	CSVFormat  *bool  `json:"csv-format,omitempty"`
+	Data       string `json:"data"`
+	AlwaysSend bool   `json:"always-send,omitempty"`
+}
+
+// KeaOptionDef is an entry of `option-def` in the Kea configuration.
+type KeaOptionDef struct {
+	Name        string `json:"name"`
+	Code        int    `json:"code"`
+	Space       string `json:"space"`
+	Type        string `json:"type"`
+	RecordTypes string `json:"record-types,omitempty"`
+	Array       bool   `json:"array,omitempty"`
+	Encapsulate string `json:"encapsulate,omitempty"`
+}
+
+// KeaConfig is the `option-def` and `option-data` of the Kea configuration.
+// Options has the options of OptionData in the dhcp4 space, and the options of
+// the other spaces are encapsulated into the options which encapsulate the spaces.
+type KeaConfig struct {
+	OptionDef  []KeaOptionDef  `json:"option-def,omitempty"`
+	OptionData []KeaOptionData `json:"option-data"`
+	Options    []Option        `json:"-"`
+	// Warnings are the scopes whose options are flattened into Options, and the spaces which are not encapsulated.
+	Warnings []string `json:"-"`
+}
+
+// KeaName returns the option name of Kea, or "unknown-N" for undefined codes.
+func KeaName(code Code) string {
+	if name, ok := keaOptionNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown-%d", code)
+}
+
+// keaType is an option type of Kea such as an array of ipv4-address.
+// Only the last field of a record is repeated if it is an array.
+type keaType struct {
+	fields []string
+	array  bool
+}
+
+func newKeaType(d *KeaOptionDef) (keaType, error) {
+	t := keaType{array: d.Array}
+	if d.Type == "record" {
+		for _, f := range strings.Split(d.RecordTypes, ",") {
+			t.fields = append(t.fields, strings.TrimSpace(f))
+		}
+	} else {
+		t.fields = []string{d.Type}
+	}
+	for _, f := range t.fields {
+		switch f {
+		case "binary", "boolean", "empty", "fqdn", "ipv4-address", "ipv6-address", "string",
+			"uint8", "uint16", "uint32", "int8", "int16", "int32", "internal":
+		default:
+			return t, fmt.Errorf("unsupported type \"%s\"", f)
+		}
+	}
+	return t, nil
+}
+
+// keaDefOf returns the definition of the code in the dhcp4 space
+// with the type of NewOptionData, or false if Kea cannot express the type.
+func keaDefOf(code Code) (KeaOptionDef, bool) {
+	d := KeaOptionDef{
+		Name:  KeaName(code),
+		Code:  int(code),
+		Space: KeaSpace,
+	}
+	switch NewOptionData(byte(code)).(type) {
+	case *IPv4:
+		d.Type = "ipv4-address"
+	case *IPv4s, *IPv4Pairs:
+		d.Type = "ipv4-address"
+		d.Array = true
+	case *IPv4Pair:
+		d.Type = "record"
+		d.RecordTypes = "ipv4-address, ipv4-address"
+	case *Boolean:
+		d.Type = "boolean"
+	case *Byte:
+		d.Type = "uint8"
+	case *Size:
+		d.Type = "uint16"
+	case *Sizes:
+		d.Type = "uint16"
+		d.Array = true
+	case *TimeDuration:
+		d.Type = "uint32"
+	case *TimeOffset:
+		d.Type = "int32"
+	case *DomainNames:
+		d.Type = "fqdn"
+		d.Array = true
+	case *Routes:
+		// Kea formats only its own classless-static-route option.
+		if code != 121 {
+			return d, false
+		}
+		d.Type = "internal"
+	case *String:
+		if !dhcpdTextOptions[code] {
+			return d, false
+		}
+		d.Type = "string"
+	default:
+		return d, false
+	}
+	return d, true
+}
+
+// NewKeaConfig returns the configuration of the options.
+// Options of the types Kea cannot express are configured with csv-format=false and hex data,
+// and options Kea does not define by default are configured with their definitions.
+func NewKeaConfig(options []Option) (*KeaConfig, error) {
+	cfg := &KeaConfig{
+		OptionData: make([]KeaOptionData, 0, len(options)),
+		Options:    options,
+	}
+	defined := make(map[Code]bool)
+	for _, o := range options {
+		switch o.Code {
+		case 0, 255:
+			return nil, fmt.Errorf("%s cannot be configured", o.Code.String())
+		}
+		csv := true
+		od := KeaOptionData{
+			Name:      KeaName(o.Code),
+			Code:      int(o.Code),
+			Space:     KeaSpace,
+			CSVFormat: &csv,
+		}
+		d, ok := keaDefOf(o.Code)
+		if !ok {
+			csv = false
+			od.Data = strings.ToUpper(hex.EncodeToString(o.Encode()))
+			cfg.OptionData = append(cfg.OptionData, od)
+			continue
+		}
+		t, _ := newKeaType(&d)
+		data, err := t.format(o.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", od.Name, err)
+		}
+		od.Data = data
+		if _, ok := keaOptionNames[o.Code]; !ok && !defined[o.Code] {
+			cfg.OptionDef = append(cfg.OptionDef, d)
+			defined[o.Code] = true
+		}
+		cfg.OptionData = append(cfg.OptionData, od)
+	}
+	return cfg, nil
+}
+
+// MarshalKea returns the JSON of the configuration of the options.
+func MarshalKea(options []Option) ([]byte, error) {
+	cfg, err := NewKeaConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+// UnmarshalKea parses `option-def` and `option-data` in the Kea configuration.
+// The configuration may be the whole file with the Dhcp4 map, and `option-data`
+// of subnets, pools, reservations and client classes are also collected.
+// Comments are allowed as Kea does.
+func UnmarshalKea(b []byte) (*KeaConfig, error) {
+	cfg := &KeaConfig{}
+	dec := json.NewDecoder(bytes.NewReader(stripKeaComments(b)))
+	// objects has whether each of the nested containers is an object or an array,
+	// and names has the keys of the containers.
+	objects := make([]bool, 0, 8)
+	names := make([]string, 0, 8)
+	name := ""
+	flattened := make(map[string]bool)
+	key := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case json.Delim('{'):
+			objects = append(objects, true)
+			names = append(names, name)
+			name = ""
+			key = true
+			continue
+		case json.Delim('['):
+			objects = append(objects, false)
+			names = append(names, name)
+			name = ""
+			key = false
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			objects = objects[:len(objects)-1]
+			names = names[:len(names)-1]
+		default:
+			if !key {
+				// A value resets the key of the next container.
+				name = ""
+				break
+			}
+			switch tok {
+			case "option-def":
+				defs := make([]KeaOptionDef, 0)
+				if err := dec.Decode(&defs); err != nil {
+					return nil, fmt.Errorf("option-def: %s", err)
+				}
+				cfg.OptionDef = append(cfg.OptionDef, defs...)
+			case "option-data":
+				scopes := make([]string, 0, len(names))
+				for _, n := range names {
+					if n != "" && n != "Dhcp4" {
+						scopes = append(scopes, n)
+					}
+				}
+				if scope := strings.Join(scopes, " > "); scope != "" && !flattened[scope] {
+					flattened[scope] = true
+					cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("options in scope \"%s\" are flattened", scope))
+				}
+				data := make([]KeaOptionData, 0)
+				if err := dec.Decode(&data); err != nil {
+					return nil, fmt.Errorf("option-data: %s", err)
+				}
+				cfg.OptionData = append(cfg.OptionData, data...)
+			default:
+				name, _ = tok.(string)
+				key = false
+				continue
+			}
+		}
+		key = len(objects) > 0 && objects[len(objects)-1]
+	}
+	options, err := cfg.options()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Options = options
+	return cfg, nil
+}
+
+// stripKeaComments replaces the comments starting with #, // or /* with spaces.
+func stripKeaComments(b []byte) []byte {
+	out := make([]byte, len(b))
+	copy(out, b)
+	quoted := false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '#' || c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			for ; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i+1 < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		}
+	}
+	return out
+}
+
+func (cfg *KeaConfig) options() ([]Option, error) {
+	defs := make(map[string]*KeaOptionDef)
+	add := func(d KeaOptionDef) {
+		if d.Space == "" {
+			d.Space = KeaSpace
+		}
+		defs[fmt.Sprintf("%s.%s", d.Space, d.Name)] = &d
+		defs[fmt.Sprintf("%s.%d", d.Space, d.Code)] = &d
+	}
+	for code := range keaOptionNames {
+		d, ok := keaDefOf(code)
+		if !ok {
+			d.Type = "binary"
+		}
+		add(d)
+	}
+	for _, d := range keaSubOptionDefs {
+		add(d)
+	}
+	encapsulated := make(map[string]Code)
+	for space, code := range keaSpaces {
+		encapsulated[space] = code
+	}
+	for _, d := range cfg.OptionDef {
+		if d.Code < 1 || d.Code > 254 {
+			return nil, fmt.Errorf("option-def \"%s\": invalid code %d", d.Name, d.Code)
+		}
+		if _, err := newKeaType(&d); err != nil || d.Type == "internal" {
+			return nil, fmt.Errorf("option-def \"%s\": unsupported type \"%s\"", d.Name, d.Type)
+		}
+		add(d)
+		if d.Encapsulate != "" && (d.Space == "" || d.Space == KeaSpace) {
+			encapsulated[d.Encapsulate] = Code(d.Code)
+		}
+	}
+
+	options := make([]Option, 0, len(cfg.OptionData))
+	spaceOptions := make(map[string][]SubOption)
+	order := make([]string, 0)
+	for i, od := range cfg.OptionData {
+		space := od.Space
+		if space == "" {
+			space = KeaSpace
+		}
+		name := od.Name
+		if name == "" {
+			name = strconv.Itoa(od.Code)
+		}
+		code, data, err := od.encode(space, defs)
+		if err != nil {
+			return nil, fmt.Errorf("option-data[%d] \"%s\": %s", i, name, err)
+		}
+		if space != KeaSpace {
+			if _, ok := spaceOptions[space]; !ok {
+				order = append(order, space)
+			}
+			spaceOptions[space] = append(spaceOptions[space], SubOption{Code: byte(code), Data: data})
+			continue
+		}
+		o, err := Decode(byte(code), data)
+		if err != nil {
+			return nil, fmt.Errorf("option-data[%d] \"%s\": %s", i, name, err)
+		}
+		options = append(options, o)
+	}
+	for _, space := range order {
+		code, ok := encapsulated[space]
+		if !ok {
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf(
+				"%d options in space \"%s\" are dropped because no option encapsulates the space",
+				len(spaceOptions[space]), space,
+			))
+			continue
+		}
+		data := EncodeSubOptions(spaceOptions[space])
+		index := -1
+		for i, o := range options {
+			if o.Code == code {
+				index = i
+				break
+			}
+		}
+		if index >= 0 {
+			data = append(options[index].Encode(), data...)
+		}
+		o, err := Decode(byte(code), data)
+		if err != nil {
+			return nil, err
+		}
+		if index >= 0 {
+			options[index] = o
+		} else {
+			options = append(options, o)
+		}
+	}
+	return options, nil
+}
+
+// encode returns the code and the data of the option with the definition in the space.
+func (od *KeaOptionData) encode(space string, defs map[string]*KeaOptionDef) (Code, []byte, error) {
+	var d *KeaOptionDef
+	if od.Name != "" {
+		d = defs[space+"."+od.Name]
+		if d == nil && od.Code == 0 {
+			return 0, nil, fmt.Errorf("unknown option in space \"%s\"", space)
+		}
+		if d != nil && od.Code != 0 && od.Code != d.Code {
+			return 0, nil, fmt.Errorf("code %d does not match the definition %d", od.Code, d.Code)
+		}
+	} else {
+		d = defs[fmt.Sprintf("%s.%d", space, od.Code)]
+	}
+	code := od.Code
+	if d != nil {
+		code = d.Code
+	}
+	if code < 1 || code > 254 {
+		return 0, nil, fmt.Errorf("invalid code %d", code)
+	}
+	if od.CSVFormat != nil && !*od.CSVFormat {
+		data, err := parseKeaHex(od.Data)
+		return Code(code), data, err
+	}
+	if d == nil {
+		return 0, nil, fmt.Errorf("no definition for csv-format data")
+	}
+	t, err := newKeaType(d)
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := t.encode(od.Data)
+	return Code(code), data, err
+}
+
+func parseKeaHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	s = strings.NewReplacer(":", "", " ", "").Replace(s)
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex data \"%s\"", s)
+	}
+	return b, nil
+}
+
+// splitKeaCSV splits the data by commas except escaped ones.
+func splitKeaCSV(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	values := make([]string, 0, 2)
+	buf := bytes.Buffer{}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ',':
+			buf.WriteByte(',')
+			i++
+		case s[i] == ',':
+			values = append(values, strings.TrimSpace(buf.String()))
+			buf.Reset()
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return append(values, strings.TrimSpace(buf.String()))
+}
+
+func (t *keaType) encode(data string) ([]byte, error) {
+	values := splitKeaCSV(data)
+	if len(t.fields) == 1 && t.fields[0] == "empty" {
+		if len(values) > 0 {
+			return nil, fmt.Errorf("empty option has data")
+		}
+		return []byte{}, nil
+	}
+	if len(t.fields) == 1 && t.fields[0] == "internal" {
+		return encodeKeaRoutes(values)
+	}
+	if len(t.fields) == 1 && !t.array && (t.fields[0] == "string" || t.fields[0] == "binary") {
+		values = []string{strings.Join(values, ",")}
+	}
+	if len(values) < len(t.fields) {
+		return nil, fmt.Errorf("too few values")
+	}
+	if len(values) > len(t.fields) && !t.array {
+		return nil, fmt.Errorf("too many values")
+	}
+	b := make([]byte, 0, len(values)*4)
+	for i, v := range values {
+		f := t.fields[len(t.fields)-1]
+		if i < len(t.fields) {
+			f = t.fields[i]
+		}
+		fb, err := encodeKeaField(f, v)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, fb...)
+	}
+	return b, nil
+}
+
+func encodeKeaField(f, v string) ([]byte, error) {
+	switch f {
+	case "binary":
+		return parseKeaHex(v)
+	case "boolean":
+		switch strings.ToLower(v) {
+		case "true", "1":
+			return []byte{1}, nil
+		case "false", "0":
+			return []byte{0}, nil
+		}
+		return nil, fmt.Errorf("invalid boolean \"%s\"", v)
+	case "fqdn":
+		dn := DomainName(strings.Split(strings.TrimSuffix(v, "."), "."))
+		return dn.Encode(), nil
+	case "ipv4-address":
+		ip := net.ParseIP(v).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address \"%s\"", v)
+		}
+		return ip, nil
+	case "ipv6-address":
+		ip := net.ParseIP(v)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("invalid IPv6 address \"%s\"", v)
+		}
+		return ip.To16(), nil
+	case "string":
+		return []byte(v), nil
+	case "uint8", "uint16", "uint32", "int8", "int16", "int32":
+		signed := f[0] == 'i'
+		bits, _ := strconv.Atoi(strings.TrimLeft(f, "uint"))
+		var n uint64
+		if signed {
+			i, err := strconv.ParseInt(v, 10, bits)
+			if err != nil {
+				return nil, err
+			}
+			n = uint64(i)
+		} else {
+			u, err := strconv.ParseUint(v, 10, bits)
+			if err != nil {
+				return nil, err
+			}
+			n = u
+		}
+		b := make([]byte, bits/8)
+		for i := range b {
+			b[i] = byte(n >> uint(8*(len(b)-1-i)))
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unsupported type \"%s\"", f)
+}
+
+// encodeKeaRoutes encodes routes such as `10.1.0.0/16 - 10.0.0.254` of classless-static-route.
+func encodeKeaRoutes(values []string) ([]byte, error) {
+	routes := make(Routes, 0, len(values))
+	for _, v := range values {
+		pair := strings.SplitN(v, "-", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid route \"%s\"", v)
+		}
+		r := Route{}
+		if err := r.Unmarshal([]byte(strings.TrimSpace(pair[0]) + " " + strings.TrimSpace(pair[1]))); err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	return routes.Encode(), nil
+}
+
+func (t *keaType) format(b []byte) (string, error) {
+	if len(t.fields) == 1 && t.fields[0] == "internal" {
+		routes := Routes{}
+		if err := routes.Decode(b); err != nil {
+			return "", err
+		}
+		s := make([]string, len(routes))
+		for i, r := range routes {
+			s[i] = fmt.Sprintf("%s - %s", r.Source.String(), r.Destination.String())
+		}
+		return strings.Join(s, ", "), nil
+	}
+	values := make([]string, 0, len(t.fields))
+	for i := 0; len(b) > 0 || i < len(t.fields); i++ {
+		f := t.fields[len(t.fields)-1]
+		if i < len(t.fields) {
+			f = t.fields[i]
+		} else if !t.array {
+			return "", fmt.Errorf("too long data")
+		}
+		if f == "empty" {
+			continue
+		}
+		v, n, err := formatKeaField(f, b)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return strings.Join(values, ", "), nil
+}
+
+func formatKeaField(f string, b []byte) (string, int, error) {
+	switch f {
+	case "binary":
+		return strings.ToUpper(hex.EncodeToString(b)), len(b), nil
+	case "boolean":
+		if err := validateMinimumSize(b, 1); err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatBool(b[0] != 0), 1, nil
+	case "fqdn":
+		dn := DomainName{}
+		if err := dn.Decode(b); err != nil {
+			return "", 0, err
+		}
+		return string(dn.Marshal()), len(dn.Encode()), nil
+	case "ipv4-address":
+		if err := validateMinimumSize(b, 4); err != nil {
+			return "", 0, err
+		}
+		return net.IP(b[:4]).String(), 4, nil
+	case "ipv6-address":
+		if err := validateMinimumSize(b, 16); err != nil {
+			return "", 0, err
+		}
+		return net.IP(b[:16]).String(), 16, nil
+	case "string":
+		return strings.Replace(string(b), ",", "\\,", -1), len(b), nil
+	case "uint8", "uint16", "uint32", "int8", "int16", "int32":
+		bits, _ := strconv.Atoi(strings.TrimLeft(f, "uint"))
+		size := bits / 8
+		if err := validateMinimumSize(b, size); err != nil {
+			return "", 0, err
+		}
+		var n uint64
+		for _, c := range b[:size] {
+			n = n<<8 | uint64(c)
+		}
+		if f[0] == 'i' {
+			shift := uint(64 - bits)
+			return strconv.FormatInt(int64(n<<shift)>>shift, 10), size, nil
+		}
+		return strconv.FormatUint(n, 10), size, nil
+	}
+	return "", 0, fmt.Errorf("unsupported type \"%s\"", f)
+}
@@ -0,0 +1,197 @@
+package dhop
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var keaConfig = []byte(`{
+  # example
+  "Dhcp4": {
+    "option-def": [
+      { "name": "foo", "code": 224, "space": "dhcp4", "type": "record", "record-types": "ipv4-address, uint16, string" },
+      { "name": "bar", "code": 1, "space": "pxelinux", "type": "uint32" },
+      { "name": "pxelinux", "code": 225, "space": "dhcp4", "type": "empty", "encapsulate": "pxelinux" }
+    ],
+    // global options
+    "option-data": [
+      { "name": "domain-name-servers", "data": "10.0.0.2, 10.0.0.3" },
+      { "name": "domain-name", "data": "option-data" },
+      { "name": "dhcp-lease-time", "data": "3600" },
+      { "code": 19, "data": "false" }
+    ],
+    "subnet4": [
+      {
+        "subnet": "10.0.0.0/24",
+        /* subnet options */
+        "option-data": [
+          { "name": "routers", "code": 3, "space": "dhcp4", "csv-format": true, "data": "10.0.0.1" },
+          { "name": "domain-search", "data": "example.com, example.net" },
+          { "name": "classless-static-route", "data": "10.1.0.0/16 - 10.0.0.254" },
+          { "name": "foo", "data": "10.0.0.4, 80, a\\,b" },
+          { "code": 230, "csv-format": false, "data": "0A:00:00:04" },
+          { "name": "circuit-id", "space": "dhcp-agent-options-space", "data": "0102" },
+          { "name": "bar", "space": "pxelinux", "data": "30" }
+        ]
+      }
+    ]
+  }
+}`)
+
+func TestUnmarshalKea(t *testing.T) {
+	cfg, err := UnmarshalKea(keaConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.OptionDef) != 3 || len(cfg.OptionData) != 11 {
+		t.Fatal(cfg.OptionDef, cfg.OptionData)
+	}
+	expected := []struct {
+		code Code
+		data string
+	}{
+		{6, "10.0.0.2,10.0.0.3"},
+		{15, "option-data"},
+		{51, "1h0m0s"},
+		{19, "false"},
+		{3, "10.0.0.1"},
+		{119, "example.com,example.net"},
+		{121, "10.1.0.0/16 10.0.0.254"},
+		{224, "\n\x00\x00\x04\x00Pa,b"},
+		{230, "\n\x00\x00\x04"},
+		{82, "\x01\x02\x01\x02"},
+		{225, "\x01\x04\x00\x00\x00\x1e"},
+	}
+	if len(cfg.Options) != len(expected) {
+		t.Fatal(cfg.Options)
+	}
+	for i, e := range expected {
+		o := cfg.Options[i]
+		if o.Code != e.code || string(o.Marshal()) != e.data {
+			t.Errorf("%d: %q", o.Code, o.Marshal())
+		}
+	}
+}
+
+func TestUnmarshalKeaError(t *testing.T) {
+	for _, s := range []string{
+		`{"option-data": [{"name": "unknown-option", "data": "1"}]}`,
+		`{"option-data": [{"code": 230, "data": "1"}]}`,
+		`{"option-data": [{"name": "routers", "code": 6, "data": "10.0.0.1"}]}`,
+		`{"option-data": [{"name": "routers", "data": "example.com"}]}`,
+		`{"option-data": [{"name": "dhcp-lease-time", "data": "1, 2"}]}`,
+		`{"option-data": [{"code": 230, "csv-format": false, "data": "xyz"}]}`,
+		`{"option-def": [{"name": "foo", "code": 224, "type": "tuple"}]}`,
+		`{"option-data": [`,
+	} {
+		if _, err := UnmarshalKea([]byte(s)); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestMarshalKea(t *testing.T) {
+	ip := IPv4(ipBytes)
+	ips := IPv4s{IPv4(ipBytes), IPv4(ipBytes)}
+	s := String("a,b")
+	d := TimeDuration(0)
+	b, err := MarshalKea([]Option{
+		{OptionData: &ip, Code: 1},
+		{OptionData: &s, Code: 15},
+		{OptionData: &s, Code: 61},
+		{OptionData: &ips, Code: 150},
+		{OptionData: &d, Code: 91},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte(`{
+  "option-def": [
+    {
+      "name": "unknown-150",
+      "code": 150,
+      "space": "dhcp4",
+      "type": "ipv4-address",
+      "array": true
+    },
+    {
+      "name": "unknown-91",
+      "code": 91,
+      "space": "dhcp4",
+      "type": "uint32"
+    }
+  ],
+  "option-data": [
+    {
+      "name": "subnet-mask",
+      "code": 1,
+      "space": "dhcp4",
+      "csv-format": true,
+      "data": "192.168.100.1"
+    },
+    {
+      "name": "domain-name",
+      "code": 15,
+      "space": "dhcp4",
+      "csv-format": true,
+      "data": "a\\,b"
+    },
+    {
+      "name": "dhcp-client-identifier",
+      "code": 61,
+      "space": "dhcp4",
+      "csv-format": false,
+      "data": "612C62"
+    },
+    {
+      "name": "unknown-150",
+      "code": 150,
+      "space": "dhcp4",
+      "csv-format": true,
+      "data": "192.168.100.1, 192.168.100.1"
+    },
+    {
+      "name": "unknown-91",
+      "code": 91,
+      "space": "dhcp4",
+      "csv-format": true,
+      "data": "0"
+    }
+  ]
+}`)
+	if !bytes.Equal(b, expected) {
+		t.Error(string(b))
+	}
+	cfg, err := UnmarshalKea(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Options) != 5 || string(cfg.Options[2].Encode()) != string(s) {
+		t.Error(cfg.Options)
+	}
+	if _, err := MarshalKea([]Option{{OptionData: &End{}, Code: 255}}); err == nil {
+		t.Error()
+	}
+}
+
+func TestUnmarshalKeaWarnings(t *testing.T) {
+	cfg, err := UnmarshalKea([]byte(`{"Dhcp4": {
+  "option-data": [{"name": "routers", "data": "10.0.0.1"}, {"name": "foo", "code": 1, "space": "bar", "csv-format": false, "data": "01"}],
+  "subnet4": [
+    {"subnet": "10.0.0.0/24", "option-data": [{"code": 6, "data": "10.0.0.2"}]},
+    {"subnet": "10.0.1.0/24", "pools": [{"pool": "10.0.1.10 - 10.0.1.20", "option-data": [{"code": 6, "data": "10.0.1.2"}]}]}
+  ]
+}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`options in scope "subnet4" are flattened`,
+		`options in scope "subnet4 > pools" are flattened`,
+		`1 options in space "bar" are dropped because no option encapsulates the space`,
+	}
+	if strings.Join(cfg.Warnings, "\n") != strings.Join(expected, "\n") || len(cfg.Options) != 3 {
+		t.Error(cfg.Warnings, cfg.Options)
+	}
+}
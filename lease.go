@@ -0,0 +1,229 @@
+package dhop
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// leaseTimeFormat is the format of times in lease files, which follows the day of the week.
+const leaseTimeFormat = "2006/01/02 15:04:05"
+
+// Lease is a lease declaration of dhclient.leases or dhcpd.leases.
+// Leases of dhclient have no IP in the declaration, but have the fixed-address.
+type Lease struct {
+	IP           net.IP
+	Interface    string
+	HardwareAddr net.HardwareAddr
+	ClientID     []byte
+	Hostname     string
+	ServerName   string
+	Filename     string
+	// State is the binding state of dhcpd, such as "active" and "free".
+	State     string
+	NextState string
+	Starts    time.Time
+	Ends      time.Time
+	CLTT      time.Time
+	Renew     time.Time
+	Rebind    time.Time
+	Expire    time.Time
+	// Variables are the values set by `set` statements.
+	Variables map[string]string
+	Options   []Option
+	// UnknownOptions are the raw option statements whose names are not defined,
+	// such as the options which are declared in dhclient.conf.
+	UnknownOptions []string
+}
+
+// Active returns whether the lease is bound at the time.
+// Leases of dhcpd must be in the active state, and leases without the end never expire.
+func (l *Lease) Active(now time.Time) bool {
+	if l.State != "" && l.State != "active" {
+		return false
+	}
+	end := l.Ends
+	if end.IsZero() {
+		end = l.Expire
+	}
+	return end.IsZero() || end.After(now)
+}
+
+// UnmarshalLeases parses the lease declarations of dhclient.leases and dhcpd.leases
+// in the order of the file. dhcpd appends a new declaration when a lease changes,
+// so the last declaration of an address is the current one.
+func UnmarshalLeases(b []byte) ([]*Lease, error) {
+	tokens, err := tokenizeDhcpd(b)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := parseDhcpdStatements(tokens)
+	if err != nil {
+		return nil, err
+	}
+	p := newDhcpdParser()
+	leases := make([]*Lease, 0, len(statements))
+	for _, s := range statements {
+		if s.block == nil || len(s.tokens) == 0 || s.tokens[0].text != "lease" {
+			continue
+		}
+		l, err := p.parseLease(s)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, l)
+	}
+	return leases, nil
+}
+
+func (p *dhcpdParser) parseLease(s dhcpdStatement) (*Lease, error) {
+	l := &Lease{}
+	switch len(s.tokens) {
+	case 1:
+	case 2:
+		l.IP = net.ParseIP(s.tokens[1].text).To4()
+		if l.IP == nil {
+			return nil, fmt.Errorf("line %d: invalid lease address \"%s\"", s.tokens[0].line, s.tokens[1].text)
+		}
+	default:
+		return nil, fmt.Errorf("line %d: invalid lease declaration", s.tokens[0].line)
+	}
+	p.cfg = DhcpdConfig{
+		SpaceOptions: make(map[string][]SubOption),
+	}
+	p.order = nil
+	for _, st := range s.block {
+		if len(st.tokens) == 0 {
+			continue
+		}
+		if err := p.parseLeaseStatement(l, st.tokens); err != nil {
+			return nil, fmt.Errorf("line %d: %s", st.tokens[0].line, err)
+		}
+	}
+	cfg, err := p.config()
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s", s.tokens[0].line, err)
+	}
+	l.Options = cfg.Options
+	return l, nil
+}
+
+func (p *dhcpdParser) parseLeaseStatement(l *Lease, tokens []dhcpdToken) error {
+	args := tokens[1:]
+	var err error
+	switch tokens[0].text {
+	case "option":
+		if len(args) > 0 && args[0].text != "space" && !p.defined(args[0].text) && (len(args) < 2 || args[1].text != "code") {
+			l.UnknownOptions = append(l.UnknownOptions, formatDhcpdTokens(args))
+			return nil
+		}
+		return p.parseOption(args)
+	case "starts":
+		l.Starts, err = parseLeaseTime(args)
+	case "ends":
+		l.Ends, err = parseLeaseTime(args)
+	case "cltt":
+		l.CLTT, err = parseLeaseTime(args)
+	case "renew":
+		l.Renew, err = parseLeaseTime(args)
+	case "rebind":
+		l.Rebind, err = parseLeaseTime(args)
+	case "expire":
+		l.Expire, err = parseLeaseTime(args)
+	case "binding", "next":
+		if len(args) < 2 || args[len(args)-2].text != "state" {
+			return fmt.Errorf("invalid binding state")
+		}
+		if tokens[0].text == "binding" {
+			l.State = args[len(args)-1].text
+		} else {
+			l.NextState = args[len(args)-1].text
+		}
+	case "hardware":
+		if len(args) != 2 {
+			return fmt.Errorf("invalid hardware address")
+		}
+		l.HardwareAddr, err = net.ParseMAC(args[1].text)
+	case "uid":
+		if len(args) != 1 {
+			return fmt.Errorf("invalid uid")
+		}
+		if args[0].quoted {
+			l.ClientID = []byte(args[0].text)
+		} else {
+			l.ClientID, err = parseColonHex(args[0].text)
+		}
+	case "fixed-address":
+		if len(args) != 1 || net.ParseIP(args[0].text).To4() == nil {
+			return fmt.Errorf("invalid fixed-address")
+		}
+		l.IP = net.ParseIP(args[0].text).To4()
+	case "interface":
+		l.Interface, err = leaseString(args)
+	case "client-hostname":
+		l.Hostname, err = leaseString(args)
+	case "server-name":
+		l.ServerName, err = leaseString(args)
+	case "filename":
+		l.Filename, err = leaseString(args)
+	case "set":
+		if len(args) != 3 || args[1].text != "=" {
+			return fmt.Errorf("invalid set statement")
+		}
+		if l.Variables == nil {
+			l.Variables = make(map[string]string)
+		}
+		l.Variables[args[0].text] = args[2].text
+	}
+	return err
+}
+
+func (p *dhcpdParser) defined(name string) bool {
+	space, name := qualifiedDhcpdName(name)
+	_, ok := p.types[space+"."+name]
+	return ok
+}
+
+// formatDhcpdTokens returns the text of the tokens, quoting the quoted ones.
+func formatDhcpdTokens(tokens []dhcpdToken) string {
+	s := make([]string, len(tokens))
+	for i, t := range tokens {
+		if t.quoted {
+			s[i] = quoteDhcpd([]byte(t.text))
+		} else {
+			s[i] = t.text
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func leaseString(tokens []dhcpdToken) (string, error) {
+	if len(tokens) != 1 {
+		return "", fmt.Errorf("invalid string")
+	}
+	return tokens[0].text, nil
+}
+
+// parseLeaseTime parses a time such as `2 2023/01/03 04:05:06`, `epoch 1672718706` or `never`.
+// Times are in UTC.
+func parseLeaseTime(tokens []dhcpdToken) (time.Time, error) {
+	switch {
+	case len(tokens) == 1 && tokens[0].text == "never":
+		return time.Time{}, nil
+	case len(tokens) == 2 && tokens[0].text == "epoch":
+		n, err := strconv.ParseInt(tokens[1].text, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(n, 0).UTC(), nil
+	case len(tokens) == 3:
+		return time.Parse(leaseTimeFormat, tokens[1].text+" "+tokens[2].text)
+	}
+	s := make([]string, len(tokens))
+	for i, t := range tokens {
+		s[i] = t.text
+	}
+	return time.Time{}, fmt.Errorf("invalid time \"%s\"", strings.Join(s, " "))
+}
@@ -0,0 +1,69 @@
+package lease
+
+import (
+	"net"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+// Allocate, Commit, Free and Quarantine implement server.Allocator,
+// so that server.PoolHandler serves the addresses of the manager.
+// The lease time of the handler replaces LeaseTime, and MinLeaseTime and MaxLeaseTime still apply.
+
+// Allocate offers an address to the client of DHCPDISCOVER like Offer.
+func (m *Manager) Allocate(req *dhop.Message, d time.Duration) (net.IP, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, err := m.offer(req, d, m.now())
+	if err != nil {
+		return nil, 0, err
+	}
+	return l.IP, l.Duration, nil
+}
+
+// Commit binds the address which the handler chose from DHCPREQUEST like Request.
+func (m *Manager) Commit(req *dhop.Message, ip net.IP, d time.Duration) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ip.To4() == nil {
+		return 0, ErrNoAddress
+	}
+	l, err := m.bind(req, ip.To4(), d, m.now())
+	if err != nil {
+		return 0, err
+	}
+	return l.Duration, nil
+}
+
+// Free ends the offer or the lease of the address if it is held by the client of the message.
+// A lease is kept in StateReleased like Release.
+func (m *Manager) Free(req *dhop.Message, ip net.IP) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	ip = ip.To4()
+	if ip == nil {
+		return
+	}
+	l, ok := m.leases[ipToUint32(ip)]
+	if !ok || l.ClientID != ClientID(req) || !l.active(now) {
+		return
+	}
+	switch l.State {
+	case StateOffered:
+		m.save(&Lease{IP: ip, State: StateFree})
+	case StateBound:
+		r := *l
+		r.State = StateReleased
+		r.Expire = now
+		m.save(&r)
+	}
+}
+
+// Quarantine quarantines the address declined by the client of the message like Decline.
+func (m *Manager) Quarantine(req *dhop.Message, ip net.IP) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decline(req, ip, m.now())
+}
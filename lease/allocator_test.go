@@ -0,0 +1,51 @@
+package lease
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bgpat/dhop"
+	"github.com/bgpat/dhop/server"
+)
+
+var _ server.Allocator = (*Manager)(nil)
+
+func TestManagerAllocator(t *testing.T) {
+	now := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	m := newTestManager(t, &now, nil)
+	m.MaxLeaseTime = 2 * time.Hour
+	h := &server.PoolHandler{ServerID: net.IPv4(10, 0, 0, 1), Pool: m, LeaseTime: 3 * time.Hour}
+
+	offer := h.ServeDHCP(newRequest(dhop.MessageTypeDiscover, 1, nil))
+	if offer == nil || !offer.YIAddr.Equal(net.IPv4(10, 0, 0, 10)) {
+		t.Fatal("offer:", offer)
+	}
+	if o, ok := offer.Option(51); !ok || time.Duration(*o.OptionData.(*dhop.TimeDuration)) != 2*time.Hour {
+		t.Error("lease time is not limited:", o)
+	}
+	req := newRequest(dhop.MessageTypeRequest, 1, offer.YIAddr)
+	if ack := h.ServeDHCP(req); ack == nil || ack.Type() != dhop.MessageTypeAck || !ack.YIAddr.Equal(offer.YIAddr) {
+		t.Fatal("ack:", ack)
+	}
+	if nak := h.ServeDHCP(newRequest(dhop.MessageTypeRequest, 2, offer.YIAddr)); nak == nil || nak.Type() != dhop.MessageTypeNak {
+		t.Error("request of the address in use:", nak)
+	}
+
+	h.ServeDHCP(newRequest(dhop.MessageTypeDecline, 2, offer.YIAddr))
+	if leases := m.Leases(); len(leases) != 1 || leases[0].State != StateBound {
+		t.Error("declined by another client:", leases)
+	}
+	release := newRequest(dhop.MessageTypeRelease, 1, nil)
+	release.CIAddr = offer.YIAddr
+	h.ServeDHCP(release)
+	if leases := m.Leases(); len(leases) != 1 || leases[0].State != StateReleased {
+		t.Error("not released:", leases)
+	}
+
+	offer = h.ServeDHCP(newRequest(dhop.MessageTypeDiscover, 1, nil))
+	h.ServeDHCP(newRequest(dhop.MessageTypeDecline, 1, offer.YIAddr))
+	if leases := m.Leases(); len(leases) != 1 || leases[0].State != StateDeclined {
+		t.Error("not declined:", leases)
+	}
+}
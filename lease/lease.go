@@ -0,0 +1,103 @@
+// Package lease is an address allocator and lease manager for DHCPv4 servers.
+// Manager allocates the addresses of ranges except exclusions and reservations,
+// holds offers, expires leases, and quarantines declined and conflicting addresses.
+// Leases are persisted by a Store, such as MemoryStore and the append-only FileStore.
+// Manager is a server.Allocator, so server.PoolHandler can serve its addresses.
+package lease
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+// State is the state of an address in the manager.
+type State byte
+
+const (
+	// StateFree is an address without any record. Stores remove the record of a free lease.
+	StateFree State = iota
+	// StateOffered is an address reserved for the client until Expire after DHCPOFFER.
+	StateOffered
+	// StateBound is an address leased to the client until Expire.
+	StateBound
+	// StateReleased is a free address which is remembered to be offered to the last client again.
+	StateReleased
+	// StateExpired is a free address whose lease has expired, remembered like StateReleased.
+	StateExpired
+	// StateDeclined is an address which a client found in use by DHCPDECLINE, quarantined until Expire.
+	StateDeclined
+	// StateConflict is an address which the server found in use, quarantined until Expire.
+	StateConflict
+)
+
+var stateNames = []string{"free", "offered", "bound", "released", "expired", "declined", "conflict"}
+
+func (s State) String() string {
+	if int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return fmt.Sprintf("N/A (%d)", byte(s))
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(b []byte) error {
+	for i, name := range stateNames {
+		if strings.EqualFold(string(b), name) {
+			*s = State(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown lease state %q", string(b))
+}
+
+// Lease is the record of an address.
+// ClientID is the key of the client by ClientID, and is empty for quarantined addresses.
+// Duration is the lease time which is granted, or offered to the client in StateOffered.
+type Lease struct {
+	IP           net.IP           `json:"ip"`
+	ClientID     string           `json:"client_id,omitempty"`
+	HardwareAddr net.HardwareAddr `json:"-"`
+	State        State            `json:"state"`
+	Start        time.Time        `json:"start"`
+	Expire       time.Time        `json:"expire"`
+	Duration     time.Duration    `json:"-"`
+}
+
+// active returns whether the address is held at the time.
+func (l *Lease) active(now time.Time) bool {
+	switch l.State {
+	case StateOffered, StateBound, StateDeclined, StateConflict:
+		return now.Before(l.Expire)
+	}
+	return false
+}
+
+// Options returns IP Address Lease Time, Renewal (T1) Time Value and Rebinding (T2) Time Value of the lease,
+// which are 1/2 and 7/8 of the lease time (RFC 2131 section 4.4.5).
+func (l *Lease) Options() []dhop.Option {
+	lt := dhop.TimeDuration(l.Duration)
+	t1 := dhop.TimeDuration(l.Duration / 2)
+	t2 := dhop.TimeDuration(l.Duration * 7 / 8)
+	return []dhop.Option{
+		{OptionData: &lt, Code: 51},
+		{OptionData: &t1, Code: 58},
+		{OptionData: &t2, Code: 59},
+	}
+}
+
+// ClientID returns the key of the client which sent the message,
+// the hex of Client Identifier, or the hex of htype and chaddr if the option is omitted (RFC 2131 section 4.2).
+func ClientID(m *dhop.Message) string {
+	if o, ok := m.Option(61); ok {
+		return hex.EncodeToString(o.Encode())
+	}
+	return hex.EncodeToString(append([]byte{m.HType}, m.CHAddr...))
+}
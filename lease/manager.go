@@ -0,0 +1,513 @@
+package lease
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+var (
+	ErrPoolExhausted = errors.New("no free address in the ranges")
+	ErrOutOfRange    = errors.New("address is out of the ranges")
+	ErrInUse         = errors.New("address is leased to another client")
+	ErrReserved      = errors.New("address is reserved for another client")
+	ErrNotLeased     = errors.New("address is not leased to the client")
+	// ErrOtherServer is returned for DHCPREQUEST to another server, and the offer to the client is released.
+	ErrOtherServer = errors.New("client selected another server")
+	// ErrNoAddress is returned for DHCPREQUEST without Requested IP Address and ciaddr.
+	ErrNoAddress = errors.New("request has no address")
+)
+
+// Default durations of Manager.
+const (
+	DefaultLeaseTime   = time.Hour
+	DefaultOfferHold   = 30 * time.Second
+	DefaultDeclineHold = 10 * time.Minute
+)
+
+// Range is the addresses from Start to End inclusive.
+type Range struct {
+	Start net.IP
+	End   net.IP
+}
+
+// Reservation is a fixed address of the client identified by Client Identifier or chaddr.
+// The address may be out of the ranges, and it is never allocated to other clients.
+type Reservation struct {
+	ClientID     []byte
+	HardwareAddr net.HardwareAddr
+	IP           net.IP
+}
+
+// Config is the configuration of Manager.
+// ServerID is compared with Server Identifier of DHCPREQUEST if it is not nil.
+// Store is a MemoryStore if it is nil.
+type Config struct {
+	Ranges       []Range
+	Exclusions   []Range
+	Reservations []Reservation
+	ServerID     net.IP
+	Store        Store
+}
+
+// Manager allocates addresses and manages the leases. It is safe for concurrent use.
+//
+// Offer, Request, Release and Decline handle the requests of DHCPDISCOVER, DHCPREQUEST, DHCPRELEASE and DHCPDECLINE,
+// and the returned lease has yiaddr and the lease time options for the reply.
+// Addresses of released and expired leases are offered to the last client again while they are free,
+// and new clients get the addresses which have never been leased before the others (RFC 2131 section 4.3.1).
+type Manager struct {
+	// LeaseTime is the lease time if the client does not request IP Address Lease Time.
+	LeaseTime time.Duration
+	// MinLeaseTime and MaxLeaseTime limit the lease time requested by clients if they are not zero.
+	MinLeaseTime time.Duration
+	MaxLeaseTime time.Duration
+	// OfferHold is the time for which an address stays offered after DHCPOFFER.
+	OfferHold time.Duration
+	// DeclineHold is how long a declined or conflicting address is not allocated.
+	DeclineHold time.Duration
+	// Now is the clock of the manager, or time.Now if it is nil.
+	Now func() time.Time
+
+	mu           sync.Mutex
+	ranges       []ipRange
+	exclusions   []ipRange
+	serverID     net.IP
+	store        Store
+	leases       map[uint32]*Lease
+	reservations []Reservation
+	reserved     map[uint32]bool
+}
+
+type ipRange struct {
+	start, end uint32
+}
+
+func (r ipRange) contains(n uint32) bool {
+	return r.start <= n && n <= r.end
+}
+
+func newIPRange(r Range) (ipRange, error) {
+	s, e := r.Start.To4(), r.End.To4()
+	if s == nil || e == nil {
+		return ipRange{}, errors.New("range must be IPv4 addresses")
+	}
+	ir := ipRange{ipToUint32(s), ipToUint32(e)}
+	if ir.start > ir.end {
+		return ipRange{}, errors.New("range is reversed")
+	}
+	return ir, nil
+}
+
+// NewManager returns the manager of the configuration with the leases loaded from the store.
+func NewManager(c Config) (*Manager, error) {
+	m := &Manager{
+		LeaseTime:   DefaultLeaseTime,
+		OfferHold:   DefaultOfferHold,
+		DeclineHold: DefaultDeclineHold,
+		serverID:    c.ServerID.To4(),
+		store:       c.Store,
+		leases:      make(map[uint32]*Lease),
+		reserved:    make(map[uint32]bool),
+	}
+	if m.store == nil {
+		m.store = NewMemoryStore()
+	}
+	for _, r := range c.Ranges {
+		ir, err := newIPRange(r)
+		if err != nil {
+			return nil, err
+		}
+		m.ranges = append(m.ranges, ir)
+	}
+	for _, r := range c.Exclusions {
+		ir, err := newIPRange(r)
+		if err != nil {
+			return nil, err
+		}
+		m.exclusions = append(m.exclusions, ir)
+	}
+	for _, r := range c.Reservations {
+		ip := r.IP.To4()
+		if ip == nil {
+			return nil, errors.New("reserved address must be an IPv4 address")
+		}
+		if r.ClientID == nil && r.HardwareAddr == nil {
+			return nil, errors.New("reservation requires a client identifier or a hardware address")
+		}
+		n := ipToUint32(ip)
+		if m.reserved[n] {
+			return nil, errors.New("address is reserved twice: " + ip.String())
+		}
+		m.reserved[n] = true
+		r.IP = ip
+		m.reservations = append(m.reservations, r)
+	}
+	leases, err := m.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range leases {
+		l := leases[i]
+		if l.IP.To4() == nil {
+			continue
+		}
+		m.leases[ipToUint32(l.IP)] = &l
+	}
+	return m, nil
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+// dynamic returns whether the address is allocated from the ranges.
+func (m *Manager) dynamic(n uint32) bool {
+	if m.reserved[n] {
+		return false
+	}
+	for _, r := range m.exclusions {
+		if r.contains(n) {
+			return false
+		}
+	}
+	for _, r := range m.ranges {
+		if r.contains(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// reservation returns the address reserved for the client of the message.
+func (m *Manager) reservation(req *dhop.Message) net.IP {
+	id, hasID := req.Option(61)
+	for _, r := range m.reservations {
+		if r.ClientID != nil && hasID && bytes.Equal(r.ClientID, id.Encode()) {
+			return r.IP
+		}
+	}
+	for _, r := range m.reservations {
+		if r.HardwareAddr != nil && bytes.Equal(r.HardwareAddr, req.CHAddr) {
+			return r.IP
+		}
+	}
+	return nil
+}
+
+// usable returns whether the address can be leased to the client.
+func (m *Manager) usable(n uint32, client string, now time.Time) bool {
+	l, ok := m.leases[n]
+	return !ok || !l.active(now) || (l.ClientID == client && (l.State == StateOffered || l.State == StateBound))
+}
+
+// leaseTime returns the lease time for the requested IP Address Lease Time, or d if it is not requested.
+func (m *Manager) leaseTime(req *dhop.Message, d time.Duration) time.Duration {
+	if o, ok := req.Option(51); ok {
+		if t, ok := o.OptionData.(*dhop.TimeDuration); ok && *t > 0 {
+			d = time.Duration(*t)
+		}
+	}
+	if m.MinLeaseTime > 0 && d < m.MinLeaseTime {
+		d = m.MinLeaseTime
+	}
+	if m.MaxLeaseTime > 0 && d > m.MaxLeaseTime {
+		d = m.MaxLeaseTime
+	}
+	return d
+}
+
+// save writes the lease to the store and replaces the record in memory.
+func (m *Manager) save(l *Lease) error {
+	if err := m.store.Save(*l); err != nil {
+		return err
+	}
+	n := ipToUint32(l.IP)
+	if l.State == StateFree {
+		delete(m.leases, n)
+	} else {
+		m.leases[n] = l
+	}
+	return nil
+}
+
+// Offer reserves an address for the client of DHCPDISCOVER for OfferHold.
+// The address is the reserved address, the address which the client holds or held last,
+// Requested IP Address if it is free, or a free address in the ranges.
+func (m *Manager) Offer(req *dhop.Message) (*Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offer(req, m.LeaseTime, m.now())
+}
+
+func (m *Manager) offer(req *dhop.Message, d time.Duration, now time.Time) (*Lease, error) {
+	client := ClientID(req)
+
+	var ip net.IP
+	if r := m.reservation(req); r != nil {
+		if !m.usable(ipToUint32(r), client, now) {
+			return nil, ErrInUse
+		}
+		ip = r
+	} else {
+		n, ok := m.allocate(client, req.RequestedIPAddress(), now)
+		if !ok {
+			return nil, ErrPoolExhausted
+		}
+		ip = uint32ToIP(n)
+	}
+	d = m.leaseTime(req, d)
+	if l, ok := m.leases[ipToUint32(ip)]; ok && l.State == StateBound && l.ClientID == client && l.active(now) {
+		// The client is bound already, and DHCPREQUEST will extend the lease.
+		c := *l
+		c.Duration = d
+		return &c, nil
+	}
+	l := &Lease{
+		IP:           ip,
+		ClientID:     client,
+		HardwareAddr: req.CHAddr,
+		State:        StateOffered,
+		Start:        now,
+		Expire:       now.Add(m.OfferHold),
+		Duration:     d,
+	}
+	if err := m.save(l); err != nil {
+		return nil, err
+	}
+	c := *l
+	return &c, nil
+}
+
+// allocate returns a dynamic address for the client.
+func (m *Manager) allocate(client string, requested net.IP, now time.Time) (uint32, bool) {
+	var last *Lease
+	for _, l := range m.leases {
+		if l.ClientID != client || !m.dynamic(ipToUint32(l.IP)) {
+			continue
+		}
+		if l.active(now) {
+			return ipToUint32(l.IP), true
+		}
+		if l.State != StateDeclined && l.State != StateConflict && (last == nil || l.Expire.After(last.Expire)) {
+			last = l
+		}
+	}
+	if last != nil {
+		return ipToUint32(last.IP), true
+	}
+	if requested.To4() != nil {
+		n := ipToUint32(requested)
+		if m.dynamic(n) && m.usable(n, client, now) {
+			return n, true
+		}
+	}
+	// The address which has never been leased, or the address whose lease ended the earliest.
+	var oldest *Lease
+	for _, r := range m.ranges {
+		for n := r.start; ; n++ {
+			if m.dynamic(n) {
+				l, ok := m.leases[n]
+				if !ok {
+					return n, true
+				}
+				if !l.active(now) && (oldest == nil || l.Expire.Before(oldest.Expire)) {
+					oldest = l
+				}
+			}
+			if n == r.end {
+				break
+			}
+		}
+	}
+	if oldest == nil {
+		return 0, false
+	}
+	return ipToUint32(oldest.IP), true
+}
+
+// Request binds the address of DHCPREQUEST, Requested IP Address or ciaddr, to the client.
+// ErrOtherServer is returned if the client selected another server.
+// The other errors mean that the server should reply DHCPNAK.
+func (m *Manager) Request(req *dhop.Message) (*Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	client := ClientID(req)
+
+	ip := req.RequestedIPAddress()
+	if id := req.ServerIdentifier(); id != nil && m.serverID != nil && !id.Equal(m.serverID) {
+		for n, l := range m.leases {
+			if l.ClientID == client && l.State == StateOffered && l.active(now) {
+				if err := m.save(&Lease{IP: uint32ToIP(n), State: StateFree}); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return nil, ErrOtherServer
+	}
+	if ip == nil && req.CIAddr != nil && !req.CIAddr.IsUnspecified() {
+		// RENEWING or REBINDING
+		ip = req.CIAddr
+	}
+	if ip.To4() == nil {
+		return nil, ErrNoAddress
+	}
+	return m.bind(req, ip.To4(), m.LeaseTime, now)
+}
+
+// bind leases the address to the client of DHCPREQUEST, and d is the lease time unless it is requested.
+func (m *Manager) bind(req *dhop.Message, ip net.IP, d time.Duration, now time.Time) (*Lease, error) {
+	client := ClientID(req)
+	n := ipToUint32(ip)
+	if r := m.reservation(req); r != nil {
+		if !r.Equal(ip) {
+			return nil, ErrReserved
+		}
+	} else if m.reserved[n] {
+		return nil, ErrReserved
+	} else if !m.dynamic(n) {
+		return nil, ErrOutOfRange
+	}
+	if !m.usable(n, client, now) {
+		return nil, ErrInUse
+	}
+
+	d = m.leaseTime(req, d)
+	if l, ok := m.leases[n]; ok && l.ClientID == client && l.State == StateOffered && l.active(now) {
+		if _, requested := req.Option(51); !requested {
+			d = l.Duration
+		}
+	}
+	// A client holds one address, so its other offers and leases end.
+	for k, l := range m.leases {
+		if k != n && l.ClientID == client && (l.State == StateOffered || l.State == StateBound) && l.active(now) {
+			if err := m.save(&Lease{IP: uint32ToIP(k), ClientID: client, HardwareAddr: l.HardwareAddr, State: StateReleased, Expire: now}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	l := &Lease{
+		IP:           ip,
+		ClientID:     client,
+		HardwareAddr: req.CHAddr,
+		State:        StateBound,
+		Start:        now,
+		Expire:       now.Add(d),
+		Duration:     d,
+	}
+	if err := m.save(l); err != nil {
+		return nil, err
+	}
+	c := *l
+	return &c, nil
+}
+
+// Release frees the address of ciaddr of DHCPRELEASE if it is leased to the client.
+// The address is remembered to be offered to the client again.
+func (m *Manager) Release(req *dhop.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	ip := req.CIAddr.To4()
+	if ip == nil {
+		return ErrNotLeased
+	}
+	l, ok := m.leases[ipToUint32(ip)]
+	if !ok || l.ClientID != ClientID(req) || l.State != StateBound || !l.active(now) {
+		return ErrNotLeased
+	}
+	r := *l
+	r.State = StateReleased
+	r.Expire = now
+	return m.save(&r)
+}
+
+// Decline quarantines Requested IP Address of DHCPDECLINE for DeclineHold if it is leased to the client.
+func (m *Manager) Decline(req *dhop.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.decline(req, req.RequestedIPAddress(), m.now())
+}
+
+func (m *Manager) decline(req *dhop.Message, ip net.IP, now time.Time) error {
+	ip = ip.To4()
+	if ip == nil {
+		return ErrNotLeased
+	}
+	l, ok := m.leases[ipToUint32(ip)]
+	if !ok || l.ClientID != ClientID(req) || !l.active(now) {
+		return ErrNotLeased
+	}
+	return m.quarantine(ip, StateDeclined, now)
+}
+
+// Conflict quarantines the address for DeclineHold because the server found it in use,
+// such as by ICMP echo before DHCPOFFER. The lease of the address is discarded.
+func (m *Manager) Conflict(ip net.IP) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ip = ip.To4()
+	if ip == nil {
+		return ErrOutOfRange
+	}
+	return m.quarantine(ip, StateConflict, m.now())
+}
+
+func (m *Manager) quarantine(ip net.IP, s State, now time.Time) error {
+	return m.save(&Lease{
+		IP:     ip,
+		State:  s,
+		Start:  now,
+		Expire: now.Add(m.DeclineHold),
+	})
+}
+
+// Expire updates the records whose time has passed, and returns the leases which have expired.
+// Expired offers and quarantines are removed, and expired leases are kept in StateExpired.
+// The other methods treat them as free without calling Expire.
+func (m *Manager) Expire() ([]Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	expired := make([]Lease, 0)
+	for n, l := range m.leases {
+		if l.active(now) {
+			continue
+		}
+		switch l.State {
+		case StateBound:
+			r := *l
+			r.State = StateExpired
+			if err := m.save(&r); err != nil {
+				return nil, err
+			}
+			expired = append(expired, r)
+		case StateOffered, StateDeclined, StateConflict:
+			if err := m.save(&Lease{IP: uint32ToIP(n), State: StateFree}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sortLeases(expired)
+	return expired, nil
+}
+
+// Leases returns the records of the addresses in the order of the addresses.
+func (m *Manager) Leases() []Lease {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	leases := make([]Lease, 0, len(m.leases))
+	for _, l := range m.leases {
+		leases = append(leases, *l)
+	}
+	sortLeases(leases)
+	return leases
+}
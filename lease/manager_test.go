@@ -0,0 +1,179 @@
+package lease
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+func newRequest(t dhop.MessageType, mac byte, requested net.IP) *dhop.Message {
+	typ := dhop.Byte(t)
+	m := &dhop.Message{
+		Op:      dhop.OpRequest,
+		HType:   1,
+		HLen:    6,
+		CIAddr:  net.IPv4zero,
+		CHAddr:  net.HardwareAddr{0x52, 0x54, 0, 0, 0, mac},
+		Options: []dhop.Option{{OptionData: &typ, Code: 53}},
+	}
+	if requested != nil {
+		ip := dhop.IPv4(requested.To4())
+		m.Options = append(m.Options, dhop.Option{OptionData: &ip, Code: 50})
+	}
+	return m
+}
+
+func newTestManager(t *testing.T, now *time.Time, store Store) *Manager {
+	m, err := NewManager(Config{
+		Ranges:     []Range{{net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 14)}},
+		Exclusions: []Range{{net.IPv4(10, 0, 0, 11), net.IPv4(10, 0, 0, 11)}},
+		Reservations: []Reservation{
+			{HardwareAddr: net.HardwareAddr{0x52, 0x54, 0, 0, 0, 0xff}, IP: net.IPv4(10, 0, 0, 100)},
+			{ClientID: []byte("host-12"), IP: net.IPv4(10, 0, 0, 12)},
+		},
+		ServerID: net.IPv4(10, 0, 0, 1),
+		Store:    store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Now = func() time.Time { return *now }
+	return m
+}
+
+func TestManager(t *testing.T) {
+	now := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	m := newTestManager(t, &now, nil)
+
+	l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 1, nil))
+	if err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 10)) || l.State != StateOffered || l.Duration != DefaultLeaseTime {
+		t.Fatal(l, err)
+	}
+	// 10.0.0.11 is excluded and 10.0.0.12 is reserved.
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 2, net.IPv4(10, 0, 0, 11))); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 13)) {
+		t.Error("offer:", l, err)
+	}
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 0xff, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 100)) {
+		t.Error("reservation by chaddr:", l, err)
+	}
+	req := newRequest(dhop.MessageTypeDiscover, 3, nil)
+	id := dhop.String("host-12")
+	req.Options = append(req.Options, dhop.Option{OptionData: &id, Code: 61})
+	if l, err := m.Offer(req); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 12)) {
+		t.Error("reservation by client identifier:", l, err)
+	}
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 4, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 14)) {
+		t.Error("offer:", l, err)
+	}
+	if _, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 5, nil)); err != ErrPoolExhausted {
+		t.Error("exhausted:", err)
+	}
+
+	req = newRequest(dhop.MessageTypeRequest, 1, net.IPv4(10, 0, 0, 10))
+	sid := dhop.IPv4(net.IPv4(10, 0, 0, 1).To4())
+	lt := dhop.TimeDuration(2 * time.Hour)
+	req.Options = append(req.Options, dhop.Option{OptionData: &sid, Code: 54}, dhop.Option{OptionData: &lt, Code: 51})
+	m.MaxLeaseTime = 90 * time.Minute
+	l, err = m.Request(req)
+	if err != nil || l.State != StateBound || l.Duration != 90*time.Minute || !l.Expire.Equal(now.Add(90*time.Minute)) {
+		t.Fatal("request:", l, err)
+	}
+	if o := l.Options(); len(o) != 3 || o[1].Code != 58 || time.Duration(*o[1].OptionData.(*dhop.TimeDuration)) != 45*time.Minute {
+		t.Error("options:", o)
+	}
+	if _, err := m.Request(newRequest(dhop.MessageTypeRequest, 2, net.IPv4(10, 0, 0, 10))); err != ErrInUse {
+		t.Error("request in use:", err)
+	}
+	if _, err := m.Request(newRequest(dhop.MessageTypeRequest, 2, net.IPv4(10, 0, 0, 12))); err != ErrReserved {
+		t.Error("request reserved:", err)
+	}
+	if _, err := m.Request(newRequest(dhop.MessageTypeRequest, 2, net.IPv4(10, 0, 0, 11))); err != ErrOutOfRange {
+		t.Error("request excluded:", err)
+	}
+	if _, err := m.Request(newRequest(dhop.MessageTypeRequest, 2, nil)); err != ErrNoAddress {
+		t.Error("request without address:", err)
+	}
+	req = newRequest(dhop.MessageTypeRequest, 4, net.IPv4(10, 0, 0, 14))
+	other := dhop.IPv4(net.IPv4(10, 0, 0, 2).To4())
+	req.Options = append(req.Options, dhop.Option{OptionData: &other, Code: 54})
+	if _, err := m.Request(req); err != ErrOtherServer {
+		t.Error("other server:", err)
+	}
+	// The offer to 10.0.0.14 is released, and it is offered to a new client.
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 5, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 14)) {
+		t.Error("offer after selecting:", l, err)
+	}
+
+	// RENEWING
+	req = newRequest(dhop.MessageTypeRequest, 1, nil)
+	req.CIAddr = net.IPv4(10, 0, 0, 10)
+	now = now.Add(30 * time.Minute)
+	if l, err := m.Request(req); err != nil || !l.Expire.Equal(now.Add(time.Hour)) {
+		t.Error("renew:", l, err)
+	}
+
+	req = newRequest(dhop.MessageTypeRelease, 1, nil)
+	req.CIAddr = net.IPv4(10, 0, 0, 10)
+	if err := m.Release(newRequest(dhop.MessageTypeRelease, 2, nil)); err != ErrNotLeased {
+		t.Error("release by another client:", err)
+	}
+	if err := m.Release(req); err != nil {
+		t.Error("release:", err)
+	}
+	// The released address is offered to the last client again.
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 1, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 10)) {
+		t.Error("offer after release:", l, err)
+	}
+	if err := m.Decline(newRequest(dhop.MessageTypeDecline, 1, net.IPv4(10, 0, 0, 10))); err != nil {
+		t.Error("decline:", err)
+	}
+	if err := m.Conflict(net.IPv4(10, 0, 0, 13)); err != nil {
+		t.Error("conflict:", err)
+	}
+	// The offer to 10.0.0.14 has expired, and the quarantined addresses are skipped.
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 1, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 14)) {
+		t.Error("quarantine:", l, err)
+	}
+
+	now = now.Add(DefaultDeclineHold)
+	expired, err := m.Expire()
+	if err != nil || len(expired) != 0 {
+		t.Error("expire:", expired, err)
+	}
+	if leases := m.Leases(); len(leases) != 0 {
+		t.Error("leases after expiry:", leases)
+	}
+}
+
+func TestManagerExpire(t *testing.T) {
+	now := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	m := newTestManager(t, &now, nil)
+	for i := byte(1); i <= 3; i++ {
+		l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, i, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Request(newRequest(dhop.MessageTypeRequest, i, l.IP)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+	}
+	if _, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 4, nil)); err != ErrPoolExhausted {
+		t.Error("exhausted:", err)
+	}
+	now = now.Add(time.Hour - 2*time.Minute)
+	expired, err := m.Expire()
+	if err != nil || len(expired) != 2 || expired[0].State != StateExpired || !expired[1].IP.Equal(net.IPv4(10, 0, 0, 13)) {
+		t.Error("expire:", expired, err)
+	}
+	// The address of the oldest expired lease is offered to a new client.
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 4, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 10)) {
+		t.Error("offer after expiry:", l, err)
+	}
+	// The client of the expired lease gets the same address again.
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 2, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 13)) {
+		t.Error("offer to the last client:", l, err)
+	}
+}
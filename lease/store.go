@@ -0,0 +1,201 @@
+package lease
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store persists the leases of a manager.
+// Save is called for each change with the new record of the address,
+// and a lease in StateFree removes the record.
+type Store interface {
+	Load() ([]Lease, error)
+	Save(l Lease) error
+}
+
+// MemoryStore is a Store which keeps the leases in memory.
+// It is safe for concurrent use.
+type MemoryStore struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{leases: make(map[string]Lease)}
+}
+
+func (s *MemoryStore) Load() ([]Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	leases := make([]Lease, 0, len(s.leases))
+	for _, l := range s.leases {
+		leases = append(leases, l)
+	}
+	sortLeases(leases)
+	return leases, nil
+}
+
+func (s *MemoryStore) Save(l Lease) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l.State == StateFree {
+		delete(s.leases, l.IP.String())
+	} else {
+		s.leases[l.IP.String()] = l
+	}
+	return nil
+}
+
+// FileStore is a Store which appends a JSON line to the file for each change.
+// Load replays the lines, so the last record of an address wins.
+// Compact rewrites the file with the current records.
+type FileStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+type fileRecord struct {
+	Lease
+	HardwareAddr string  `json:"hardware_addr,omitempty"`
+	Duration     float64 `json:"duration,omitempty"`
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+func (s *FileStore) Load() ([]Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *FileStore) load() ([]Lease, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return []Lease{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string]Lease)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r fileRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, err
+		}
+		l := r.Lease
+		if r.HardwareAddr != "" {
+			if l.HardwareAddr, err = net.ParseMAC(r.HardwareAddr); err != nil {
+				return nil, err
+			}
+		}
+		l.Duration = time.Duration(r.Duration * float64(time.Second))
+		if l.State == StateFree {
+			delete(records, l.IP.String())
+		} else {
+			records[l.IP.String()] = l
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	leases := make([]Lease, 0, len(records))
+	for _, l := range records {
+		leases = append(leases, l)
+	}
+	sortLeases(leases)
+	return leases, nil
+}
+
+func (s *FileStore) Save(l Lease) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := writeRecord(f, l); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Compact rewrites the file with a line for each address, and replaces the file atomically.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	leases, err := s.load()
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".")
+	if err != nil {
+		return err
+	}
+	for _, l := range leases {
+		if err := writeRecord(f, l); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+func writeRecord(f *os.File, l Lease) error {
+	r := fileRecord{Lease: l, Duration: l.Duration.Seconds()}
+	if l.HardwareAddr != nil {
+		r.HardwareAddr = l.HardwareAddr.String()
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+func sortLeases(leases []Lease) {
+	sort.Slice(leases, func(i, j int) bool {
+		return ipToUint32(leases[i].IP) < ipToUint32(leases[j].IP)
+	})
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	ip = ip.To4()
+	if ip == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(ip)
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
@@ -0,0 +1,73 @@
+package lease
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dhop-lease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "leases.jsonl")
+
+	now := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	m := newTestManager(t, &now, NewFileStore(path))
+	l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Request(newRequest(dhop.MessageTypeRequest, 1, l.IP)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 2, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Conflict(net.IPv4(10, 0, 0, 14)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n != 4 {
+		t.Errorf("%d lines are appended:\n%s", n, b)
+	}
+
+	m = newTestManager(t, &now, NewFileStore(path))
+	leases := m.Leases()
+	if len(leases) != 3 || !leases[0].IP.Equal(net.IPv4(10, 0, 0, 10)) || leases[0].State != StateBound ||
+		leases[0].Duration != time.Hour || leases[0].HardwareAddr.String() != "52:54:00:00:00:01" ||
+		!leases[0].Expire.Equal(now.Add(time.Hour)) || leases[1].State != StateOffered || leases[2].State != StateConflict {
+		t.Error("loaded leases:", leases)
+	}
+	if err := NewFileStore(path).Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || strings.Count(string(b), "\n") != 3 {
+		t.Error("compact:", string(b), err)
+	}
+	if l, err := m.Offer(newRequest(dhop.MessageTypeDiscover, 1, nil)); err != nil || !l.IP.Equal(net.IPv4(10, 0, 0, 10)) {
+		t.Error("offer after load:", l, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	s.Save(Lease{IP: net.IPv4(10, 0, 0, 2), State: StateBound})
+	s.Save(Lease{IP: net.IPv4(10, 0, 0, 1), State: StateDeclined})
+	s.Save(Lease{IP: net.IPv4(10, 0, 0, 2), State: StateFree})
+	if leases, err := s.Load(); err != nil || len(leases) != 1 || leases[0].State != StateDeclined {
+		t.Error(leases, err)
+	}
+}
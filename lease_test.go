@@ -0,0 +1,154 @@
+package dhop
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	dhclientLeases = []byte(`default-duid "\000\001\000\001";
+lease {
+  interface "eth0";
+  fixed-address 10.0.0.5;
+  server-name "boot";
+  option subnet-mask 255.255.255.0;
+  option routers 10.0.0.1;
+  option dhcp-lease-time 3600;
+  option domain-name-servers 10.0.0.2,10.0.0.3;
+  option domain-name "example.com";
+  renew 2 2023/01/03 04:35:06;
+  rebind 2 2023/01/03 04:57:36;
+  expire 2 2023/01/03 05:05:06;
+}
+lease {
+  interface "eth0";
+  fixed-address 10.0.0.6;
+  option dhcp-lease-time 3600;
+  renew 2 2023/01/03 05:35:06;
+  expire epoch 1672729506; # Tue Jan  3 07:05:06 2023
+}
+`)
+	dhcpdLeases = []byte(`# The format of this file is documented in the dhcpd.leases(5) manual page.
+authoring-byte-order little-endian;
+
+lease 10.0.0.5 {
+  starts 2 2023/01/03 04:05:06;
+  ends 2 2023/01/03 05:05:06;
+  cltt 2 2023/01/03 04:05:06;
+  binding state active;
+  next binding state free;
+  rewind binding state free;
+  hardware ethernet 00:11:22:33:44:55;
+  uid "\001\000\021\"3DU";
+  set vendor-class-identifier = "MSFT 5.0";
+  client-hostname "host";
+}
+lease 10.0.0.6 {
+  starts 2 2023/01/03 04:05:06;
+  ends never;
+  binding state free;
+  hardware ethernet 00:11:22:33:44:66;
+  uid 01:00:11:22:33:44:66;
+  option agent.circuit-id "eth0";
+}
+server-duid "\000\001\000\001";
+`)
+)
+
+func TestUnmarshalDhclientLeases(t *testing.T) {
+	leases, err := UnmarshalLeases(dhclientLeases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 {
+		t.Fatal(leases)
+	}
+	l := leases[0]
+	if l.IP.String() != "10.0.0.5" || l.Interface != "eth0" || l.ServerName != "boot" || len(l.Options) != 5 {
+		t.Error(l)
+	}
+	if string(l.Options[3].Marshal()) != "10.0.0.2,10.0.0.3" || string(l.Options[2].Marshal()) != "1h0m0s" {
+		t.Errorf("%s %s", l.Options[3].Marshal(), l.Options[2].Marshal())
+	}
+	if !l.Expire.Equal(time.Date(2023, 1, 3, 5, 5, 6, 0, time.UTC)) || !l.Renew.Equal(time.Date(2023, 1, 3, 4, 35, 6, 0, time.UTC)) {
+		t.Error(l.Expire, l.Renew)
+	}
+	if !l.Active(time.Date(2023, 1, 3, 5, 0, 0, 0, time.UTC)) || l.Active(time.Date(2023, 1, 3, 6, 0, 0, 0, time.UTC)) {
+		t.Error(l.Expire)
+	}
+	if !leases[1].Expire.Equal(time.Date(2023, 1, 3, 7, 5, 6, 0, time.UTC)) || len(leases[1].Options) != 1 {
+		t.Error(leases[1])
+	}
+}
+
+func TestUnmarshalDhcpdLeases(t *testing.T) {
+	leases, err := UnmarshalLeases(dhcpdLeases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 {
+		t.Fatal(leases)
+	}
+	l := leases[0]
+	if l.IP.String() != "10.0.0.5" || l.HardwareAddr.String() != "00:11:22:33:44:55" || l.Hostname != "host" {
+		t.Error(l)
+	}
+	if string(l.ClientID) != "\x01\x00\x11\x22\x33\x44\x55" || l.Variables["vendor-class-identifier"] != "MSFT 5.0" {
+		t.Errorf("%q %v", l.ClientID, l.Variables)
+	}
+	if l.State != "active" || l.NextState != "free" || !l.Ends.Equal(time.Date(2023, 1, 3, 5, 5, 6, 0, time.UTC)) {
+		t.Error(l.State, l.NextState, l.Ends)
+	}
+	if !l.Active(time.Date(2023, 1, 3, 5, 0, 0, 0, time.UTC)) {
+		t.Error(l.Ends)
+	}
+	l = leases[1]
+	if !l.Ends.IsZero() || l.Active(time.Now()) || string(l.ClientID) != "\x01\x00\x11\x22\x33\x44\x66" {
+		t.Error(l)
+	}
+	if len(l.Options) != 1 || l.Options[0].Code != 82 || string(l.Options[0].Encode()) != "\x01\x04eth0" {
+		t.Error(l.Options)
+	}
+}
+
+func TestUnmarshalLeasesError(t *testing.T) {
+	for _, s := range []string{
+		`lease 10.0.0.5 { starts 2 2023/01/03; }`,
+		`lease example.com { }`,
+		`lease { option routers example.com; }`,
+		`lease 10.0.0.5 { hardware ethernet xx; }`,
+		`lease 10.0.0.5 { binding active; }`,
+		`lease 10.0.0.5 {`,
+	} {
+		if _, err := UnmarshalLeases([]byte(s)); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestUnmarshalLeasesUnknownOption(t *testing.T) {
+	leases, err := UnmarshalLeases([]byte(`lease {
+  fixed-address 10.0.0.5;
+  option wpad "http://wpad/wpad.dat";
+  option routers 10.0.0.1;
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := leases[0]
+	if len(l.UnknownOptions) != 1 || l.UnknownOptions[0] != `wpad "http://wpad/wpad.dat"` {
+		t.Error(l.UnknownOptions)
+	}
+	if len(l.Options) != 1 || l.Options[0].Code != 3 {
+		t.Error(l.Options)
+	}
+}
+
+func TestUnmarshalLeasesErrorLine(t *testing.T) {
+	_, err := UnmarshalLeases([]byte("lease {\n  fixed-address 10.0.0.5;\n  option routers example.com;\n}\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3: ") || strings.Count(err.Error(), "line ") != 1 {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,210 @@
+package dhop
+
+import (
+	"fmt"
+	"net"
+)
+
+type Severity string
+
+const (
+	// SeverityError is a violation of MUST or MUST NOT.
+	SeverityError Severity = "error"
+	// SeverityWarning is a violation of SHOULD NOT, or a suspicious message.
+	SeverityWarning Severity = "warning"
+)
+
+// Violation is a rule of RFC 2131 which a message breaks.
+// Code is the option of the rule, or 0 if Field is the header field of the rule.
+type Violation struct {
+	Severity Severity `json:"severity"`
+	Code     Code     `json:"code,omitempty"`
+	Field    string   `json:"field,omitempty"`
+	Message  string   `json:"message"`
+}
+
+type presence byte
+
+const (
+	may presence = iota
+	must
+	mustNot
+	shouldNot
+)
+
+type lintRule struct {
+	op      byte
+	options map[Code]presence
+	// others is the presence of the options which are not in options.
+	others presence
+}
+
+// lintRules are the options in each message type of RFC 2131 Table 3 and Table 5.
+// Client Identifier and Relay Agent Information may be echoed in every reply (RFC 6842, RFC 3046).
+// The options whose presence depends on the state of the client are checked in lintContext.
+var lintRules = map[MessageType]lintRule{
+	MessageTypeDiscover: {OpRequest, map[Code]presence{53: must, 54: mustNot, 56: shouldNot}, may},
+	MessageTypeRequest:  {OpRequest, map[Code]presence{53: must, 56: shouldNot}, may},
+	MessageTypeDecline: {OpRequest, map[Code]presence{
+		50: must, 53: must, 54: must, 56: may, 61: may, 82: may,
+	}, mustNot},
+	MessageTypeRelease: {OpRequest, map[Code]presence{
+		50: mustNot, 53: must, 54: must, 56: may, 61: may, 82: may,
+	}, mustNot},
+	MessageTypeInform: {OpRequest, map[Code]presence{
+		50: mustNot, 51: mustNot, 53: must, 54: mustNot, 56: shouldNot,
+	}, may},
+	MessageTypeOffer: {OpReply, map[Code]presence{
+		50: mustNot, 51: must, 53: must, 54: must, 55: mustNot, 56: may, 57: mustNot,
+	}, may},
+	MessageTypeAck: {OpReply, map[Code]presence{
+		50: mustNot, 53: must, 54: must, 55: mustNot, 56: may, 57: mustNot,
+	}, may},
+	MessageTypeNak: {OpReply, map[Code]presence{
+		53: must, 54: must, 56: may, 60: may, 61: may, 82: may,
+	}, mustNot},
+}
+
+// Lint checks the presence of options and the header fields in each message type (RFC 2131 Table 3 and Table 5),
+// and duplicated options.
+func Lint(m *Message) []Violation {
+	violations := make([]Violation, 0)
+	add := func(s Severity, code Code, field, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			Severity: s,
+			Code:     code,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	violations = append(violations, lintDuplicates(m)...)
+	t := m.Type()
+	if _, ok := m.Option(53); !ok {
+		add(SeverityError, 53, "", "DHCP Message Type is missing")
+		return violations
+	}
+	rule, ok := lintRules[t]
+	if !ok {
+		add(SeverityError, 53, "", "unknown message type %d", byte(t))
+		return violations
+	}
+	if m.Op != rule.op {
+		add(SeverityError, 0, "op", "%s has op %d", t, m.Op)
+	}
+
+	options := make(map[Code]presence, len(rule.options))
+	for code, p := range rule.options {
+		options[code] = p
+	}
+	for code, p := range lintContext(m, t) {
+		options[code] = p
+	}
+	for code := Code(1); code < 255; code++ {
+		if _, ok := m.Option(code); !ok && options[code] == must {
+			add(SeverityError, code, "", "option %d (%s) MUST appear in %s", code, code.String(), t)
+		}
+	}
+	seen := make(map[Code]bool)
+	for _, o := range m.Options {
+		if seen[o.Code] {
+			continue
+		}
+		seen[o.Code] = true
+		p, ok := options[o.Code]
+		if !ok {
+			p = rule.others
+		}
+		switch p {
+		case mustNot:
+			add(SeverityError, o.Code, "", "option %d (%s) MUST NOT appear in %s", o.Code, o.Code.String(), t)
+		case shouldNot:
+			add(SeverityWarning, o.Code, "", "option %d (%s) SHOULD NOT appear in %s", o.Code, o.Code.String(), t)
+		}
+	}
+
+	for _, f := range lintFields(m, t) {
+		add(SeverityError, 0, f.name, "%s MUST be %s in %s", f.name, f.expected, t)
+	}
+	return violations
+}
+
+// lintContext returns the presence of the options which depends on the header fields.
+func lintContext(m *Message, t MessageType) map[Code]presence {
+	switch t {
+	case MessageTypeRequest:
+		if isUnspecified(m.CIAddr) {
+			// SELECTING or INIT-REBOOT
+			return map[Code]presence{50: must}
+		}
+		// BOUND, RENEWING or REBINDING
+		return map[Code]presence{50: mustNot, 54: mustNot}
+	case MessageTypeAck:
+		if isUnspecified(m.YIAddr) {
+			// DHCPACK to DHCPINFORM
+			return map[Code]presence{51: mustNot}
+		}
+		return map[Code]presence{51: must}
+	}
+	return nil
+}
+
+type lintField struct {
+	name     string
+	expected string
+}
+
+// lintFields returns the header fields which have invalid values.
+func lintFields(m *Message, t MessageType) []lintField {
+	var fields []lintField
+	zero := func(name string, ip net.IP) {
+		if !isUnspecified(ip) {
+			fields = append(fields, lintField{name, "0"})
+		}
+	}
+	nonzero := func(name string, ip net.IP) {
+		if isUnspecified(ip) {
+			fields = append(fields, lintField{name, "the address of the client"})
+		}
+	}
+	switch t {
+	case MessageTypeDiscover, MessageTypeDecline:
+		zero("ciaddr", m.CIAddr)
+	case MessageTypeRelease, MessageTypeInform:
+		nonzero("ciaddr", m.CIAddr)
+	case MessageTypeOffer:
+		zero("ciaddr", m.CIAddr)
+		nonzero("yiaddr", m.YIAddr)
+	case MessageTypeNak:
+		zero("ciaddr", m.CIAddr)
+		zero("yiaddr", m.YIAddr)
+		zero("siaddr", m.SIAddr)
+	}
+	if m.Op == OpRequest {
+		zero("yiaddr", m.YIAddr)
+		zero("siaddr", m.SIAddr)
+	}
+	return fields
+}
+
+// lintDuplicates reports options which appear more than once.
+// Long options split by RFC 3396 are joined by Decode, so they are not reported.
+func lintDuplicates(m *Message) []Violation {
+	var violations []Violation
+	counts := make(map[Code]int)
+	for _, o := range m.Options {
+		counts[o.Code]++
+	}
+	reported := make(map[Code]bool)
+	for _, o := range m.Options {
+		if counts[o.Code] == 1 || reported[o.Code] {
+			continue
+		}
+		reported[o.Code] = true
+		violations = append(violations, Violation{
+			Severity: SeverityError,
+			Code:     o.Code,
+			Message:  fmt.Sprintf("option %d (%s) appears %d times", o.Code, o.Code.String(), counts[o.Code]),
+		})
+	}
+	return violations
+}
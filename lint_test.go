@@ -0,0 +1,73 @@
+package dhop
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func lintMessages(violations []Violation) string {
+	s := make([]string, len(violations))
+	for i, v := range violations {
+		s[i] = string(v.Severity) + ": " + v.Message
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestLint(t *testing.T) {
+	offer, err := NewReply(replyRequest(MessageTypeDiscover), MessageTypeOffer, replyServerOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer.YIAddr = net.IPv4(10, 0, 0, 100)
+	if v := Lint(offer); len(v) != 0 {
+		t.Error(lintMessages(v))
+	}
+
+	requested := IPv4(net.IPv4(10, 0, 0, 100).To4())
+	message := String("bye")
+	offer.Options = append(offer.Options,
+		Option{OptionData: &requested, Code: 50},
+		Option{OptionData: &message, Code: 56},
+	)
+	offer.DeleteOption(51)
+	offer.CIAddr = net.IPv4(10, 0, 0, 5)
+	expected := `error: option 51 (Address Time) MUST appear in DHCPOFFER
+error: option 50 (Address Request) MUST NOT appear in DHCPOFFER
+error: ciaddr MUST be 0 in DHCPOFFER`
+	if s := lintMessages(Lint(offer)); s != expected {
+		t.Error(s)
+	}
+
+	renew := replyRequest(MessageTypeRequest, Option{OptionData: &requested, Code: 50})
+	renew.CIAddr = net.IPv4(10, 0, 0, 100)
+	if s := lintMessages(Lint(renew)); s != "error: option 50 (Address Request) MUST NOT appear in DHCPREQUEST" {
+		t.Error(s)
+	}
+	selecting := replyRequest(MessageTypeRequest)
+	if s := lintMessages(Lint(selecting)); s != "error: option 50 (Address Request) MUST appear in DHCPREQUEST" {
+		t.Error(s)
+	}
+
+	release := replyRequest(MessageTypeRelease, replyServerOptions()[4], Option{OptionData: &IPv4s{requested}, Code: 3})
+	release.CIAddr = net.IPv4(10, 0, 0, 100)
+	if s := lintMessages(Lint(release)); s != "error: option 3 (Router) MUST NOT appear in DHCPRELEASE" {
+		t.Error(s)
+	}
+}
+
+func TestLintDuplicates(t *testing.T) {
+	long := String(strings.Repeat("a", 300))
+	m := replyRequest(MessageTypeDiscover, Option{OptionData: &long, Code: 77})
+	d := Message{}
+	if err := d.Decode(m.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if v := Lint(&d); len(v) != 0 {
+		t.Error("split option:", lintMessages(v))
+	}
+	m.Options = append(m.Options, Option{OptionData: newByte(1), Code: 53})
+	if s := lintMessages(Lint(m)); s != "error: option 53 (DHCP Msg Type) appears 2 times" {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,319 @@
+package dhop
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// MagicCookie is the four octets which precede the options field of a DHCP message.
+var MagicCookie = []byte{99, 130, 83, 99}
+
+const (
+	// MessageHeaderSize is the size of the fixed BOOTP header excluding the magic cookie.
+	MessageHeaderSize = 236
+
+	OpRequest byte = 1
+	OpReply   byte = 2
+
+	FlagBroadcast uint16 = 0x8000
+)
+
+type MessageType byte
+
+const (
+	MessageTypeDiscover MessageType = 1 + iota
+	MessageTypeOffer
+	MessageTypeRequest
+	MessageTypeDecline
+	MessageTypeAck
+	MessageTypeNak
+	MessageTypeRelease
+	MessageTypeInform
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case MessageTypeDiscover:
+		return "DHCPDISCOVER"
+	case MessageTypeOffer:
+		return "DHCPOFFER"
+	case MessageTypeRequest:
+		return "DHCPREQUEST"
+	case MessageTypeDecline:
+		return "DHCPDECLINE"
+	case MessageTypeAck:
+		return "DHCPACK"
+	case MessageTypeNak:
+		return "DHCPNAK"
+	case MessageTypeRelease:
+		return "DHCPRELEASE"
+	case MessageTypeInform:
+		return "DHCPINFORM"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(t))
+}
+
+// Message is a DHCPv4 message (RFC 2131).
+// Options never contain Pad and End, and options overloaded into the sname
+// and file fields are moved into Options while decoding.
+type Message struct {
+	Op      byte
+	HType   byte
+	HLen    byte
+	Hops    byte
+	XID     uint32
+	Secs    uint16
+	Flags   uint16
+	CIAddr  net.IP
+	YIAddr  net.IP
+	SIAddr  net.IP
+	GIAddr  net.IP
+	CHAddr  net.HardwareAddr
+	SName   string
+	File    string
+	Options []Option
+}
+
+// Type returns the value of the DHCP Message Type option, or 0 for BOOTP messages.
+func (m *Message) Type() MessageType {
+	o, ok := m.Option(53)
+	if !ok {
+		return 0
+	}
+	b, ok := o.OptionData.(*Byte)
+	if !ok {
+		return 0
+	}
+	return MessageType(*b)
+}
+
+// Option returns the first option which has the code.
+func (m *Message) Option(code Code) (Option, bool) {
+	for _, o := range m.Options {
+		if o.Code == code {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// SetOption replaces the options which have the same code, or appends the option.
+func (m *Message) SetOption(o Option) {
+	for i, v := range m.Options {
+		if v.Code == o.Code {
+			m.Options[i] = o
+			m.Options = append(m.Options[:i+1], withoutCode(m.Options[i+1:], o.Code)...)
+			return
+		}
+	}
+	m.Options = append(m.Options, o)
+}
+
+// DeleteOption removes all options which have the code.
+func (m *Message) DeleteOption(code Code) {
+	m.Options = withoutCode(m.Options, code)
+}
+
+func withoutCode(options []Option, code Code) []Option {
+	a := make([]Option, 0, len(options))
+	for _, o := range options {
+		if o.Code != code {
+			a = append(a, o)
+		}
+	}
+	return a
+}
+
+func (m *Message) Broadcast() bool {
+	return m.Flags&FlagBroadcast != 0
+}
+
+func (m *Message) Encode() []byte {
+	b := make([]byte, MessageHeaderSize, MessageHeaderSize+len(MagicCookie)+64)
+	m.encodeHeader(b)
+	b = append(b, MagicCookie...)
+	b = append(b, EncodeOptions(m.Options)...)
+	return append(b, 255)
+}
+
+func (m *Message) encodeHeader(b []byte) {
+	b[0] = m.Op
+	b[1] = m.HType
+	b[2] = m.HLen
+	b[3] = m.Hops
+	binary.BigEndian.PutUint32(b[4:8], m.XID)
+	binary.BigEndian.PutUint16(b[8:10], m.Secs)
+	binary.BigEndian.PutUint16(b[10:12], m.Flags)
+	copy(b[12:16], m.CIAddr.To4())
+	copy(b[16:20], m.YIAddr.To4())
+	copy(b[20:24], m.SIAddr.To4())
+	copy(b[24:28], m.GIAddr.To4())
+	copy(b[28:44], m.CHAddr)
+	copy(b[44:108], m.SName)
+	copy(b[108:236], m.File)
+}
+
+func (m *Message) Decode(b []byte) error {
+	if err := validateMinimumSize(b, MessageHeaderSize+len(MagicCookie)); err != nil {
+		return err
+	}
+	if !bytes.Equal(b[MessageHeaderSize:MessageHeaderSize+len(MagicCookie)], MagicCookie) {
+		return &InvalidFormatError{
+			Message: fmt.Sprintf("invalid magic cookie: % x", b[MessageHeaderSize:MessageHeaderSize+len(MagicCookie)]),
+		}
+	}
+	b = append([]byte{}, b...)
+	hlen := int(b[2])
+	if hlen > 16 {
+		hlen = 16
+	}
+	*m = Message{
+		Op:     b[0],
+		HType:  b[1],
+		HLen:   b[2],
+		Hops:   b[3],
+		XID:    binary.BigEndian.Uint32(b[4:8]),
+		Secs:   binary.BigEndian.Uint16(b[8:10]),
+		Flags:  binary.BigEndian.Uint16(b[10:12]),
+		CIAddr: net.IP(b[12:16]),
+		YIAddr: net.IP(b[16:20]),
+		SIAddr: net.IP(b[20:24]),
+		GIAddr: net.IP(b[24:28]),
+		CHAddr: net.HardwareAddr(b[28 : 28+hlen]),
+	}
+	raw, err := decodeRawOptions(b[MessageHeaderSize+len(MagicCookie):])
+	if err != nil {
+		return err
+	}
+	var overload byte
+	for i, o := range raw {
+		if o.code != 52 {
+			continue
+		}
+		if len(o.data) == 1 {
+			overload = o.data[0]
+			raw = append(raw[:i], raw[i+1:]...)
+		}
+		break
+	}
+	if overload&1 != 0 {
+		a, err := decodeRawOptions(b[108:236])
+		if err != nil {
+			return err
+		}
+		raw = append(raw, a...)
+	} else {
+		m.File = cstring(b[108:236])
+	}
+	if overload&2 != 0 {
+		a, err := decodeRawOptions(b[44:108])
+		if err != nil {
+			return err
+		}
+		raw = append(raw, a...)
+	} else {
+		m.SName = cstring(b[44:108])
+	}
+	m.Options = joinOptions(raw)
+	return nil
+}
+
+// EncodeOptions encodes options into the wire format without the End option.
+// Data longer than 255 bytes is split into multiple options (RFC 3396).
+func EncodeOptions(options []Option) []byte {
+	b := make([]byte, 0, 64)
+	for _, o := range options {
+		switch o.Code {
+		case 0, 255:
+			continue
+		}
+		data := o.Encode()
+		for {
+			l := len(data)
+			if l > 255 {
+				l = 255
+			}
+			b = append(b, byte(o.Code), byte(l))
+			b = append(b, data[:l]...)
+			data = data[l:]
+			if len(data) == 0 {
+				break
+			}
+		}
+	}
+	return b
+}
+
+// DecodeOptions decodes options in the wire format until the End option.
+// Consecutive options of the same code are joined as a long option split by RFC 3396.
+// Option data which cannot be decoded as the type of the code is kept as String.
+func DecodeOptions(b []byte) ([]Option, error) {
+	raw, err := decodeRawOptions(b)
+	return joinOptions(raw), err
+}
+
+type rawOption struct {
+	code byte
+	data []byte
+}
+
+func decodeRawOptions(b []byte) ([]rawOption, error) {
+	options := make([]rawOption, 0, 8)
+	for len(b) > 0 {
+		code := b[0]
+		if code == 0 {
+			b = b[1:]
+			continue
+		}
+		if code == 255 {
+			break
+		}
+		if err := validateMinimumSize(b, 2); err != nil {
+			return options, err
+		}
+		l := int(b[1])
+		if err := validateMinimumSize(b[2:], l); err != nil {
+			return options, err
+		}
+		options = append(options, rawOption{code: code, data: b[2 : 2+l]})
+		b = b[2+l:]
+	}
+	return options, nil
+}
+
+// joinOptions concatenates the data of all the options of each code in the order of their first appearance,
+// and decodes them (RFC 3396 section 7).
+func joinOptions(raw []rawOption) []Option {
+	codes := make([]byte, 0, len(raw))
+	data := make(map[byte][]byte)
+	for _, r := range raw {
+		d, ok := data[r.code]
+		if !ok {
+			codes = append(codes, r.code)
+			d = []byte{}
+		}
+		data[r.code] = append(d, r.data...)
+	}
+	options := make([]Option, 0, len(codes))
+	for _, code := range codes {
+		o, err := Decode(code, data[code])
+		if err != nil {
+			s := String(data[code])
+			o = Option{
+				OptionData: &s,
+				Code:       Code(code),
+			}
+		}
+		options = append(options, o)
+	}
+	return options
+}
+
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
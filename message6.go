@@ -0,0 +1,150 @@
+package dhop
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+type MessageType6 byte
+
+const (
+	MessageType6Solicit MessageType6 = 1 + iota
+	MessageType6Advertise
+	MessageType6Request
+	MessageType6Confirm
+	MessageType6Renew
+	MessageType6Rebind
+	MessageType6Reply
+	MessageType6Release
+	MessageType6Decline
+	MessageType6Reconfigure
+	MessageType6InformationRequest
+	MessageType6RelayForward
+	MessageType6RelayReply
+)
+
+func (t MessageType6) String() string {
+	switch t {
+	case MessageType6Solicit:
+		return "SOLICIT"
+	case MessageType6Advertise:
+		return "ADVERTISE"
+	case MessageType6Request:
+		return "REQUEST"
+	case MessageType6Confirm:
+		return "CONFIRM"
+	case MessageType6Renew:
+		return "RENEW"
+	case MessageType6Rebind:
+		return "REBIND"
+	case MessageType6Reply:
+		return "REPLY"
+	case MessageType6Release:
+		return "RELEASE"
+	case MessageType6Decline:
+		return "DECLINE"
+	case MessageType6Reconfigure:
+		return "RECONFIGURE"
+	case MessageType6InformationRequest:
+		return "INFORMATION-REQUEST"
+	case MessageType6RelayForward:
+		return "RELAY-FORW"
+	case MessageType6RelayReply:
+		return "RELAY-REPL"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(t))
+}
+
+// Option6 is a DHCPv6 option (RFC 8415) which keeps its data as is.
+type Option6 struct {
+	Code uint16
+	Data []byte
+}
+
+// Message6 is a DHCPv6 message.
+// HopCount, LinkAddress and PeerAddress are only used by relay messages,
+// and TransactionID is only used by the others.
+type Message6 struct {
+	Type          MessageType6
+	TransactionID uint32
+	HopCount      byte
+	LinkAddress   net.IP
+	PeerAddress   net.IP
+	Options       []Option6
+}
+
+func (m *Message6) IsRelay() bool {
+	return m.Type == MessageType6RelayForward || m.Type == MessageType6RelayReply
+}
+
+// Option returns the data of the first option which has the code.
+func (m *Message6) Option(code uint16) ([]byte, bool) {
+	for _, o := range m.Options {
+		if o.Code == code {
+			return o.Data, true
+		}
+	}
+	return nil, false
+}
+
+func (m *Message6) Encode() []byte {
+	var b []byte
+	if m.IsRelay() {
+		b = make([]byte, 34, 64)
+		b[0] = byte(m.Type)
+		b[1] = m.HopCount
+		copy(b[2:18], m.LinkAddress.To16())
+		copy(b[18:34], m.PeerAddress.To16())
+	} else {
+		b = make([]byte, 4, 64)
+		binary.BigEndian.PutUint32(b, m.TransactionID&0xffffff)
+		b[0] = byte(m.Type)
+	}
+	for _, o := range m.Options {
+		var h [4]byte
+		binary.BigEndian.PutUint16(h[:2], o.Code)
+		binary.BigEndian.PutUint16(h[2:], uint16(len(o.Data)))
+		b = append(b, h[:]...)
+		b = append(b, o.Data...)
+	}
+	return b
+}
+
+func (m *Message6) Decode(b []byte) error {
+	if err := validateMinimumSize(b, 4); err != nil {
+		return err
+	}
+	b = append([]byte{}, b...)
+	*m = Message6{
+		Type: MessageType6(b[0]),
+	}
+	if m.IsRelay() {
+		if err := validateMinimumSize(b, 34); err != nil {
+			return err
+		}
+		m.HopCount = b[1]
+		m.LinkAddress = net.IP(b[2:18])
+		m.PeerAddress = net.IP(b[18:34])
+		b = b[34:]
+	} else {
+		m.TransactionID = binary.BigEndian.Uint32(b) & 0xffffff
+		b = b[4:]
+	}
+	m.Options = make([]Option6, 0, 8)
+	for len(b) > 0 {
+		if err := validateMinimumSize(b, 4); err != nil {
+			return err
+		}
+		l := int(binary.BigEndian.Uint16(b[2:4]))
+		if err := validateMinimumSize(b[4:], l); err != nil {
+			return err
+		}
+		m.Options = append(m.Options, Option6{
+			Code: binary.BigEndian.Uint16(b[:2]),
+			Data: b[4 : 4+l],
+		})
+		b = b[4+l:]
+	}
+	return nil
+}
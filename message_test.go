@@ -0,0 +1,165 @@
+package dhop
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+var (
+	messageDiscover = Message{
+		Op:     OpRequest,
+		HType:  1,
+		HLen:   6,
+		XID:    0x12345678,
+		Flags:  FlagBroadcast,
+		CIAddr: net.IPv4zero.To4(),
+		YIAddr: net.IPv4zero.To4(),
+		SIAddr: net.IPv4zero.To4(),
+		GIAddr: net.IPv4zero.To4(),
+		CHAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Options: []Option{
+			{OptionData: newByte(1), Code: 53},
+			{OptionData: &IPv4s{IPv4(ipBytes)}, Code: 3},
+		},
+	}
+	messageDiscoverOptionBytes = []byte{99, 130, 83, 99, 53, 1, 1, 3, 4, 192, 168, 100, 1, 255}
+)
+
+func newByte(n byte) *Byte {
+	b := Byte(n)
+	return &b
+}
+
+func TestEncodeMessage(t *testing.T) {
+	b := messageDiscover.Encode()
+	if len(b) != MessageHeaderSize+len(messageDiscoverOptionBytes) {
+		t.Fatalf("unexpected length: %d", len(b))
+	}
+	if b[0] != OpRequest || b[4] != 0x12 || b[7] != 0x78 || b[10] != 0x80 || b[28] != 0x00 || b[33] != 0x55 {
+		t.Error(b[:44])
+	}
+	if bytes.Compare(b[MessageHeaderSize:], messageDiscoverOptionBytes) != 0 {
+		t.Error(b[MessageHeaderSize:])
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	m := Message{}
+	if err := m.Decode(messageDiscover.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if m.XID != messageDiscover.XID || !m.Broadcast() || m.CHAddr.String() != "00:11:22:33:44:55" {
+		t.Error(m)
+	}
+	if m.Type() != MessageTypeDiscover {
+		t.Error(m.Type())
+	}
+	o, ok := m.Option(3)
+	if !ok || string(o.Marshal()) != ipString {
+		t.Error(o)
+	}
+}
+
+func TestDecodeMessageOverload(t *testing.T) {
+	b := messageDiscover.Encode()
+	b = append(b[:len(b)-1], 52, 1, 3, 255)
+	copy(b[108:], []byte{12, 4, 104, 111, 115, 116, 255})
+	copy(b[44:], []byte{15, 3, 116, 108, 100, 255})
+	m := Message{}
+	if err := m.Decode(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Option(52); ok {
+		t.Error("overload option must be removed")
+	}
+	if o, ok := m.Option(12); !ok || string(o.Marshal()) != "host" {
+		t.Error(o)
+	}
+	if o, ok := m.Option(15); !ok || string(o.Marshal()) != "tld" {
+		t.Error(o)
+	}
+	if m.SName != "" || m.File != "" {
+		t.Error(m.SName, m.File)
+	}
+}
+
+func TestDecodeMessageInvalidCookie(t *testing.T) {
+	b := messageDiscover.Encode()
+	b[MessageHeaderSize] = 0
+	m := Message{}
+	if err := m.Decode(b); err == nil {
+		t.Error()
+	}
+}
+
+func TestEncodeOptionsLong(t *testing.T) {
+	s := String(bytes.Repeat([]byte{'a'}, 300))
+	b := EncodeOptions([]Option{{OptionData: &s, Code: 43}})
+	if len(b) != 304 || b[0] != 43 || b[1] != 255 || b[257] != 43 || b[258] != 45 {
+		t.Error(len(b))
+	}
+	options, err := DecodeOptions(append(b, 53, 1, 1, 43, 1, 'b'))
+	if err != nil || len(options) != 2 || options[0].Code != 43 || len(options[0].Encode()) != 301 || options[0].Encode()[300] != 'b' {
+		t.Error(options, err)
+	}
+
+	m := Message{Options: []Option{{OptionData: newByte(1), Code: 53}, {OptionData: &s, Code: 77}}}
+	d := Message{}
+	if err := d.Decode(m.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := d.Option(77); len(d.Options) != 2 || !ok || !bytes.Equal(o.Encode(), []byte(s)) {
+		t.Error(d.Options)
+	}
+
+	// The options field and the file field have the parts of option 77.
+	raw := m.Encode()
+	copy(raw[108:], []byte{77, 1, 'c', 255})
+	raw[len(raw)-1] = 52
+	raw = append(raw, 1, 1, 255)
+	d = Message{}
+	if err := d.Decode(raw); err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := d.Option(77); len(d.Options) != 2 || !ok || !bytes.Equal(o.Encode(), append([]byte(s), 'c')) {
+		t.Error(d.Options)
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	m := Message{
+		Options: []Option{
+			{OptionData: newByte(1), Code: 53},
+			{OptionData: newByte(2), Code: 23},
+			{OptionData: newByte(3), Code: 23},
+		},
+	}
+	m.SetOption(Option{OptionData: newByte(4), Code: 23})
+	if len(m.Options) != 2 || *m.Options[1].OptionData.(*Byte) != 4 {
+		t.Error(m.Options)
+	}
+	m.DeleteOption(53)
+	if len(m.Options) != 1 || m.Options[0].Code != 23 {
+		t.Error(m.Options)
+	}
+}
+
+func TestMessage6(t *testing.T) {
+	m := Message6{
+		Type:          MessageType6Solicit,
+		TransactionID: 0xabcdef,
+		Options:       []Option6{{Code: 8, Data: []byte{0, 0}}},
+	}
+	b := m.Encode()
+	if bytes.Compare(b, []byte{1, 0xab, 0xcd, 0xef, 0, 8, 0, 2, 0, 0}) != 0 {
+		t.Error(b)
+	}
+	d := Message6{}
+	if err := d.Decode(b); err != nil {
+		t.Fatal(err)
+	}
+	if d.Type != MessageType6Solicit || d.TransactionID != 0xabcdef || len(d.Options) != 1 {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,291 @@
+package dhop
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NetworkdConfig is the `SendOption` and `SendVendorOption` settings of systemd-networkd.
+// Options has the options of SendOption, and the sub-options of SendVendorOption
+// encapsulated into Vendor Specific Information.
+type NetworkdConfig struct {
+	Options       []Option
+	VendorOptions []SubOption
+}
+
+// networkdTypeOf returns the type of systemd-networkd for the option data,
+// or an error if systemd-networkd cannot express it.
+func networkdTypeOf(o Option) (string, error) {
+	switch v := o.OptionData.(type) {
+	case *IPv4:
+		return "ipv4address", nil
+	case *IPv4s:
+		if len(*v) != 1 {
+			return "", fmt.Errorf("systemd-networkd cannot express %d addresses in an option", len(*v))
+		}
+		return "ipv4address", nil
+	case *Boolean, *Byte:
+		return "uint8", nil
+	case *Size:
+		return "uint16", nil
+	case *TimeDuration, *TimeOffset:
+		return "uint32", nil
+	case *String:
+		return "string", nil
+	}
+	return "", fmt.Errorf("systemd-networkd cannot express %T", o.OptionData)
+}
+
+// MarshalNetworkdOption returns the setting of the option such as `SendOption=3:ipv4address:10.0.0.1`.
+func MarshalNetworkdOption(o Option) (string, error) {
+	switch o.Code {
+	case 0, 255:
+		return "", fmt.Errorf("%s cannot be configured", o.Code.String())
+	}
+	typ, err := networkdTypeOf(o)
+	if err != nil {
+		return "", fmt.Errorf("%d: %s", o.Code, err)
+	}
+	value, err := formatNetworkdValue(typ, o.Encode())
+	if err != nil {
+		return "", fmt.Errorf("%d: %s", o.Code, err)
+	}
+	return fmt.Sprintf("SendOption=%d:%s:%s", o.Code, typ, value), nil
+}
+
+// MarshalNetworkdVendorOption returns the setting of the sub-option of Vendor Specific Information
+// such as `SendVendorOption=1:string:foo`.
+func MarshalNetworkdVendorOption(o SubOption) (string, error) {
+	switch o.Code {
+	case 0, 255:
+		return "", fmt.Errorf("sub-option %d cannot be configured", o.Code)
+	}
+	value, err := formatNetworkdValue("string", o.Data)
+	if err != nil {
+		return "", fmt.Errorf("%d: %s", o.Code, err)
+	}
+	return fmt.Sprintf("SendVendorOption=%d:string:%s", o.Code, value), nil
+}
+
+// MarshalNetworkd returns the settings of the options, one per line.
+// Vendor Specific Information is written as SendVendorOption settings of its sub-options.
+func MarshalNetworkd(options []Option) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for _, o := range options {
+		lines := make([]string, 0, 1)
+		if o.Code == 43 {
+			subs, err := DecodeSubOptions(o.Encode())
+			if err != nil {
+				return nil, fmt.Errorf("43: %s", err)
+			}
+			for _, sub := range subs {
+				s, err := MarshalNetworkdVendorOption(sub)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, s)
+			}
+		} else {
+			s, err := MarshalNetworkdOption(o)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, s)
+		}
+		for _, s := range lines {
+			buf.WriteString(s)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalNetworkd parses `SendOption` and `SendVendorOption` settings of .network files
+// and ignores the other lines.
+func UnmarshalNetworkd(b []byte) (*NetworkdConfig, error) {
+	cfg := &NetworkdConfig{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, "=")
+		if i < 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		if key != "SendOption" && key != "SendVendorOption" {
+			continue
+		}
+		code, data, err := parseNetworkdOption(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		if key == "SendVendorOption" {
+			cfg.VendorOptions = append(cfg.VendorOptions, SubOption{Code: code, Data: data})
+			continue
+		}
+		o, err := Decode(code, data)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %d: %s", n, code, err)
+		}
+		cfg.Options = append(cfg.Options, o)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cfg.VendorOptions) > 0 {
+		o, err := Decode(43, EncodeSubOptions(cfg.VendorOptions))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Options = append(cfg.Options, o)
+	}
+	return cfg, nil
+}
+
+func parseNetworkdOption(s string) (byte, []byte, error) {
+	a := strings.SplitN(s, ":", 3)
+	if len(a) != 3 {
+		return 0, nil, fmt.Errorf("invalid option \"%s\"", s)
+	}
+	code, err := strconv.ParseUint(a[0], 10, 8)
+	if err != nil || code < 1 || code > 254 {
+		return 0, nil, fmt.Errorf("invalid option code \"%s\"", a[0])
+	}
+	data, err := parseNetworkdValue(a[1], a[2])
+	return byte(code), data, err
+}
+
+func parseNetworkdValue(typ, v string) ([]byte, error) {
+	switch typ {
+	case "uint8", "uint16", "uint32":
+		bits, _ := strconv.Atoi(typ[4:])
+		n, err := strconv.ParseUint(v, 10, bits)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, bits/8)
+		for i := range b {
+			b[i] = byte(n >> uint(8*(len(b)-1-i)))
+		}
+		return b, nil
+	case "ipv4address":
+		ip := net.ParseIP(v).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address \"%s\"", v)
+		}
+		return ip, nil
+	case "ipv6address":
+		ip := net.ParseIP(v)
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("invalid IPv6 address \"%s\"", v)
+		}
+		return ip.To16(), nil
+	case "string":
+		return unescapeNetworkd(v)
+	}
+	return nil, fmt.Errorf("unsupported type \"%s\"", typ)
+}
+
+func formatNetworkdValue(typ string, b []byte) (string, error) {
+	switch typ {
+	case "uint8", "uint16", "uint32":
+		bits, _ := strconv.Atoi(typ[4:])
+		if err := validateSize(b, bits/8); err != nil {
+			return "", err
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return strconv.FormatUint(n, 10), nil
+	case "ipv4address":
+		if err := validateSize(b, 4); err != nil {
+			return "", err
+		}
+		return net.IP(b).String(), nil
+	case "string":
+		return escapeNetworkd(b), nil
+	}
+	return "", fmt.Errorf("unsupported type \"%s\"", typ)
+}
+
+// escapeNetworkd escapes the bytes which systemd unescapes in string values,
+// and the spaces at both ends which would be trimmed.
+func escapeNetworkd(b []byte) string {
+	buf := bytes.Buffer{}
+	for i, c := range b {
+		switch {
+		case c == '\\':
+			buf.WriteString("\\\\")
+		case c == ' ' && (i == 0 || i == len(b)-1):
+			buf.WriteString("\\x20")
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&buf, "\\x%02x", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// unescapeNetworkd unescapes C-style escapes as systemd does.
+func unescapeNetworkd(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return nil, fmt.Errorf("invalid escape at the end of \"%s\"", s)
+		}
+		switch c := s[i]; c {
+		case 'a':
+			b = append(b, '\a')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case 's':
+			b = append(b, ' ')
+		case '\\', '"', '\'':
+			b = append(b, c)
+		case 'x':
+			if i+3 > len(s) {
+				return nil, fmt.Errorf("invalid escape in \"%s\"", s)
+			}
+			n, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape in \"%s\"", s)
+			}
+			b = append(b, byte(n))
+			i += 2
+		case '0', '1', '2', '3':
+			if i+3 > len(s) {
+				return nil, fmt.Errorf("invalid escape in \"%s\"", s)
+			}
+			n, err := strconv.ParseUint(s[i:i+3], 8, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid escape in \"%s\"", s)
+			}
+			b = append(b, byte(n))
+			i += 2
+		default:
+			return nil, fmt.Errorf("invalid escape in \"%s\"", s)
+		}
+	}
+	return b, nil
+}
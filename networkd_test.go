@@ -0,0 +1,112 @@
+package dhop
+
+import (
+	"bytes"
+	"testing"
+)
+
+var networkdConfig = []byte(`[Match]
+Name=eth0
+
+[DHCPServer]
+# options
+SendOption=3:ipv4address:10.0.0.1
+SendOption=15:string:example.com
+SendOption=19:uint8:1
+SendOption=26:uint16:1500
+SendOption=51:uint32:3600
+SendOption=61:string:\x01\x00\x11\x22\x33\x44\x55
+SendOption=224:ipv6address:2001:db8::1
+SendVendorOption=1:string:foo
+SendVendorOption=2:uint8:3
+`)
+
+func TestUnmarshalNetworkd(t *testing.T) {
+	cfg, err := UnmarshalNetworkd(networkdConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.VendorOptions) != 2 {
+		t.Error(cfg.VendorOptions)
+	}
+	expected := []struct {
+		code Code
+		data string
+	}{
+		{3, "10.0.0.1"},
+		{15, "example.com"},
+		{19, "true"},
+		{26, "1500"},
+		{51, "1h0m0s"},
+		{61, "\x01\x00\x11\x22\x33\x44\x55"},
+		{224, "\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01"},
+		{43, "\x01\x03foo\x02\x01\x03"},
+	}
+	if len(cfg.Options) != len(expected) {
+		t.Fatal(cfg.Options)
+	}
+	for i, e := range expected {
+		o := cfg.Options[i]
+		if o.Code != e.code || string(o.Marshal()) != e.data {
+			t.Errorf("%d: %q", o.Code, o.Marshal())
+		}
+	}
+}
+
+func TestUnmarshalNetworkdError(t *testing.T) {
+	for _, s := range []string{
+		`SendOption=3`,
+		`SendOption=256:uint8:1`,
+		`SendOption=3:ipv4address:example.com`,
+		`SendOption=19:uint8:256`,
+		`SendOption=15:int32:1`,
+		`SendOption=15:string:\x0`,
+		`SendOption=3:uint8:1`,
+	} {
+		if _, err := UnmarshalNetworkd([]byte(s)); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestMarshalNetworkd(t *testing.T) {
+	ip := IPv4(ipBytes)
+	ips := IPv4s{IPv4(ipBytes), IPv4(ipBytes)}
+	s := String(" a\\b\n")
+	offset := TimeOffset(3600 * 1000000000)
+	vendor := String("\x01\x03foo")
+	b, err := MarshalNetworkd([]Option{
+		{OptionData: &ip, Code: 1},
+		{OptionData: &s, Code: 15},
+		{OptionData: &offset, Code: 2},
+		{OptionData: &vendor, Code: 43},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte(`SendOption=1:ipv4address:192.168.100.1
+SendOption=15:string:\x20a\\b\x0a
+SendOption=2:uint32:3600
+SendVendorOption=1:string:foo
+`)
+	if !bytes.Equal(b, expected) {
+		t.Error(string(b))
+	}
+	cfg, err := UnmarshalNetworkd(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Options) != 4 || string(cfg.Options[1].Encode()) != string(s) || string(cfg.Options[2].Marshal()) != "1h0m0s" {
+		t.Error(cfg.Options)
+	}
+	routes := Routes{}
+	for _, o := range []Option{
+		{OptionData: &ips, Code: 6},
+		{OptionData: &routes, Code: 121},
+		{OptionData: &End{}, Code: 255},
+	} {
+		if _, err := MarshalNetworkdOption(o); err == nil {
+			t.Error(o)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package pcap
+
+import (
+	"net"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+// NewPacket returns a packet carrying the message with the addresses
+// which a client or a server on the same link would use.
+func NewPacket(m *dhop.Message, t time.Time) *Packet {
+	p := &Packet{
+		Timestamp: t,
+		Payload:   m.Encode(),
+	}
+	if m.Op == dhop.OpRequest {
+		p.SrcMAC = m.CHAddr
+		p.DstMAC = BroadcastMAC
+		p.Src = net.IPv4zero
+		if !isUnspecified(m.CIAddr) {
+			p.Src = m.CIAddr
+		}
+		p.Dst = net.IPv4bcast
+		p.SrcPort = PortDHCPClient
+		p.DstPort = PortDHCPServer
+		return p
+	}
+	p.Src = net.IPv4zero
+	if ip := m.ServerIdentifier(); ip != nil {
+		p.Src = ip
+	} else if !isUnspecified(m.SIAddr) {
+		p.Src = m.SIAddr
+	}
+	p.SrcPort = PortDHCPServer
+	p.DstMAC = m.CHAddr
+	switch {
+	case !isUnspecified(m.GIAddr):
+		p.Dst = m.GIAddr
+		p.DstMAC = nil
+		p.DstPort = PortDHCPServer
+	case m.Broadcast() || isUnspecified(m.YIAddr):
+		p.Dst = net.IPv4bcast
+		p.DstMAC = BroadcastMAC
+		p.DstPort = PortDHCPClient
+	default:
+		p.Dst = m.YIAddr
+		p.DstPort = PortDHCPClient
+	}
+	return p
+}
+
+func isUnspecified(ip net.IP) bool {
+	return ip == nil || ip.IsUnspecified()
+}
@@ -0,0 +1,216 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86dd
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88a8
+	etherTypeQinQ2 = 0x9100
+
+	protocolUDP = 17
+
+	PortDHCPServer  = 67
+	PortDHCPClient  = 68
+	PortDHCP6Client = 546
+	PortDHCP6Server = 547
+)
+
+// ErrNotUDP is returned when the frame does not carry a UDP datagram.
+var ErrNotUDP = errors.New("not a UDP datagram")
+
+// Packet is a UDP datagram in a captured frame.
+type Packet struct {
+	Timestamp time.Time
+	SrcMAC    net.HardwareAddr
+	DstMAC    net.HardwareAddr
+	VLANs     []uint16
+	Src       net.IP
+	Dst       net.IP
+	SrcPort   uint16
+	DstPort   uint16
+	Payload   []byte
+
+	// ipOffset and udpOffset are the offsets of the IP and UDP headers in the frame.
+	ipOffset  int
+	udpOffset int
+}
+
+// IsDHCPv4 reports whether the datagram is sent from or to the DHCPv4 ports.
+func (p *Packet) IsDHCPv4() bool {
+	return p.Src.To4() != nil && (isPort(p, PortDHCPServer) || isPort(p, PortDHCPClient))
+}
+
+// IsDHCPv6 reports whether the datagram is sent from or to the DHCPv6 ports.
+func (p *Packet) IsDHCPv6() bool {
+	return p.Src.To4() == nil && (isPort(p, PortDHCP6Server) || isPort(p, PortDHCP6Client))
+}
+
+func isPort(p *Packet, port uint16) bool {
+	return p.SrcPort == port || p.DstPort == port
+}
+
+// Packet decodes the headers of the frame down to UDP.
+func (r *Record) Packet() (*Packet, error) {
+	p := &Packet{
+		Timestamp: r.Timestamp,
+	}
+	b := r.Data
+	var etherType uint16
+	switch r.LinkType {
+	case LinkTypeEthernet:
+		if len(b) < 14 {
+			return nil, &FormatError{Message: "too short ethernet frame"}
+		}
+		p.DstMAC = net.HardwareAddr(b[0:6])
+		p.SrcMAC = net.HardwareAddr(b[6:12])
+		etherType = binary.BigEndian.Uint16(b[12:14])
+		b = b[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ || etherType == etherTypeQinQ2 {
+			if len(b) < 4 {
+				return nil, &FormatError{Message: "too short 802.1Q tag"}
+			}
+			p.VLANs = append(p.VLANs, binary.BigEndian.Uint16(b[0:2])&0x0fff)
+			etherType = binary.BigEndian.Uint16(b[2:4])
+			b = b[4:]
+		}
+	case LinkTypeLinuxSLL:
+		if len(b) < 16 {
+			return nil, &FormatError{Message: "too short Linux cooked header"}
+		}
+		etherType = binary.BigEndian.Uint16(b[14:16])
+		b = b[16:]
+	case LinkTypeLinuxSLL2:
+		if len(b) < 20 {
+			return nil, &FormatError{Message: "too short Linux cooked v2 header"}
+		}
+		etherType = binary.BigEndian.Uint16(b[0:2])
+		b = b[20:]
+	case LinkTypeNull, LinkTypeLoop:
+		if len(b) < 4 {
+			return nil, &FormatError{Message: "too short loopback header"}
+		}
+		b = b[4:]
+		etherType = ipVersion(b)
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		etherType = ipVersion(b)
+	default:
+		return nil, &FormatError{
+			Message: fmt.Sprintf("unsupported link type: %d", r.LinkType),
+		}
+	}
+	p.ipOffset = len(r.Data) - len(b)
+	var err error
+	switch etherType {
+	case etherTypeIPv4:
+		b, err = p.decodeIPv4(b)
+	case etherTypeIPv6:
+		b, err = p.decodeIPv6(b)
+	default:
+		return nil, ErrNotUDP
+	}
+	if err != nil {
+		return nil, err
+	}
+	// b may be truncated to the IP length, but it starts at the UDP header.
+	p.udpOffset = cap(r.Data) - cap(b)
+	if err := p.decodeUDP(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func ipVersion(b []byte) uint16 {
+	if len(b) == 0 {
+		return 0
+	}
+	switch b[0] >> 4 {
+	case 4:
+		return etherTypeIPv4
+	case 6:
+		return etherTypeIPv6
+	}
+	return 0
+}
+
+func (p *Packet) decodeIPv4(b []byte) ([]byte, error) {
+	if len(b) < 20 {
+		return nil, &FormatError{Message: "too short IPv4 header"}
+	}
+	ihl := int(b[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(b[2:4]))
+	if ihl < 20 || len(b) < ihl || total < ihl {
+		return nil, &FormatError{Message: "invalid IPv4 header length"}
+	}
+	if b[9] != protocolUDP {
+		return nil, ErrNotUDP
+	}
+	if binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 {
+		return nil, &FormatError{Message: "fragmented IPv4 packet is not supported"}
+	}
+	p.Src = net.IP(b[12:16])
+	p.Dst = net.IP(b[16:20])
+	if total < len(b) {
+		b = b[:total]
+	}
+	return b[ihl:], nil
+}
+
+func (p *Packet) decodeIPv6(b []byte) ([]byte, error) {
+	if len(b) < 40 {
+		return nil, &FormatError{Message: "too short IPv6 header"}
+	}
+	next := b[6]
+	p.Src = net.IP(b[8:24])
+	p.Dst = net.IP(b[24:40])
+	if l := 40 + int(binary.BigEndian.Uint16(b[4:6])); l < len(b) {
+		b = b[:l]
+	}
+	b = b[40:]
+	for next != protocolUDP {
+		if len(b) < 8 {
+			return nil, ErrNotUDP
+		}
+		var l int
+		switch next {
+		case 0, 43, 60:
+			l = (int(b[1]) + 1) * 8
+		case 44:
+			return nil, &FormatError{Message: "fragmented IPv6 packet is not supported"}
+		case 51:
+			l = (int(b[1]) + 2) * 4
+		default:
+			return nil, ErrNotUDP
+		}
+		if len(b) < l {
+			return nil, &FormatError{Message: "too short IPv6 extension header"}
+		}
+		next = b[0]
+		b = b[l:]
+	}
+	return b, nil
+}
+
+func (p *Packet) decodeUDP(b []byte) error {
+	if len(b) < 8 {
+		return &FormatError{Message: "too short UDP header"}
+	}
+	p.SrcPort = binary.BigEndian.Uint16(b[0:2])
+	p.DstPort = binary.BigEndian.Uint16(b[2:4])
+	l := int(binary.BigEndian.Uint16(b[4:6]))
+	if l < 8 {
+		return &FormatError{Message: "invalid UDP length"}
+	}
+	if l < len(b) {
+		b = b[:l]
+	}
+	p.Payload = b[8:]
+	return nil
+}
@@ -0,0 +1,343 @@
+// Package pcap reads and writes pcap and pcapng capture files,
+// and handles the link, network and transport layers around DHCP messages.
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+)
+
+type LinkType uint16
+
+const (
+	LinkTypeNull      LinkType = 0
+	LinkTypeEthernet  LinkType = 1
+	LinkTypeRaw       LinkType = 101
+	LinkTypeLoop      LinkType = 108
+	LinkTypeLinuxSLL  LinkType = 113
+	LinkTypeIPv4      LinkType = 228
+	LinkTypeIPv6      LinkType = 229
+	LinkTypeLinuxSLL2 LinkType = 276
+)
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+
+	blockTypeSectionHeader      = 0x0a0d0d0a
+	blockTypeInterface          = 0x00000001
+	blockTypePacket             = 0x00000002
+	blockTypeSimplePacket       = 0x00000003
+	blockTypeEnhancedPacket     = 0x00000006
+	byteOrderMagic              = 0x1a2b3c4d
+	optionEndOfOpt              = 0
+	optionInterfaceTimeRes      = 9
+	optionInterfaceTimeOffset   = 14
+	defaultInterfaceTimeUnits   = 1000000
+	maximumBlockSize            = 16 << 20
+	sectionHeaderBlockMinLength = 28
+)
+
+// Record is a captured frame.
+type Record struct {
+	Timestamp      time.Time
+	LinkType       LinkType
+	Data           []byte
+	OriginalLength int
+}
+
+type FormatError struct {
+	Message string
+}
+
+func (err *FormatError) Error() string {
+	return err.Message
+}
+
+type captureInterface struct {
+	linkType LinkType
+	units    uint64
+	offset   int64
+}
+
+// Reader reads records from a pcap or pcapng file.
+type Reader struct {
+	r          io.Reader
+	order      binary.ByteOrder
+	ng         bool
+	linkType   LinkType
+	units      uint64
+	interfaces []captureInterface
+}
+
+// NewReader detects the file format from the magic number and reads the file header.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: r}
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(magic[:]) == blockTypeSectionHeader {
+		reader.ng = true
+		if err := reader.readSectionHeader(); err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		switch order.Uint32(magic[:]) {
+		case magicMicroseconds:
+			reader.units = 1000000
+		case magicNanoseconds:
+			reader.units = 1000000000
+		default:
+			continue
+		}
+		reader.order = order
+	}
+	if reader.order == nil {
+		return nil, &FormatError{
+			Message: fmt.Sprintf("unknown capture file format: % x", magic),
+		}
+	}
+	var header [20]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	reader.linkType = LinkType(reader.order.Uint32(header[16:20]))
+	return reader, nil
+}
+
+// Next returns the next captured frame, or io.EOF at the end of the file.
+func (r *Reader) Next() (*Record, error) {
+	if r.ng {
+		return r.nextBlock()
+	}
+	var header [16]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return nil, err
+	}
+	l := r.order.Uint32(header[8:12])
+	if l > maximumBlockSize {
+		return nil, &FormatError{
+			Message: fmt.Sprintf("too large record: %d bytes", l),
+		}
+	}
+	data := make([]byte, l)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return &Record{
+		Timestamp:      timestamp(uint64(r.order.Uint32(header[0:4]))*r.units+uint64(r.order.Uint32(header[4:8])), r.units, 0),
+		LinkType:       r.linkType,
+		Data:           data,
+		OriginalLength: int(r.order.Uint32(header[12:16])),
+	}, nil
+}
+
+// readSectionHeader reads the rest of a section header block whose type has already been read.
+func (r *Reader) readSectionHeader() error {
+	var header [8]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	switch {
+	case binary.BigEndian.Uint32(header[4:8]) == byteOrderMagic:
+		r.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header[4:8]) == byteOrderMagic:
+		r.order = binary.LittleEndian
+	default:
+		return &FormatError{
+			Message: fmt.Sprintf("invalid byte-order magic: % x", header[4:8]),
+		}
+	}
+	l := r.order.Uint32(header[0:4])
+	if l < sectionHeaderBlockMinLength || l > maximumBlockSize {
+		return &FormatError{
+			Message: fmt.Sprintf("invalid section header block length: %d", l),
+		}
+	}
+	if _, err := io.CopyN(ioutil.Discard, r.r, int64(l-12)); err != nil {
+		return unexpectedEOF(err)
+	}
+	r.interfaces = r.interfaces[:0]
+	return nil
+}
+
+func (r *Reader) nextBlock() (*Record, error) {
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r.r, header[:]); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint32(header[:]) == blockTypeSectionHeader {
+			if err := r.readSectionHeader(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		blockType := r.order.Uint32(header[:])
+		if _, err := io.ReadFull(r.r, header[:]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		l := r.order.Uint32(header[:])
+		if l < 12 || l%4 != 0 || l > maximumBlockSize {
+			return nil, &FormatError{
+				Message: fmt.Sprintf("invalid block length: %d", l),
+			}
+		}
+		body := make([]byte, l-8)
+		if _, err := io.ReadFull(r.r, body); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		body = body[:len(body)-4]
+		record, err := r.decodeBlock(blockType, body)
+		if err != nil || record != nil {
+			return record, err
+		}
+	}
+}
+
+func (r *Reader) decodeBlock(blockType uint32, b []byte) (*Record, error) {
+	switch blockType {
+	case blockTypeInterface:
+		if len(b) < 8 {
+			return nil, &FormatError{Message: "too short interface description block"}
+		}
+		iface := captureInterface{
+			linkType: LinkType(r.order.Uint16(b[0:2])),
+			units:    defaultInterfaceTimeUnits,
+		}
+		r.walkOptions(b[8:], func(code uint16, value []byte) {
+			switch {
+			case code == optionInterfaceTimeRes && len(value) == 1:
+				iface.units = timeUnits(value[0])
+			case code == optionInterfaceTimeOffset && len(value) == 8:
+				iface.offset = int64(r.order.Uint64(value))
+			}
+		})
+		r.interfaces = append(r.interfaces, iface)
+	case blockTypeEnhancedPacket:
+		if len(b) < 20 {
+			return nil, &FormatError{Message: "too short enhanced packet block"}
+		}
+		iface, err := r.captureInterface(int(r.order.Uint32(b[0:4])))
+		if err != nil {
+			return nil, err
+		}
+		ts := uint64(r.order.Uint32(b[4:8]))<<32 | uint64(r.order.Uint32(b[8:12]))
+		l := int(r.order.Uint32(b[12:16]))
+		if len(b) < 20+l {
+			return nil, &FormatError{Message: "truncated enhanced packet block"}
+		}
+		return &Record{
+			Timestamp:      timestamp(ts, iface.units, iface.offset),
+			LinkType:       iface.linkType,
+			Data:           b[20 : 20+l],
+			OriginalLength: int(r.order.Uint32(b[16:20])),
+		}, nil
+	case blockTypePacket:
+		if len(b) < 20 {
+			return nil, &FormatError{Message: "too short packet block"}
+		}
+		iface, err := r.captureInterface(int(r.order.Uint16(b[0:2])))
+		if err != nil {
+			return nil, err
+		}
+		ts := uint64(r.order.Uint32(b[4:8]))<<32 | uint64(r.order.Uint32(b[8:12]))
+		l := int(r.order.Uint32(b[12:16]))
+		if len(b) < 20+l {
+			return nil, &FormatError{Message: "truncated packet block"}
+		}
+		return &Record{
+			Timestamp:      timestamp(ts, iface.units, iface.offset),
+			LinkType:       iface.linkType,
+			Data:           b[20 : 20+l],
+			OriginalLength: int(r.order.Uint32(b[16:20])),
+		}, nil
+	case blockTypeSimplePacket:
+		if len(b) < 4 {
+			return nil, &FormatError{Message: "too short simple packet block"}
+		}
+		iface, err := r.captureInterface(0)
+		if err != nil {
+			return nil, err
+		}
+		l := int(r.order.Uint32(b[0:4]))
+		data := b[4:]
+		if l < len(data) {
+			data = data[:l]
+		}
+		return &Record{
+			LinkType:       iface.linkType,
+			Data:           data,
+			OriginalLength: l,
+		}, nil
+	}
+	return nil, nil
+}
+
+func (r *Reader) captureInterface(id int) (captureInterface, error) {
+	if id >= len(r.interfaces) {
+		return captureInterface{}, &FormatError{
+			Message: fmt.Sprintf("undefined interface: %d", id),
+		}
+	}
+	return r.interfaces[id], nil
+}
+
+func (r *Reader) walkOptions(b []byte, f func(uint16, []byte)) {
+	for len(b) >= 4 {
+		code := r.order.Uint16(b[0:2])
+		l := int(r.order.Uint16(b[2:4]))
+		if code == optionEndOfOpt || len(b) < 4+l {
+			return
+		}
+		f(code, b[4:4+l])
+		b = b[4+(l+3)&^3:]
+	}
+}
+
+// timeUnits returns the number of timestamp units per second from if_tsresol.
+func timeUnits(resolution byte) uint64 {
+	n := uint(resolution & 0x7f)
+	if resolution&0x80 != 0 {
+		if n > 63 {
+			return math.MaxUint64
+		}
+		return 1 << n
+	}
+	units := uint64(1)
+	for i := uint(0); i < n && units <= math.MaxUint64/10; i++ {
+		units *= 10
+	}
+	return units
+}
+
+func timestamp(ts, units uint64, offset int64) time.Time {
+	sec := ts / units
+	frac := ts % units
+	var nsec uint64
+	switch {
+	case units == 1000000000:
+		nsec = frac
+	case units < 1000000000 && 1000000000%units == 0:
+		nsec = frac * (1000000000 / units)
+	case units > 1000000000 && units%1000000000 == 0:
+		nsec = frac / (units / 1000000000)
+	default:
+		nsec = uint64(float64(frac) / float64(units) * 1e9)
+	}
+	return time.Unix(int64(sec)+offset, int64(nsec)).UTC()
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+var (
+	udpPayload = []byte{1, 2, 3, 4}
+
+	ethernetFrame = []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+		0x81, 0x00, 0x00, 0x64, 0x08, 0x00,
+		0x45, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x40, 0x11, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff,
+		0x00, 0x44, 0x00, 0x43, 0x00, 0x0c, 0x00, 0x00,
+		1, 2, 3, 4,
+	}
+
+	ipv6Frame = []byte{
+		0x60, 0x00, 0x00, 0x00, 0x00, 0x14, 0x00, 0x40,
+		0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 2,
+		0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x02, 0x22, 0x02, 0x23, 0x00, 0x0c, 0x00, 0x00,
+		1, 2, 3, 4,
+	}
+
+	captureTime = time.Date(2018, 4, 1, 12, 0, 0, 123456000, time.UTC)
+)
+
+func classicCapture(order binary.ByteOrder, linkType LinkType, frame []byte) []byte {
+	b := make([]byte, 24+16+len(frame))
+	order.PutUint32(b[0:], magicMicroseconds)
+	order.PutUint16(b[4:], 2)
+	order.PutUint16(b[6:], 4)
+	order.PutUint32(b[16:], 65535)
+	order.PutUint32(b[20:], uint32(linkType))
+	order.PutUint32(b[24:], uint32(captureTime.Unix()))
+	order.PutUint32(b[28:], uint32(captureTime.Nanosecond()/1000))
+	order.PutUint32(b[32:], uint32(len(frame)))
+	order.PutUint32(b[36:], uint32(len(frame)))
+	copy(b[40:], frame)
+	return b
+}
+
+func block(order binary.ByteOrder, blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	b := make([]byte, 8, 12+len(body))
+	order.PutUint32(b[0:], blockType)
+	order.PutUint32(b[4:], uint32(12+len(body)))
+	b = append(b, body...)
+	b = append(b, b[4:8]...)
+	return b
+}
+
+func ngCapture(order binary.ByteOrder, linkType LinkType, frame []byte) []byte {
+	shb := make([]byte, 16)
+	order.PutUint32(shb[0:], byteOrderMagic)
+	order.PutUint16(shb[4:], 1)
+	binary.BigEndian.PutUint64(shb[8:], 0xffffffffffffffff)
+	idb := make([]byte, 8, 20)
+	order.PutUint16(idb[0:], uint16(linkType))
+	opt := make([]byte, 8)
+	order.PutUint16(opt[0:], optionInterfaceTimeRes)
+	order.PutUint16(opt[2:], 1)
+	opt[4] = 9
+	idb = append(idb, opt...)
+	idb = append(idb, 0, 0, 0, 0)
+	ts := uint64(captureTime.UnixNano())
+	epb := make([]byte, 20, 20+len(frame))
+	order.PutUint32(epb[4:], uint32(ts>>32))
+	order.PutUint32(epb[8:], uint32(ts))
+	order.PutUint32(epb[12:], uint32(len(frame)))
+	order.PutUint32(epb[16:], uint32(len(frame)))
+	epb = append(epb, frame...)
+	b := block(order, blockTypeSectionHeader, shb)
+	b = append(b, block(order, blockTypeInterface, idb)...)
+	b = append(b, block(order, 5, []byte{0, 0, 0, 0})...)
+	b = append(b, block(order, blockTypeEnhancedPacket, epb)...)
+	return b
+}
+
+func readOne(t *testing.T, b []byte) *Record {
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Error(err)
+	}
+	return record
+}
+
+func TestReadClassic(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		record := readOne(t, classicCapture(order, LinkTypeEthernet, ethernetFrame))
+		if !record.Timestamp.Equal(captureTime) {
+			t.Error(record.Timestamp)
+		}
+		if record.LinkType != LinkTypeEthernet || bytes.Compare(record.Data, ethernetFrame) != 0 {
+			t.Error(record)
+		}
+	}
+}
+
+func TestReadNG(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		record := readOne(t, ngCapture(order, LinkTypeRaw, ipv6Frame))
+		if !record.Timestamp.Equal(captureTime) {
+			t.Error(record.Timestamp)
+		}
+		if record.LinkType != LinkTypeRaw || bytes.Compare(record.Data, ipv6Frame) != 0 {
+			t.Error(record)
+		}
+	}
+}
+
+func TestReadUnknownFormat(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader(make([]byte, 24))); err == nil {
+		t.Error()
+	}
+}
+
+func TestPacketEthernet(t *testing.T) {
+	record := Record{LinkType: LinkTypeEthernet, Data: ethernetFrame}
+	p, err := record.Packet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.VLANs) != 1 || p.VLANs[0] != 100 {
+		t.Error(p.VLANs)
+	}
+	if p.Src.String() != "0.0.0.0" || p.Dst.String() != "255.255.255.255" || p.SrcPort != 68 || p.DstPort != 67 {
+		t.Error(p)
+	}
+	if !p.IsDHCPv4() || p.IsDHCPv6() || bytes.Compare(p.Payload, udpPayload) != 0 {
+		t.Error(p)
+	}
+}
+
+func TestPacketIPv6(t *testing.T) {
+	record := Record{LinkType: LinkTypeRaw, Data: ipv6Frame}
+	p, err := record.Packet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Src.String() != "fe80::1" || p.Dst.String() != "ff02::1:2" || p.SrcPort != 546 || p.DstPort != 547 {
+		t.Error(p)
+	}
+	if p.IsDHCPv4() || !p.IsDHCPv6() || bytes.Compare(p.Payload, udpPayload) != 0 {
+		t.Error(p)
+	}
+}
+
+func TestPacketNotUDP(t *testing.T) {
+	frame := append([]byte{}, ethernetFrame...)
+	frame[18+9] = 6
+	record := Record{LinkType: LinkTypeEthernet, Data: frame}
+	if _, err := record.Packet(); err != ErrNotUDP {
+		t.Error(err)
+	}
+}
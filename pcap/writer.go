@@ -0,0 +1,246 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	defaultSnapLength = 262144
+	defaultTTL        = 64
+)
+
+var (
+	BroadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	zeroMAC      = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+)
+
+// Writer writes records into a pcap file with microsecond timestamps.
+type Writer struct {
+	w        io.Writer
+	linkType LinkType
+}
+
+// NewWriter writes the file header.
+func NewWriter(w io.Writer, linkType LinkType) (*Writer, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], magicMicroseconds)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], defaultSnapLength)
+	binary.LittleEndian.PutUint32(header[20:24], uint32(linkType))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:        w,
+		linkType: linkType,
+	}, nil
+}
+
+func (w *Writer) LinkType() LinkType {
+	return w.linkType
+}
+
+func (w *Writer) WriteRecord(r *Record) error {
+	header := make([]byte, 16)
+	if !r.Timestamp.IsZero() {
+		binary.LittleEndian.PutUint32(header[0:4], uint32(r.Timestamp.Unix()))
+		binary.LittleEndian.PutUint32(header[4:8], uint32(r.Timestamp.Nanosecond()/1000))
+	}
+	l := r.OriginalLength
+	if l < len(r.Data) {
+		l = len(r.Data)
+	}
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(r.Data)))
+	binary.LittleEndian.PutUint32(header[12:16], uint32(l))
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.w.Write(r.Data)
+	return err
+}
+
+// WritePacket synthesizes the headers of the packet for the link type of the file.
+func (w *Writer) WritePacket(p *Packet) error {
+	frame, err := p.Encode(w.linkType)
+	if err != nil {
+		return err
+	}
+	return w.WriteRecord(&Record{
+		Timestamp: p.Timestamp,
+		LinkType:  w.linkType,
+		Data:      frame,
+	})
+}
+
+// Encode builds a frame carrying the payload with the link, IP and UDP headers.
+// Lengths and checksums are computed from the payload.
+func (p *Packet) Encode(linkType LinkType) ([]byte, error) {
+	var (
+		b         []byte
+		etherType uint16
+	)
+	if p.Src.To4() != nil && p.Dst.To4() != nil {
+		b = p.encodeIPv4()
+		etherType = etherTypeIPv4
+	} else if p.Src.To16() != nil && p.Dst.To16() != nil {
+		b = p.encodeIPv6()
+		etherType = etherTypeIPv6
+	} else {
+		return nil, &FormatError{
+			Message: fmt.Sprintf("invalid addresses: %v > %v", p.Src, p.Dst),
+		}
+	}
+	switch linkType {
+	case LinkTypeEthernet:
+		header := make([]byte, 12, 14+len(p.VLANs)*4+len(b))
+		copy(header[0:6], macOrZero(p.DstMAC))
+		copy(header[6:12], macOrZero(p.SrcMAC))
+		for _, vlan := range p.VLANs {
+			header = append(header, byte(etherTypeVLAN>>8), byte(etherTypeVLAN&0xff), byte(vlan>>8&0x0f), byte(vlan))
+		}
+		header = append(header, byte(etherType>>8), byte(etherType))
+		return append(header, b...), nil
+	case LinkTypeRaw:
+		return b, nil
+	case LinkTypeIPv4:
+		if etherType == etherTypeIPv4 {
+			return b, nil
+		}
+	case LinkTypeIPv6:
+		if etherType == etherTypeIPv6 {
+			return b, nil
+		}
+	}
+	return nil, &FormatError{
+		Message: fmt.Sprintf("unsupported link type: %d", linkType),
+	}
+}
+
+// Splice returns a copy of the record whose UDP payload is replaced with the payload.
+// The link, IP and UDP headers are kept as captured, such as VLAN tags, TTL, ID and IP options,
+// and only the lengths and checksums of IP and UDP are recomputed.
+// The UDP checksum stays 0 if it was not computed by the sender of the IPv4 datagram.
+func (r *Record) Splice(payload []byte) (*Record, error) {
+	p, err := r.Packet()
+	if err != nil {
+		return nil, err
+	}
+	u := p.udpOffset
+	end := u + 8 + len(p.Payload)
+	b := make([]byte, 0, len(r.Data)-len(p.Payload)+len(payload))
+	b = append(b, r.Data[:u+8]...)
+	b = append(b, payload...)
+	b = append(b, r.Data[end:]...)
+
+	ip, udp := b[p.ipOffset:u], b[u:u+8+len(payload)]
+	binary.BigEndian.PutUint16(udp[4:6], uint16(len(udp)))
+	var pseudo []byte
+	if ip[0]>>4 == 4 {
+		ihl := int(ip[0]&0x0f) * 4
+		binary.BigEndian.PutUint16(ip[2:4], uint16(len(ip)+len(udp)))
+		ip[10], ip[11] = 0, 0
+		binary.BigEndian.PutUint16(ip[10:12], checksum(ip[:ihl]))
+		pseudo = make([]byte, 12, 12+len(udp))
+		copy(pseudo[0:8], ip[12:20])
+		pseudo[9] = protocolUDP
+		binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(udp)))
+	} else {
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(ip)-40+len(udp)))
+		pseudo = make([]byte, 40, 40+len(udp))
+		copy(pseudo[0:32], ip[8:40])
+		binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(udp)))
+		pseudo[39] = protocolUDP
+	}
+	if ip[0]>>4 != 4 || udp[6] != 0 || udp[7] != 0 {
+		udp[6], udp[7] = 0, 0
+		sum := checksum(append(pseudo, udp...))
+		if sum == 0 {
+			sum = 0xffff
+		}
+		binary.BigEndian.PutUint16(udp[6:8], sum)
+	}
+	spliced := &Record{
+		Timestamp: r.Timestamp,
+		LinkType:  r.LinkType,
+		Data:      b,
+	}
+	if r.OriginalLength > len(r.Data) {
+		spliced.OriginalLength = r.OriginalLength - len(r.Data) + len(b)
+	}
+	return spliced, nil
+}
+
+func macOrZero(mac net.HardwareAddr) net.HardwareAddr {
+	if len(mac) != 6 {
+		return zeroMAC
+	}
+	return mac
+}
+
+func (p *Packet) encodeUDP(pseudo []byte) []byte {
+	b := make([]byte, 8, 8+len(p.Payload))
+	binary.BigEndian.PutUint16(b[0:2], p.SrcPort)
+	binary.BigEndian.PutUint16(b[2:4], p.DstPort)
+	binary.BigEndian.PutUint16(b[4:6], uint16(8+len(p.Payload)))
+	b = append(b, p.Payload...)
+	sum := checksum(append(pseudo, b...))
+	if sum == 0 {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(b[6:8], sum)
+	return b
+}
+
+func (p *Packet) encodeIPv4() []byte {
+	pseudo := make([]byte, 12, 12+8+len(p.Payload))
+	copy(pseudo[0:4], p.Src.To4())
+	copy(pseudo[4:8], p.Dst.To4())
+	pseudo[9] = protocolUDP
+	binary.BigEndian.PutUint16(pseudo[10:12], uint16(8+len(p.Payload)))
+	udp := p.encodeUDP(pseudo)
+	b := make([]byte, 20, 20+len(udp))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(20+len(udp)))
+	b[8] = defaultTTL
+	b[9] = protocolUDP
+	copy(b[12:16], p.Src.To4())
+	copy(b[16:20], p.Dst.To4())
+	binary.BigEndian.PutUint16(b[10:12], checksum(b))
+	return append(b, udp...)
+}
+
+func (p *Packet) encodeIPv6() []byte {
+	pseudo := make([]byte, 40, 40+8+len(p.Payload))
+	copy(pseudo[0:16], p.Src.To16())
+	copy(pseudo[16:32], p.Dst.To16())
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(8+len(p.Payload)))
+	pseudo[39] = protocolUDP
+	udp := p.encodeUDP(pseudo)
+	b := make([]byte, 40, 40+len(udp))
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(udp)))
+	b[6] = protocolUDP
+	b[7] = defaultTTL
+	copy(b[8:24], p.Src.To16())
+	copy(b[24:40], p.Dst.To16())
+	return append(b, udp...)
+}
+
+// checksum computes the internet checksum (RFC 1071).
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
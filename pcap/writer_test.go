@@ -0,0 +1,142 @@
+package pcap
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+func TestWritePacket(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Packet{
+		Timestamp: captureTime,
+		SrcMAC:    net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		DstMAC:    BroadcastMAC,
+		VLANs:     []uint16{100},
+		Src:       net.IPv4zero,
+		Dst:       net.IPv4bcast,
+		SrcPort:   PortDHCPClient,
+		DstPort:   PortDHCPServer,
+		Payload:   udpPayload,
+	}
+	if err := w.WritePacket(p); err != nil {
+		t.Fatal(err)
+	}
+	record := readOne(t, buf.Bytes())
+	if !record.Timestamp.Equal(captureTime) {
+		t.Error(record.Timestamp)
+	}
+	if checksum(record.Data[18:38]) != 0 {
+		t.Error("invalid IPv4 checksum")
+	}
+	pseudo := append([]byte{0, 0, 0, 0, 255, 255, 255, 255, 0, 17, 0, 12}, record.Data[38:]...)
+	if checksum(pseudo) != 0 {
+		t.Error("invalid UDP checksum")
+	}
+	decoded, err := record.Packet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.IsDHCPv4() || decoded.VLANs[0] != 100 || bytes.Compare(decoded.Payload, udpPayload) != 0 {
+		t.Error(decoded)
+	}
+}
+
+func TestEncodeIPv6(t *testing.T) {
+	p := &Packet{
+		Src:     net.ParseIP("fe80::1"),
+		Dst:     net.ParseIP("ff02::1:2"),
+		SrcPort: PortDHCP6Client,
+		DstPort: PortDHCP6Server,
+		Payload: udpPayload,
+	}
+	b, err := p.Encode(LinkTypeRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := Record{LinkType: LinkTypeRaw, Data: b}
+	decoded, err := record.Packet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.IsDHCPv6() || !decoded.Src.Equal(p.Src) || bytes.Compare(decoded.Payload, udpPayload) != 0 {
+		t.Error(decoded)
+	}
+}
+
+func TestSplice(t *testing.T) {
+	p := &Packet{
+		SrcMAC:  net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		DstMAC:  BroadcastMAC,
+		VLANs:   []uint16{100},
+		Src:     net.IPv4zero,
+		Dst:     net.IPv4bcast,
+		SrcPort: PortDHCPClient,
+		DstPort: PortDHCPServer,
+		Payload: udpPayload,
+	}
+	b, err := p.Encode(LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// QinQ TPID, PCP 5, IP ID, DF, TTL 128 and the ethernet trailer
+	b[12], b[13], b[14] = 0x88, 0xa8, 0xa0
+	b[22], b[23], b[24], b[26] = 0x12, 0x34, 0x40, 128
+	b = append(b, 0xee, 0xee)
+	record := &Record{Timestamp: captureTime, LinkType: LinkTypeEthernet, Data: b}
+
+	payload := []byte{5, 6, 7, 8, 9, 10}
+	spliced, err := record.Splice(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := spliced.Data
+	if bytes.Compare(d[:18], b[:18]) != 0 || d[22] != 0x12 || d[23] != 0x34 || d[24] != 0x40 || d[26] != 128 {
+		t.Error("headers are not kept:", d[:38])
+	}
+	if len(d) != len(b)+2 || d[len(d)-2] != 0xee || d[len(d)-1] != 0xee {
+		t.Error("trailer:", d)
+	}
+	if checksum(d[18:38]) != 0 {
+		t.Error("invalid IPv4 checksum")
+	}
+	pseudo := append([]byte{0, 0, 0, 0, 255, 255, 255, 255, 0, 17, 0, 14}, d[38:52]...)
+	if checksum(pseudo) != 0 {
+		t.Error("invalid UDP checksum")
+	}
+	decoded, err := spliced.Packet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(decoded.Payload, payload) != 0 {
+		t.Error(decoded.Payload)
+	}
+}
+
+func TestNewPacket(t *testing.T) {
+	server := dhop.IPv4(net.IPv4(10, 0, 0, 1).To4())
+	m := &dhop.Message{
+		Op:     dhop.OpReply,
+		CHAddr: net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		YIAddr: net.IPv4(10, 0, 0, 2),
+		Options: []dhop.Option{
+			{OptionData: &server, Code: 54},
+		},
+	}
+	p := NewPacket(m, time.Time{})
+	if p.Src.String() != "10.0.0.1" || p.Dst.String() != "10.0.0.2" || p.SrcPort != PortDHCPServer || p.DstPort != PortDHCPClient {
+		t.Error(p)
+	}
+	m.Flags = dhop.FlagBroadcast
+	p = NewPacket(m, time.Time{})
+	if !p.Dst.Equal(net.IPv4bcast) || p.DstMAC.String() != BroadcastMAC.String() {
+		t.Error(p)
+	}
+}
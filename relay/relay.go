@@ -0,0 +1,262 @@
+// Package relay is a DHCPv4 relay agent (RFC 1542) which inserts Relay Agent Information (RFC 3046).
+// It serves on any net.PacketConn, so the behaviour can be tried on loopback UDP sockets.
+package relay
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+// Relay Agent Information sub-options handled by Relay.
+const (
+	SubOptionCircuitID        = 1
+	SubOptionRemoteID         = 2
+	SubOptionLinkSelection    = 5  // RFC 3527
+	SubOptionServerIDOverride = 11 // RFC 5107
+)
+
+// DefaultMaxHops is the hop limit of requests, which is the default of ISC dhcrelay.
+const DefaultMaxHops byte = 10
+
+// pendingTimeout is how long the address of a client is kept to forward the replies.
+const pendingTimeout = time.Minute
+
+// Policy is the handling of requests which already have Relay Agent Information from the client side.
+type Policy byte
+
+const (
+	// PolicyKeep forwards the option as it is without adding the sub-options of the relay.
+	PolicyKeep Policy = iota
+	// PolicyReplace replaces the option with the sub-options of the relay.
+	PolicyReplace
+	// PolicyDrop discards the request.
+	PolicyDrop
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyKeep:
+		return "keep"
+	case PolicyReplace:
+		return "replace"
+	case PolicyDrop:
+		return "drop"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(p))
+}
+
+// Relay forwards requests of clients to servers and replies of servers to clients.
+type Relay struct {
+	// Servers are the addresses which requests are forwarded to.
+	Servers []net.Addr
+	// GIAddr is the address of the relay on the link of clients, which is set as giaddr of requests.
+	GIAddr net.IP
+	// AgentOptions are the sub-options of Relay Agent Information inserted into requests.
+	// Replies are accepted for Link Selection, and Server Identifier Override is set into replies if they are given.
+	AgentOptions []dhop.SubOption
+	Policy       Policy
+	// MaxHops is the limit of hops of requests. DefaultMaxHops is used if zero.
+	MaxHops byte
+	// ErrorLog logs dropped messages and failures of writing. Nothing is logged if nil.
+	ErrorLog *log.Logger
+
+	mu      sync.Mutex
+	pending map[pendingKey]pendingClient
+}
+
+type pendingKey struct {
+	xid    uint32
+	chaddr string
+}
+
+type pendingClient struct {
+	addr net.Addr
+	time time.Time
+}
+
+// Request rewrites the request of a client to forward it to servers.
+// It returns an error if the request must be dropped.
+func (r *Relay) Request(m *dhop.Message) error {
+	if m.Op != dhop.OpRequest {
+		return fmt.Errorf("op %d is not BOOTREQUEST", m.Op)
+	}
+	max := r.MaxHops
+	if max == 0 {
+		max = DefaultMaxHops
+	}
+	if m.Hops >= max {
+		return fmt.Errorf("hops %d reach the limit %d", m.Hops, max)
+	}
+	m.Hops++
+	if !isUnspecified(m.GIAddr) {
+		// Relayed by another agent, which owns the option (RFC 3046 section 2.1.1).
+		return nil
+	}
+	m.GIAddr = r.GIAddr.To4()
+	if _, ok := m.Option(82); ok {
+		switch r.Policy {
+		case PolicyKeep:
+			return nil
+		case PolicyDrop:
+			return fmt.Errorf("request already has relay agent information")
+		}
+	}
+	if len(r.AgentOptions) == 0 {
+		m.DeleteOption(82)
+		return nil
+	}
+	data := dhop.String(dhop.EncodeSubOptions(r.AgentOptions))
+	m.DeleteOption(82)
+	// Relay Agent Information must be the last option (RFC 3046 section 2.1).
+	m.Options = append(m.Options, dhop.Option{OptionData: &data, Code: 82})
+	return nil
+}
+
+// Reply rewrites the reply of a server to forward it to the client, removing Relay Agent Information.
+// It returns an error if the reply is not for the relay.
+func (r *Relay) Reply(m *dhop.Message) error {
+	if m.Op != dhop.OpReply {
+		return fmt.Errorf("op %d is not BOOTREPLY", m.Op)
+	}
+	var agent []dhop.SubOption
+	if o, ok := m.Option(82); ok {
+		a, err := dhop.DecodeSubOptions(o.Encode())
+		if err != nil {
+			return err
+		}
+		agent = a
+	}
+	if !m.GIAddr.Equal(r.GIAddr) {
+		link := r.agentOption(SubOptionLinkSelection)
+		if link == nil || !net.IP(link).Equal(net.IP(subOption(agent, SubOptionLinkSelection))) {
+			return fmt.Errorf("giaddr %s is not the relay", m.GIAddr)
+		}
+	}
+	m.DeleteOption(82)
+	if id := r.agentOption(SubOptionServerIDOverride); len(id) == net.IPv4len {
+		v := dhop.IPv4(append(net.IP{}, id...))
+		m.SetOption(dhop.Option{OptionData: &v, Code: 54})
+	}
+	return nil
+}
+
+func (r *Relay) agentOption(code byte) []byte {
+	return subOption(r.AgentOptions, code)
+}
+
+func subOption(a []dhop.SubOption, code byte) []byte {
+	for _, o := range a {
+		if o.Code == code {
+			return o.Data
+		}
+	}
+	return nil
+}
+
+// Serve forwards requests from the client conn to Servers with the server conn,
+// and replies from the server conn to the clients, until reading either conn fails.
+// Replies are sent back to the address which the request comes from instead of broadcasting,
+// so clients on loopback receive them. Close both conns to stop the relay.
+func (r *Relay) Serve(client, server net.PacketConn) error {
+	errc := make(chan error, 2)
+	go func() {
+		errc <- r.serveClients(client, server)
+	}()
+	go func() {
+		errc <- r.serveServers(client, server)
+	}()
+	return <-errc
+}
+
+func (r *Relay) serveClients(client, server net.PacketConn) error {
+	b := make([]byte, 65536)
+	for {
+		n, addr, err := client.ReadFrom(b)
+		if err != nil {
+			return err
+		}
+		m := &dhop.Message{}
+		if err := m.Decode(b[:n]); err != nil {
+			r.logf("dhop: invalid message from %s: %s", addr, err)
+			continue
+		}
+		if err := r.Request(m); err != nil {
+			r.logf("dhop: request from %s is dropped: %s", addr, err)
+			continue
+		}
+		r.remember(m, addr)
+		p := m.Encode()
+		for _, s := range r.Servers {
+			if _, err := server.WriteTo(p, s); err != nil {
+				r.logf("dhop: failed to forward to %s: %s", s, err)
+			}
+		}
+	}
+}
+
+func (r *Relay) serveServers(client, server net.PacketConn) error {
+	b := make([]byte, 65536)
+	for {
+		n, addr, err := server.ReadFrom(b)
+		if err != nil {
+			return err
+		}
+		m := &dhop.Message{}
+		if err := m.Decode(b[:n]); err != nil {
+			r.logf("dhop: invalid message from %s: %s", addr, err)
+			continue
+		}
+		if err := r.Reply(m); err != nil {
+			r.logf("dhop: reply from %s is dropped: %s", addr, err)
+			continue
+		}
+		dst := r.lookup(m)
+		if dst == nil {
+			r.logf("dhop: reply from %s is dropped: unknown client %s", addr, m.CHAddr)
+			continue
+		}
+		if _, err := client.WriteTo(m.Encode(), dst); err != nil {
+			r.logf("dhop: failed to reply to %s: %s", dst, err)
+		}
+	}
+}
+
+func (r *Relay) remember(m *dhop.Message, addr net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.pending == nil {
+		r.pending = make(map[pendingKey]pendingClient)
+	}
+	for k, c := range r.pending {
+		if now.Sub(c.time) > pendingTimeout {
+			delete(r.pending, k)
+		}
+	}
+	r.pending[pendingKey{m.XID, m.CHAddr.String()}] = pendingClient{addr, now}
+}
+
+func (r *Relay) lookup(m *dhop.Message) net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.pending[pendingKey{m.XID, m.CHAddr.String()}]
+	if !ok {
+		return nil
+	}
+	return c.addr
+}
+
+func (r *Relay) logf(format string, v ...interface{}) {
+	if r.ErrorLog != nil {
+		r.ErrorLog.Printf(format, v...)
+	}
+}
+
+func isUnspecified(ip net.IP) bool {
+	return ip == nil || ip.IsUnspecified()
+}
@@ -0,0 +1,175 @@
+package relay
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bgpat/dhop"
+	"github.com/bgpat/dhop/server"
+)
+
+var testGIAddr = net.IPv4(192, 168, 1, 1).To4()
+
+func newMessage(op byte, options ...dhop.Option) *dhop.Message {
+	typ := dhop.Byte(dhop.MessageTypeDiscover)
+	if op == dhop.OpReply {
+		typ = dhop.Byte(dhop.MessageTypeOffer)
+	}
+	return &dhop.Message{
+		Op:      op,
+		HType:   1,
+		HLen:    6,
+		XID:     0x12345678,
+		CIAddr:  net.IPv4zero,
+		YIAddr:  net.IPv4zero,
+		SIAddr:  net.IPv4zero,
+		GIAddr:  net.IPv4zero,
+		CHAddr:  net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Options: append([]dhop.Option{{OptionData: &typ, Code: 53}}, options...),
+	}
+}
+
+func agentOption(a ...dhop.SubOption) dhop.Option {
+	s := dhop.String(dhop.EncodeSubOptions(a))
+	return dhop.Option{OptionData: &s, Code: 82}
+}
+
+func TestRelayRequest(t *testing.T) {
+	circuit := dhop.SubOption{Code: SubOptionCircuitID, Data: []byte("eth0")}
+	client := dhop.SubOption{Code: SubOptionCircuitID, Data: []byte("spoofed")}
+	for _, c := range []struct {
+		policy  Policy
+		options []dhop.Option
+		agent   []byte
+		err     bool
+	}{
+		{PolicyKeep, nil, dhop.EncodeSubOptions([]dhop.SubOption{circuit}), false},
+		{PolicyKeep, []dhop.Option{agentOption(client)}, dhop.EncodeSubOptions([]dhop.SubOption{client}), false},
+		{PolicyReplace, []dhop.Option{agentOption(client)}, dhop.EncodeSubOptions([]dhop.SubOption{circuit}), false},
+		{PolicyDrop, []dhop.Option{agentOption(client)}, nil, true},
+		{PolicyDrop, nil, dhop.EncodeSubOptions([]dhop.SubOption{circuit}), false},
+	} {
+		r := &Relay{GIAddr: testGIAddr, AgentOptions: []dhop.SubOption{circuit}, Policy: c.policy}
+		m := newMessage(dhop.OpRequest, c.options...)
+		err := r.Request(m)
+		if (err != nil) != c.err {
+			t.Error(c.policy, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !m.GIAddr.Equal(testGIAddr) || m.Hops != 1 {
+			t.Error(c.policy, m.GIAddr, m.Hops)
+		}
+		o := m.Options[len(m.Options)-1]
+		if o.Code != 82 || !bytes.Equal(o.Encode(), c.agent) {
+			t.Errorf("%s: %d %q", c.policy, o.Code, o.Encode())
+		}
+	}
+
+	r := &Relay{GIAddr: testGIAddr, MaxHops: 4}
+	m := newMessage(dhop.OpRequest)
+	m.Hops = 4
+	if err := r.Request(m); err == nil {
+		t.Error("hop limit is not enforced")
+	}
+	m = newMessage(dhop.OpRequest, agentOption(client))
+	m.GIAddr = net.IPv4(172, 16, 0, 1)
+	if err := r.Request(m); err != nil || !m.GIAddr.Equal(net.IPv4(172, 16, 0, 1)) || m.Hops != 1 {
+		t.Error("relayed request:", err, m.GIAddr, m.Hops)
+	}
+	if _, ok := m.Option(82); !ok {
+		t.Error("option of another relay is removed")
+	}
+}
+
+func TestRelayReply(t *testing.T) {
+	link := dhop.SubOption{Code: SubOptionLinkSelection, Data: net.IPv4(10, 0, 0, 0).To4()}
+	override := dhop.SubOption{Code: SubOptionServerIDOverride, Data: testGIAddr}
+	r := &Relay{GIAddr: testGIAddr, AgentOptions: []dhop.SubOption{link, override}}
+
+	id := dhop.IPv4(net.IPv4(10, 0, 0, 1).To4())
+	m := newMessage(dhop.OpReply, dhop.Option{OptionData: &id, Code: 54}, agentOption(link, override))
+	m.GIAddr = net.IPv4(10, 0, 0, 254)
+	if err := r.Reply(m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Option(82); ok {
+		t.Error("relay agent information is not removed")
+	}
+	if !m.ServerIdentifier().Equal(testGIAddr) {
+		t.Error("server identifier is not overridden:", m.ServerIdentifier())
+	}
+
+	m = newMessage(dhop.OpReply)
+	m.GIAddr = net.IPv4(10, 0, 0, 254)
+	if err := r.Reply(m); err == nil {
+		t.Error("reply to another relay is accepted")
+	}
+}
+
+func TestRelayServe(t *testing.T) {
+	p, err := server.NewPool(net.IPv4(192, 168, 1, 100), net.IPv4(192, 168, 1, 199))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+	relayedc := make(chan *dhop.Message, 1)
+	h := &server.PoolHandler{ServerID: net.IPv4(127, 0, 0, 1), Pool: p, LeaseTime: time.Hour}
+	go server.Serve(serverConn, server.HandlerFunc(func(req *dhop.Message) *dhop.Message {
+		relayedc <- req
+		return h.ServeDHCP(req)
+	}))
+
+	clientSide, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientSide.Close()
+	serverSide, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverSide.Close()
+	r := &Relay{
+		Servers:      []net.Addr{serverConn.LocalAddr()},
+		GIAddr:       testGIAddr,
+		AgentOptions: []dhop.SubOption{{Code: SubOptionCircuitID, Data: []byte("eth0")}},
+	}
+	go r.Serve(clientSide, serverSide)
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.WriteTo(newMessage(dhop.OpRequest).Encode(), clientSide.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer := &dhop.Message{}
+	if err := offer.Decode(b[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if offer.Type() != dhop.MessageTypeOffer || !offer.YIAddr.Equal(net.IPv4(192, 168, 1, 100)) {
+		t.Error(offer.Type(), offer.YIAddr)
+	}
+	relayed := <-relayedc
+	if !relayed.GIAddr.Equal(testGIAddr) || relayed.Hops != 1 {
+		t.Error("relayed request:", relayed)
+	} else if _, ok := relayed.Option(82); !ok {
+		t.Error("no relay agent information")
+	}
+}
@@ -0,0 +1,159 @@
+package dhop
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	// MinMessageSize is the size of the IP datagram which every DHCP client accepts (RFC 2131 section 2).
+	MinMessageSize = 576
+	// IPUDPHeaderSize is the size of the IPv4 and UDP headers around a DHCP message without IP options.
+	IPUDPHeaderSize = 28
+)
+
+// MissingOptionError is returned when a mandatory option of a reply is not given.
+type MissingOptionError struct {
+	Type MessageType
+	Code Code
+}
+
+func (err *MissingOptionError) Error() string {
+	return fmt.Sprintf("%s requires option %d (%s)", err.Type, err.Code, err.Code.String())
+}
+
+// ParameterRequestList returns the codes in the Parameter Request List option.
+func (m *Message) ParameterRequestList() []Code {
+	o, ok := m.Option(55)
+	if !ok {
+		return nil
+	}
+	b := o.Encode()
+	codes := make([]Code, len(b))
+	for i, c := range b {
+		codes[i] = Code(c)
+	}
+	return codes
+}
+
+// MaxMessageSize returns the size of the IP datagram which the client accepts,
+// the Maximum DHCP Message Size option or MinMessageSize.
+func (m *Message) MaxMessageSize() int {
+	o, ok := m.Option(57)
+	if !ok {
+		return MinMessageSize
+	}
+	s, ok := o.OptionData.(*Size)
+	if !ok || int(*s) < MinMessageSize {
+		return MinMessageSize
+	}
+	return int(*s)
+}
+
+// NewReply returns the reply of the type to the request with the options of the server (RFC 2131 section 4.3).
+// The header fields xid, flags, giaddr and chaddr are copied, and ciaddr is copied into DHCPACK.
+// The caller sets yiaddr, siaddr, sname and file of the reply.
+//
+// The options of the reply start with DHCP Message Type, Server Identifier and IP Address Lease Time,
+// which are mandatory for DHCPOFFER and DHCPACK except IP Address Lease Time in DHCPACK to DHCPINFORM.
+// The other options follow in the order of the Parameter Request List of the request,
+// and the options which are not requested come last.
+// Client Identifier (RFC 6842) and Relay Agent Information (RFC 3046) of the request are echoed.
+// DHCPNAK has only Server Identifier and Message of the options of the server.
+// Options which do not fit in the Maximum DHCP Message Size of the request are dropped from the end.
+func NewReply(req *Message, t MessageType, options []Option) (*Message, error) {
+	m := &Message{
+		Op:     OpReply,
+		HType:  req.HType,
+		HLen:   req.HLen,
+		XID:    req.XID,
+		Flags:  req.Flags,
+		CIAddr: net.IPv4zero,
+		YIAddr: net.IPv4zero,
+		SIAddr: net.IPv4zero,
+		GIAddr: req.GIAddr,
+		CHAddr: req.CHAddr,
+	}
+	if m.GIAddr == nil {
+		m.GIAddr = net.IPv4zero
+	}
+	if t == MessageTypeAck && req.CIAddr != nil {
+		m.CIAddr = req.CIAddr
+	}
+	if t == MessageTypeNak && !isUnspecified(req.GIAddr) {
+		m.Flags |= FlagBroadcast
+	}
+	inform := req.Type() == MessageTypeInform
+
+	typ := Byte(t)
+	head := []Option{{OptionData: &typ, Code: 53}}
+	mandatory := []Code{54}
+	switch t {
+	case MessageTypeOffer:
+		mandatory = append(mandatory, 51)
+	case MessageTypeAck:
+		if !inform {
+			mandatory = append(mandatory, 51)
+		}
+	}
+	for _, code := range mandatory {
+		o, ok := findOption(options, code)
+		if !ok {
+			return nil, &MissingOptionError{Type: t, Code: code}
+		}
+		head = append(head, o)
+	}
+
+	var body []Option
+	rest := make([]Option, 0, len(options))
+	for _, o := range options {
+		switch o.Code {
+		case 0, 50, 51, 53, 54, 55, 57, 61, 82, 255:
+			continue
+		}
+		if t == MessageTypeNak && o.Code != 56 {
+			continue
+		}
+		rest = append(rest, o)
+	}
+	for _, code := range req.ParameterRequestList() {
+		for i := 0; i < len(rest); i++ {
+			if rest[i].Code == code {
+				body = append(body, rest[i])
+				rest = append(rest[:i], rest[i+1:]...)
+				i--
+			}
+		}
+	}
+	body = append(body, rest...)
+
+	var tail []Option
+	if o, ok := req.Option(61); ok {
+		head = append(head, o)
+	}
+	if o, ok := req.Option(82); ok {
+		tail = append(tail, o)
+	}
+
+	limit := req.MaxMessageSize() - IPUDPHeaderSize - MessageHeaderSize - len(MagicCookie) - 1
+	size := len(EncodeOptions(head)) + len(EncodeOptions(tail))
+	for i, o := range body {
+		n := len(EncodeOptions([]Option{o}))
+		if size+n > limit {
+			body = body[:i]
+			break
+		}
+		size += n
+	}
+	m.Options = append(append(head, body...), tail...)
+	return m, nil
+}
+
+func findOption(options []Option, code Code) (Option, bool) {
+	for _, o := range options {
+		if o.Code == code {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
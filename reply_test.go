@@ -0,0 +1,120 @@
+package dhop
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func replyServerOptions() []Option {
+	id := IPv4(net.IPv4(10, 0, 0, 1).To4())
+	lease := TimeDuration(time.Hour)
+	mask := IPv4(net.IPv4(255, 255, 255, 0).To4())
+	domain := String("example.com")
+	return []Option{
+		{OptionData: &mask, Code: 1},
+		{OptionData: &IPv4s{IPv4(net.IPv4(10, 0, 0, 1).To4())}, Code: 3},
+		{OptionData: &domain, Code: 15},
+		{OptionData: &lease, Code: 51},
+		{OptionData: &id, Code: 54},
+	}
+}
+
+func replyRequest(t MessageType, options ...Option) *Message {
+	return &Message{
+		Op:      OpRequest,
+		HType:   1,
+		HLen:    6,
+		XID:     0x12345678,
+		Flags:   FlagBroadcast,
+		CIAddr:  net.IPv4zero.To4(),
+		GIAddr:  net.IPv4(192, 168, 0, 1).To4(),
+		CHAddr:  net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Options: append([]Option{{OptionData: newByte(byte(t)), Code: 53}}, options...),
+	}
+}
+
+func replyCodes(m *Message) []Code {
+	codes := make([]Code, len(m.Options))
+	for i, o := range m.Options {
+		codes[i] = o.Code
+	}
+	return codes
+}
+
+func TestNewReply(t *testing.T) {
+	prl := String([]byte{15, 3, 1})
+	id := String([]byte{1, 0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	agent := String([]byte{1, 4, 'e', 't', 'h', '0'})
+	req := replyRequest(MessageTypeDiscover,
+		Option{OptionData: &id, Code: 61},
+		Option{OptionData: &prl, Code: 55},
+		Option{OptionData: &agent, Code: 82},
+	)
+	m, err := NewReply(req, MessageTypeOffer, replyServerOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Op != OpReply || m.XID != req.XID || !m.Broadcast() || !m.GIAddr.Equal(req.GIAddr) || m.CHAddr.String() != req.CHAddr.String() {
+		t.Error(m)
+	}
+	if codes := replyCodes(m); len(codes) != 8 || codes[0] != 53 || codes[1] != 54 || codes[2] != 51 || codes[3] != 61 ||
+		codes[4] != 15 || codes[5] != 3 || codes[6] != 1 || codes[7] != 82 {
+		t.Error(codes)
+	}
+
+	m, err = NewReply(req, MessageTypeNak, replyServerOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes := replyCodes(m); len(codes) != 4 || codes[1] != 54 || codes[2] != 61 || codes[3] != 82 {
+		t.Error("DHCPNAK:", codes)
+	}
+
+	inform := replyRequest(MessageTypeInform)
+	inform.CIAddr = net.IPv4(10, 0, 0, 5).To4()
+	m, err = NewReply(inform, MessageTypeAck, replyServerOptions()[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Option(51); ok || !m.CIAddr.Equal(inform.CIAddr) {
+		t.Error("DHCPACK to DHCPINFORM:", replyCodes(m), m.CIAddr)
+	}
+}
+
+func TestNewReplyMissingOption(t *testing.T) {
+	_, err := NewReply(replyRequest(MessageTypeRequest), MessageTypeAck, replyServerOptions()[:4])
+	if e, ok := err.(*MissingOptionError); !ok || e.Code != 54 {
+		t.Error(err)
+	}
+	_, err = NewReply(replyRequest(MessageTypeRequest), MessageTypeAck, replyServerOptions()[4:])
+	if e, ok := err.(*MissingOptionError); !ok || e.Code != 51 {
+		t.Error(err)
+	} else if err.Error() != "DHCPACK requires option 51 (Address Time)" {
+		t.Error(err)
+	}
+}
+
+func TestNewReplyMaxMessageSize(t *testing.T) {
+	long := String(strings.Repeat("a", 200))
+	options := append(replyServerOptions(), Option{OptionData: &long, Code: 66}, Option{OptionData: &long, Code: 67})
+	m, err := NewReply(replyRequest(MessageTypeDiscover), MessageTypeOffer, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.Encode()) + IPUDPHeaderSize; n > MinMessageSize {
+		t.Error("size:", n)
+	}
+	if _, ok := m.Option(67); ok {
+		t.Error("option over the size is not dropped")
+	}
+	size := Size(1500)
+	m, err = NewReply(replyRequest(MessageTypeDiscover, Option{OptionData: &size, Code: 57}), MessageTypeOffer, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Option(67); !ok {
+		t.Error("maximum message size is ignored")
+	}
+}
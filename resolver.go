@@ -0,0 +1,161 @@
+package dhop
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// MaxNameservers is the number of nameservers glibc reads from resolv.conf (MAXNS).
+	MaxNameservers = 3
+	// MaxSearchDomains is the number of search domains glibc reads from resolv.conf (MAXDNSRCH).
+	MaxSearchDomains = 6
+)
+
+// DHCPv6 options of DNS configuration (RFC 3646).
+const (
+	Option6DNSServers   = 23
+	Option6DomainSearch = 24
+)
+
+// ResolverConfig is the DNS resolver configuration given by DHCP options.
+// Warnings describe the servers and domains which are ignored or exceed the limits of glibc.
+type ResolverConfig struct {
+	Nameservers []net.IP
+	Search      []string
+	Warnings    []string
+}
+
+// NewResolverConfig returns the configuration of the DHCPv4 and DHCPv6 options.
+// Nameservers are Domain Name Server options followed by the DHCPv6 DNS Recursive Name Servers.
+// Search domains are Domain Search options of DHCPv4 and DHCPv6, or Domain Name if both are absent.
+// Duplicated servers and domains are removed.
+func NewResolverConfig(options []Option, options6 []Option6) *ResolverConfig {
+	c := &ResolverConfig{}
+	var domain string
+	hasSearch := false
+	for _, o := range options {
+		switch v := o.OptionData.(type) {
+		case *IPv4s:
+			if o.Code == 6 {
+				for _, ip := range *v {
+					c.addNameserver(net.IP(ip))
+				}
+			}
+		case *DomainNames:
+			if o.Code == 119 {
+				hasSearch = true
+				for _, dn := range *v {
+					c.addSearch(string(dn.Marshal()))
+				}
+			}
+		case *String:
+			if o.Code == 15 {
+				domain = string(*v)
+			}
+		}
+	}
+	for _, o := range options6 {
+		switch o.Code {
+		case Option6DNSServers:
+			if len(o.Data)%net.IPv6len != 0 {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("DHCPv6 option %d has invalid length %d", o.Code, len(o.Data)))
+				continue
+			}
+			for i := 0; i < len(o.Data); i += net.IPv6len {
+				c.addNameserver(net.IP(o.Data[i : i+net.IPv6len]))
+			}
+		case Option6DomainSearch:
+			dns := DomainNames{}
+			if err := dns.Decode(o.Data); err != nil {
+				c.Warnings = append(c.Warnings, fmt.Sprintf("DHCPv6 option %d: %s", o.Code, err))
+				continue
+			}
+			hasSearch = true
+			for _, dn := range dns {
+				c.addSearch(string(dn.Marshal()))
+			}
+		}
+	}
+	if hasSearch {
+		if domain != "" {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("domain name %s is overridden by the domain search list", domain))
+		}
+	} else if domain != "" {
+		c.addSearch(domain)
+	}
+	if len(c.Nameservers) > MaxNameservers {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("%d nameservers exceed the limit of glibc, %d", len(c.Nameservers), MaxNameservers))
+	}
+	if len(c.Search) > MaxSearchDomains {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("%d search domains exceed the limit of glibc, %d", len(c.Search), MaxSearchDomains))
+	}
+	return c
+}
+
+func (c *ResolverConfig) addNameserver(ip net.IP) {
+	if isUnspecified(ip) {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("nameserver %s is ignored", ip))
+		return
+	}
+	for _, s := range c.Nameservers {
+		if s.Equal(ip) {
+			return
+		}
+	}
+	c.Nameservers = append(c.Nameservers, ip)
+}
+
+func (c *ResolverConfig) addSearch(domain string) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return
+	}
+	for _, s := range c.Search {
+		if strings.EqualFold(s, domain) {
+			return
+		}
+	}
+	c.Search = append(c.Search, domain)
+}
+
+// ResolvConf returns the text of resolv.conf.
+// Nameservers and search domains over the limits of glibc are omitted.
+func (c *ResolverConfig) ResolvConf() []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString("# Generated by dhop\n")
+	for i, ip := range c.Nameservers {
+		if i >= MaxNameservers {
+			break
+		}
+		fmt.Fprintf(&buf, "nameserver %s\n", ip)
+	}
+	search := c.Search
+	if len(search) > MaxSearchDomains {
+		search = search[:MaxSearchDomains]
+	}
+	if len(search) > 0 {
+		fmt.Fprintf(&buf, "search %s\n", strings.Join(search, " "))
+	}
+	return buf.Bytes()
+}
+
+// Resolved returns the text of a drop-in file of systemd-resolved, such as /etc/systemd/resolved.conf.d/dhop.conf.
+// systemd-resolved has no limit, so all the nameservers and search domains are written.
+func (c *ResolverConfig) Resolved() []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString("# Generated by dhop\n[Resolve]\n")
+	if len(c.Nameservers) > 0 {
+		s := make([]string, len(c.Nameservers))
+		for i, ip := range c.Nameservers {
+			s[i] = ip.String()
+		}
+		fmt.Fprintf(&buf, "DNS=%s\n", strings.Join(s, " "))
+	}
+	if len(c.Search) > 0 {
+		fmt.Fprintf(&buf, "Domains=%s\n", strings.Join(c.Search, " "))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,56 @@
+package dhop
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNewResolverConfig(t *testing.T) {
+	dns := IPv4s{
+		IPv4(net.IPv4(10, 0, 0, 2).To4()),
+		IPv4(net.IPv4(10, 0, 0, 3).To4()),
+		IPv4(net.IPv4(10, 0, 0, 2).To4()),
+	}
+	domain := String("example.org")
+	search := DomainNames{DomainName{"example", "com"}, DomainName{"Example", "COM"}}
+	search6 := DomainNames{DomainName{"example", "net"}, DomainName{"a"}, DomainName{"b"}, DomainName{"c"}, DomainName{"d"}, DomainName{"e"}}
+	c := NewResolverConfig(
+		[]Option{
+			{OptionData: &dns, Code: 6},
+			{OptionData: &domain, Code: 15},
+			{OptionData: &search, Code: 119},
+		},
+		[]Option6{
+			{Code: Option6DNSServers, Data: append(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")...)},
+			{Code: Option6DomainSearch, Data: search6.Encode()},
+		},
+	)
+	if len(c.Nameservers) != 4 || len(c.Search) != 7 || len(c.Warnings) != 3 {
+		t.Fatal(c)
+	}
+	expected := `# Generated by dhop
+nameserver 10.0.0.2
+nameserver 10.0.0.3
+nameserver 2001:db8::1
+search example.com example.net a b c d
+`
+	if s := string(c.ResolvConf()); s != expected {
+		t.Error(s)
+	}
+	expected = `# Generated by dhop
+[Resolve]
+DNS=10.0.0.2 10.0.0.3 2001:db8::1 2001:db8::2
+Domains=example.com example.net a b c d e
+`
+	if s := string(c.Resolved()); s != expected {
+		t.Error(s)
+	}
+}
+
+func TestNewResolverConfigDomainName(t *testing.T) {
+	domain := String("example.org.")
+	c := NewResolverConfig([]Option{{OptionData: &domain, Code: 15}}, []Option6{{Code: Option6DNSServers, Data: []byte{1}}})
+	if len(c.Search) != 1 || c.Search[0] != "example.org" || len(c.Warnings) != 1 {
+		t.Error(c)
+	}
+}
@@ -0,0 +1,166 @@
+package dhop
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+type RouteMode byte
+
+const (
+	// RouteModeRFC3442 ignores Router and Static Route options if Classless Static Route is present (RFC 3442).
+	RouteModeRFC3442 RouteMode = iota
+	// RouteModeWindows also uses Classless Static Route Option (Microsoft) if Classless Static Route is absent,
+	// and installs the default routes of Router options in addition to classless static routes as Windows does.
+	RouteModeWindows
+)
+
+func (m RouteMode) String() string {
+	switch m {
+	case RouteModeRFC3442:
+		return "rfc3442"
+	case RouteModeWindows:
+		return "windows"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(m))
+}
+
+// RouteEntry is a route which a client installs.
+// Gateway is 0.0.0.0 if the destination is on the link.
+type RouteEntry struct {
+	Destination net.IPNet
+	Gateway     net.IP
+	Metric      int
+	// Code is the option which the route comes from.
+	Code Code
+}
+
+// OnLink returns whether the route has no gateway.
+func (r *RouteEntry) OnLink() bool {
+	return isUnspecified(r.Gateway)
+}
+
+// Spec returns the route in the format of `ip route show`, such as `default via 10.0.0.1 dev eth0`.
+// The device is omitted if dev is empty.
+func (r *RouteEntry) Spec(dev string) string {
+	buf := bytes.Buffer{}
+	if ones, _ := r.Destination.Mask.Size(); ones == 0 {
+		buf.WriteString("default")
+	} else {
+		buf.WriteString(r.Destination.String())
+	}
+	if !r.OnLink() {
+		fmt.Fprintf(&buf, " via %s", r.Gateway)
+	}
+	if dev != "" {
+		fmt.Fprintf(&buf, " dev %s", dev)
+	}
+	if r.OnLink() {
+		buf.WriteString(" scope link")
+	}
+	if r.Metric > 0 {
+		fmt.Fprintf(&buf, " metric %d", r.Metric)
+	}
+	return buf.String()
+}
+
+// Command returns the `ip route add` command which installs the route.
+func (r *RouteEntry) Command(dev string) string {
+	return "ip route add " + r.Spec(dev)
+}
+
+// EffectiveRoutes returns the routes which a client installs for the options in the mode.
+// Routes to the same destination as an earlier route are ignored,
+// and the default routes of Router options have increasing metrics in their order.
+func EffectiveRoutes(options []Option, mode RouteMode) []RouteEntry {
+	var routers []IPv4
+	var static []IPv4Pair
+	var classless, microsoft []Route
+	hasClassless, hasMicrosoft := false, false
+	for _, o := range options {
+		switch v := o.OptionData.(type) {
+		case *IPv4s:
+			if o.Code == 3 {
+				routers = append(routers, *v...)
+			}
+		case *IPv4Pair:
+			if o.Code == 33 {
+				static = append(static, *v)
+			}
+		case *IPv4Pairs:
+			if o.Code == 33 {
+				static = append(static, *v...)
+			}
+		case *Routes:
+			switch o.Code {
+			case 121:
+				classless = append(classless, *v...)
+				hasClassless = true
+			case 249:
+				microsoft = append(microsoft, *v...)
+				hasMicrosoft = true
+			}
+		}
+	}
+	entries := make([]RouteEntry, 0, len(routers)+len(static)+len(classless))
+	add := func(e RouteEntry) {
+		for _, r := range entries {
+			if r.Destination.String() == e.Destination.String() && r.Metric == e.Metric {
+				return
+			}
+		}
+		entries = append(entries, e)
+	}
+	classlessCode := Code(121)
+	if !hasClassless && hasMicrosoft && mode == RouteModeWindows {
+		classless = microsoft
+		hasClassless = true
+		classlessCode = 249
+	}
+	if hasClassless {
+		for _, r := range classless {
+			add(RouteEntry{
+				Destination: net.IPNet{IP: r.Source.IP.Mask(r.Source.Mask).To4(), Mask: r.Source.Mask},
+				Gateway:     r.Destination.To4(),
+				Code:        classlessCode,
+			})
+		}
+		if mode != RouteModeWindows {
+			return entries
+		}
+	}
+	if !hasClassless {
+		for _, p := range static {
+			dst := net.IP(p[0]).To4()
+			if dst == nil || dst.IsUnspecified() {
+				// The default route is not allowed in Static Route (RFC 2132).
+				continue
+			}
+			add(RouteEntry{
+				Destination: classfulNetwork(dst),
+				Gateway:     net.IP(p[1]).To4(),
+				Code:        33,
+			})
+		}
+	}
+	for i, r := range routers {
+		add(RouteEntry{
+			Destination: net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+			Gateway:     net.IP(r).To4(),
+			Metric:      i,
+			Code:        3,
+		})
+	}
+	return entries
+}
+
+// classfulNetwork returns the network of the address with the mask of its class,
+// or the host route if the address has bits outside of the mask.
+func classfulNetwork(ip net.IP) net.IPNet {
+	mask := ip.DefaultMask()
+	if mask == nil || !ip.Mask(mask).Equal(ip) {
+		mask = net.CIDRMask(32, 32)
+	}
+	return net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
@@ -0,0 +1,105 @@
+package dhop
+
+import (
+	"net"
+	"testing"
+)
+
+func routeOptions(classless, microsoft string) []Option {
+	routers := IPv4s{IPv4(net.IPv4(10, 0, 0, 1).To4()), IPv4(net.IPv4(10, 0, 0, 2).To4())}
+	static := IPv4Pair{IPv4(net.IPv4(172, 16, 0, 0).To4()), IPv4(net.IPv4(10, 0, 0, 3).To4())}
+	options := []Option{
+		{OptionData: &routers, Code: 3},
+		{OptionData: &static, Code: 33},
+	}
+	if classless != "" {
+		routes := Routes{}
+		routes.Unmarshal([]byte(classless))
+		options = append(options, Option{OptionData: &routes, Code: 121})
+	}
+	if microsoft != "" {
+		routes := Routes{}
+		routes.Unmarshal([]byte(microsoft))
+		options = append(options, Option{OptionData: &routes, Code: 249})
+	}
+	return options
+}
+
+func routeCommands(entries []RouteEntry) []string {
+	commands := make([]string, len(entries))
+	for i, e := range entries {
+		commands[i] = e.Command("eth0")
+	}
+	return commands
+}
+
+func TestEffectiveRoutes(t *testing.T) {
+	for _, c := range []struct {
+		classless string
+		microsoft string
+		mode      RouteMode
+		expected  []string
+	}{
+		{
+			mode: RouteModeRFC3442,
+			expected: []string{
+				"ip route add 172.16.0.0/16 via 10.0.0.3 dev eth0",
+				"ip route add default via 10.0.0.1 dev eth0",
+				"ip route add default via 10.0.0.2 dev eth0 metric 1",
+			},
+		},
+		{
+			classless: "192.168.100.0/24 10.0.0.4,0.0.0.0/0 10.0.0.5,10.0.1.0/24 0.0.0.0",
+			microsoft: "192.168.200.0/24 10.0.0.6",
+			mode:      RouteModeRFC3442,
+			expected: []string{
+				"ip route add 192.168.100.0/24 via 10.0.0.4 dev eth0",
+				"ip route add default via 10.0.0.5 dev eth0",
+				"ip route add 10.0.1.0/24 dev eth0 scope link",
+			},
+		},
+		{
+			microsoft: "192.168.200.0/24 10.0.0.6",
+			mode:      RouteModeRFC3442,
+			expected: []string{
+				"ip route add 172.16.0.0/16 via 10.0.0.3 dev eth0",
+				"ip route add default via 10.0.0.1 dev eth0",
+				"ip route add default via 10.0.0.2 dev eth0 metric 1",
+			},
+		},
+		{
+			microsoft: "192.168.200.0/24 10.0.0.6",
+			mode:      RouteModeWindows,
+			expected: []string{
+				"ip route add 192.168.200.0/24 via 10.0.0.6 dev eth0",
+				"ip route add default via 10.0.0.1 dev eth0",
+				"ip route add default via 10.0.0.2 dev eth0 metric 1",
+			},
+		},
+	} {
+		commands := routeCommands(EffectiveRoutes(routeOptions(c.classless, c.microsoft), c.mode))
+		if len(commands) != len(c.expected) {
+			t.Errorf("%s: %v", c.mode, commands)
+			continue
+		}
+		for i, s := range commands {
+			if s != c.expected[i] {
+				t.Errorf("%s: %s", c.mode, s)
+			}
+		}
+	}
+}
+
+func TestClassfulNetwork(t *testing.T) {
+	for ip, expected := range map[string]string{
+		"10.0.0.0":    "10.0.0.0/8",
+		"172.16.0.0":  "172.16.0.0/16",
+		"192.168.1.0": "192.168.1.0/24",
+		"10.1.0.0":    "10.1.0.0/32",
+	} {
+		n := classfulNetwork(net.ParseIP(ip).To4())
+		if n.String() != expected {
+			t.Error(n.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package dhop
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Names of the scopes of server configurations from the lowest precedence.
+const (
+	ScopeGlobal        = "global"
+	ScopeSharedNetwork = "shared-network"
+	ScopeSubnet        = "subnet"
+	ScopePool          = "pool"
+	ScopeClass         = "class"
+	ScopeHost          = "host"
+)
+
+// MergeMode is how the options of a layer are merged with the lower layers.
+type MergeMode byte
+
+const (
+	// MergeOverride replaces the data of the lower layers.
+	MergeOverride MergeMode = iota
+	// MergeAppend appends the data to the data of the lower layers, such as Domain Name Servers.
+	MergeAppend
+	// MergeRemove removes the option of the lower layers. The option is removed even if the layer does not have it.
+	MergeRemove
+)
+
+func (m MergeMode) String() string {
+	switch m {
+	case MergeOverride:
+		return "override"
+	case MergeAppend:
+		return "append"
+	case MergeRemove:
+		return "remove"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(m))
+}
+
+// Layer is a scope of options in a server configuration.
+// Modes are the merge modes of the codes, and MergeOverride is used for the codes which are not in Modes.
+type Layer struct {
+	Name    string
+	Options []Option
+	Modes   map[Code]MergeMode
+}
+
+// MergedOption is an option of the merged set with the names of the layers which supplied the data.
+type MergedOption struct {
+	Option
+	Sources []string
+}
+
+// MergeLayers merges the layers given from the lowest precedence, such as
+// global, shared-network, subnet, pool, class and host, and returns the options ordered by code.
+// Options of the same code in a layer are merged as one option.
+// Appended data is decoded as the type of the code, or kept as String if it cannot be decoded.
+func MergeLayers(layers []Layer) []MergedOption {
+	merged := make(map[Code]*MergedOption)
+	// last is the index of the last layer which supplied the option.
+	last := make(map[Code]int)
+	for i, l := range layers {
+		for code, mode := range l.Modes {
+			if mode == MergeRemove {
+				delete(merged, code)
+			}
+		}
+		for _, o := range l.Options {
+			mode := l.Modes[o.Code]
+			if mode == MergeRemove {
+				continue
+			}
+			m, ok := merged[o.Code]
+			if !ok || (mode == MergeOverride && last[o.Code] != i) {
+				merged[o.Code] = &MergedOption{Option: o, Sources: []string{l.Name}}
+				last[o.Code] = i
+				continue
+			}
+			m.Option = appendOption(m.Option, o)
+			if last[o.Code] != i {
+				m.Sources = append(m.Sources, l.Name)
+				last[o.Code] = i
+			}
+		}
+	}
+	options := make([]MergedOption, 0, len(merged))
+	for _, m := range merged {
+		options = append(options, *m)
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return options[i].Code < options[j].Code
+	})
+	return options
+}
+
+func appendOption(a, b Option) Option {
+	data := append(append([]byte{}, a.Encode()...), b.Encode()...)
+	o, err := Decode(byte(a.Code), data)
+	if err != nil {
+		s := String(data)
+		o = Option{OptionData: &s, Code: a.Code}
+	}
+	return o
+}
@@ -0,0 +1,70 @@
+package dhop
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMergeLayers(t *testing.T) {
+	ipv4s := func(a ...byte) *IPv4s {
+		v := IPv4s{}
+		for _, b := range a {
+			v = append(v, IPv4(net.IPv4(10, 0, 0, b).To4()))
+		}
+		return &v
+	}
+	domain := String("example.com")
+	host := String("pc1")
+	merged := MergeLayers([]Layer{
+		{
+			Name: ScopeGlobal,
+			Options: []Option{
+				{OptionData: ipv4s(53), Code: 6},
+				{OptionData: &domain, Code: 15},
+				{OptionData: ipv4s(123), Code: 42},
+			},
+		},
+		{
+			Name: ScopeSubnet,
+			Options: []Option{
+				{OptionData: ipv4s(1), Code: 3},
+				{OptionData: ipv4s(54), Code: 6},
+			},
+			Modes: map[Code]MergeMode{6: MergeAppend},
+		},
+		{
+			Name: ScopeHost,
+			Options: []Option{
+				{OptionData: &host, Code: 12},
+				{OptionData: ipv4s(254), Code: 3},
+			},
+			Modes: map[Code]MergeMode{42: MergeRemove},
+		},
+	})
+	expected := []struct {
+		code    Code
+		value   string
+		sources string
+	}{
+		{3, "10.0.0.254", "host"},
+		{6, "10.0.0.53,10.0.0.54", "global,subnet"},
+		{12, "pc1", "host"},
+		{15, "example.com", "global"},
+	}
+	if len(merged) != len(expected) {
+		t.Fatal(merged)
+	}
+	for i, e := range expected {
+		m := merged[i]
+		sources := ""
+		for j, s := range m.Sources {
+			if j > 0 {
+				sources += ","
+			}
+			sources += s
+		}
+		if m.Code != e.code || string(m.Marshal()) != e.value || sources != e.sources {
+			t.Errorf("%d: %s %s", m.Code, m.Marshal(), sources)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package dhop
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Inconsistency is a contradiction between options, or between options and yiaddr.
+type Inconsistency struct {
+	Codes   []Code `json:"codes"`
+	Message string `json:"message"`
+}
+
+// CheckOptions reports semantic inconsistencies of the options:
+// a non-contiguous Subnet Mask, Routers outside of the subnet of yiaddr, a Broadcast Address which does not match the mask,
+// Renewal and Rebinding Time Values not less than the next one, and Classless Static Routes with host bits in the destination.
+// The checks with the subnet are skipped if yiaddr is nil or unspecified.
+func CheckOptions(options []Option, yiaddr net.IP) []Inconsistency {
+	inconsistencies := make([]Inconsistency, 0)
+	add := func(codes []Code, format string, args ...interface{}) {
+		inconsistencies = append(inconsistencies, Inconsistency{
+			Codes:   codes,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	find := func(code Code) (Option, bool) {
+		return findOption(options, code)
+	}
+	yiaddr = yiaddr.To4()
+	if isUnspecified(yiaddr) {
+		yiaddr = nil
+	}
+
+	var mask net.IPMask
+	if o, ok := find(1); ok {
+		if v, ok := o.OptionData.(*IPv4); ok && net.IP(*v).To4() != nil {
+			mask = net.IPMask(net.IP(*v).To4())
+			if _, bits := mask.Size(); bits == 0 {
+				add([]Code{1}, "subnet mask %s is not contiguous", net.IP(mask))
+				mask = nil
+			}
+		}
+	}
+	if mask != nil && yiaddr != nil {
+		network := yiaddr.Mask(mask)
+		subnet := net.IPNet{IP: network, Mask: mask}
+		if ones, _ := mask.Size(); ones < 31 {
+			if yiaddr.Equal(network) {
+				add([]Code{1}, "yiaddr %s is the network address of %s", yiaddr, subnet.String())
+			} else if yiaddr.Equal(broadcastAddress(subnet)) {
+				add([]Code{1}, "yiaddr %s is the broadcast address of %s", yiaddr, subnet.String())
+			}
+		}
+		if o, ok := find(3); ok {
+			if v, ok := o.OptionData.(*IPv4s); ok {
+				for _, r := range *v {
+					if !subnet.Contains(net.IP(r)) {
+						add([]Code{1, 3}, "router %s is outside of %s", net.IP(r), subnet.String())
+					}
+				}
+			}
+		}
+	}
+	if o, ok := find(28); ok && mask != nil {
+		if v, ok := o.OptionData.(*IPv4); ok && net.IP(*v).To4() != nil {
+			b := net.IP(*v).To4()
+			expected := broadcastAddress(net.IPNet{IP: b.Mask(mask), Mask: mask})
+			if yiaddr != nil {
+				expected = broadcastAddress(net.IPNet{IP: yiaddr.Mask(mask), Mask: mask})
+			}
+			if !b.Equal(expected) {
+				add([]Code{1, 28}, "broadcast address %s does not match the mask, expected %s", b, expected)
+			}
+		}
+	}
+
+	times := []Code{58, 59, 51}
+	for i, a := range times {
+		ta, ok := findDuration(options, a)
+		if !ok {
+			continue
+		}
+		for _, b := range times[i+1:] {
+			tb, ok := findDuration(options, b)
+			if !ok {
+				continue
+			}
+			if ta >= tb {
+				add([]Code{a, b}, "option %d (%s) is not less than option %d (%s)", a, ta, b, tb)
+			}
+			break
+		}
+	}
+
+	for _, code := range []Code{121, 249} {
+		o, ok := find(code)
+		if !ok {
+			continue
+		}
+		v, ok := o.OptionData.(*Routes)
+		if !ok {
+			continue
+		}
+		for _, r := range *v {
+			if !r.Source.IP.Equal(r.Source.IP.Mask(r.Source.Mask)) {
+				ones, _ := r.Source.Mask.Size()
+				add([]Code{code}, "destination %s/%d has host bits set", r.Source.IP, ones)
+			}
+		}
+	}
+	return inconsistencies
+}
+
+func findDuration(options []Option, code Code) (time.Duration, bool) {
+	o, ok := findOption(options, code)
+	if !ok {
+		return 0, false
+	}
+	d, ok := o.OptionData.(*TimeDuration)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(*d), true
+}
+
+func broadcastAddress(n net.IPNet) net.IP {
+	ip := n.IP.To4()
+	b := make(net.IP, net.IPv4len)
+	for i := range b {
+		b[i] = ip[i] | ^n.Mask[i]
+	}
+	return b
+}
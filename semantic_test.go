@@ -0,0 +1,58 @@
+package dhop
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func semanticMessages(inconsistencies []Inconsistency) string {
+	s := make([]string, len(inconsistencies))
+	for i, c := range inconsistencies {
+		s[i] = c.Message
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestCheckOptions(t *testing.T) {
+	mask := IPv4(net.IPv4(255, 255, 255, 0).To4())
+	broadcast := IPv4(net.IPv4(10, 0, 0, 255).To4())
+	lease := TimeDuration(time.Hour)
+	t1 := TimeDuration(30 * time.Minute)
+	t2 := TimeDuration(45 * time.Minute)
+	options := []Option{
+		{OptionData: &mask, Code: 1},
+		{OptionData: &IPv4s{IPv4(net.IPv4(10, 0, 0, 1).To4())}, Code: 3},
+		{OptionData: &broadcast, Code: 28},
+		{OptionData: &lease, Code: 51},
+		{OptionData: &t1, Code: 58},
+		{OptionData: &t2, Code: 59},
+	}
+	if c := CheckOptions(options, net.IPv4(10, 0, 0, 100)); len(c) != 0 {
+		t.Error(semanticMessages(c))
+	}
+
+	routes := Routes{}
+	if err := routes.Unmarshal([]byte("10.1.0.0/16 10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	routes[0].Source.IP = net.IPv4(10, 1, 2, 0)
+	mask = IPv4(net.IPv4(255, 255, 0, 255).To4())
+	if c := CheckOptions(append(options, Option{OptionData: &routes, Code: 121}), nil); len(c) != 2 ||
+		c[0].Message != "subnet mask 255.255.0.255 is not contiguous" || c[1].Codes[0] != 121 {
+		t.Error(semanticMessages(c))
+	}
+
+	mask = IPv4(net.IPv4(255, 255, 255, 128).To4())
+	t1 = TimeDuration(2 * time.Hour)
+	expected := `router 10.0.0.1 is outside of 10.0.0.128/25
+option 58 (2h0m0s) is not less than option 59 (45m0s)`
+	if c := CheckOptions(options, net.IPv4(10, 0, 0, 200)); semanticMessages(c) != expected {
+		t.Error(semanticMessages(c))
+	}
+	if c := CheckOptions(options, net.IPv4(10, 0, 0, 1)); len(c) != 2 || c[0].Codes[1] != 28 ||
+		c[0].Message != "broadcast address 10.0.0.255 does not match the mask, expected 10.0.0.127" {
+		t.Error(semanticMessages(c))
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+// Allocator allocates the addresses of PoolHandler. Pool and lease.Manager implement it.
+// d is the lease time of the server, and the lease time granted to the client is returned.
+type Allocator interface {
+	// Allocate reserves an address for the client of DHCPDISCOVER.
+	Allocate(req *dhop.Message, d time.Duration) (net.IP, time.Duration, error)
+	// Commit leases the address to the client of DHCPREQUEST.
+	Commit(req *dhop.Message, ip net.IP, d time.Duration) (time.Duration, error)
+	// Free frees the address if it is held by the client of the message.
+	Free(req *dhop.Message, ip net.IP)
+	// Quarantine marks the address declined by the client of DHCPDECLINE as in use if it is held by the client.
+	Quarantine(req *dhop.Message, ip net.IP)
+}
+
+// PoolHandler is a Handler which leases the addresses of Pool (RFC 2131 section 4.3).
+// It replies to DHCPDISCOVER, DHCPREQUEST and DHCPINFORM, and updates Pool for DHCPRELEASE and DHCPDECLINE.
+// Replies are built by dhop.NewReply. Wrap it with another Handler to customise the replies.
+type PoolHandler struct {
+	ServerID  net.IP
+	Pool      Allocator
+	LeaseTime time.Duration
+	// Options are added to DHCPOFFER and DHCPACK, such as Subnet Mask, Router and Domain Name Server.
+	// Renewal and Rebinding Time Values are 1/2 and 7/8 of the lease time unless they are given.
+	Options []dhop.Option
+}
+
+func (h *PoolHandler) ServeDHCP(req *dhop.Message) *dhop.Message {
+	switch req.Type() {
+	case dhop.MessageTypeDiscover:
+		ip, d, err := h.Pool.Allocate(req, h.LeaseTime)
+		if err != nil {
+			return nil
+		}
+		return h.reply(req, dhop.MessageTypeOffer, ip, d)
+	case dhop.MessageTypeRequest:
+		return h.request(req)
+	case dhop.MessageTypeRelease:
+		if id := req.ServerIdentifier(); id == nil || id.Equal(h.ServerID) {
+			h.Pool.Free(req, req.CIAddr)
+		}
+	case dhop.MessageTypeDecline:
+		if id := req.ServerIdentifier(); id == nil || id.Equal(h.ServerID) {
+			h.Pool.Quarantine(req, req.RequestedIPAddress())
+		}
+	case dhop.MessageTypeInform:
+		return h.reply(req, dhop.MessageTypeAck, nil, 0)
+	}
+	return nil
+}
+
+func (h *PoolHandler) request(req *dhop.Message) *dhop.Message {
+	ip := req.RequestedIPAddress()
+	if id := req.ServerIdentifier(); id != nil {
+		// SELECTING: the client chose another server if the identifier differs.
+		if !id.Equal(h.ServerID) {
+			h.Pool.Free(req, ip)
+			return nil
+		}
+	} else if ip == nil {
+		// RENEWING or REBINDING
+		ip = req.CIAddr
+	}
+	if isUnspecified(ip) {
+		return nil
+	}
+	d, err := h.Pool.Commit(req, ip, h.LeaseTime)
+	if err != nil {
+		return h.reply(req, dhop.MessageTypeNak, nil, 0)
+	}
+	return h.reply(req, dhop.MessageTypeAck, ip, d)
+}
+
+// reply returns the reply of dhop.NewReply with Server Identifier and Options.
+// The address and the times of the lease of d are added if ip is not nil.
+func (h *PoolHandler) reply(req *dhop.Message, t dhop.MessageType, ip net.IP, d time.Duration) *dhop.Message {
+	id := dhop.IPv4(h.ServerID.To4())
+	options := []dhop.Option{{OptionData: &id, Code: 54}}
+	if ip != nil {
+		lt := dhop.TimeDuration(d)
+		t1 := dhop.TimeDuration(d / 2)
+		t2 := dhop.TimeDuration(d * 7 / 8)
+		options = append(options,
+			dhop.Option{OptionData: &lt, Code: 51},
+			dhop.Option{OptionData: &t1, Code: 58},
+			dhop.Option{OptionData: &t2, Code: 59},
+		)
+	}
+	for _, o := range h.Options {
+		switch o.Code {
+		case 51, 54:
+			continue
+		case 58, 59:
+			for i := range options {
+				if options[i].Code == o.Code {
+					options[i] = o
+				}
+			}
+			continue
+		}
+		options = append(options, o)
+	}
+	m, err := dhop.NewReply(req, t, options)
+	if err != nil {
+		return nil
+	}
+	if ip != nil {
+		m.YIAddr = ip
+	}
+	return m
+}
+
+func isUnspecified(ip net.IP) bool {
+	return ip == nil || ip.IsUnspecified()
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+var (
+	ErrPoolExhausted = errors.New("no free address in the pool")
+	ErrOutOfRange    = errors.New("address is out of the pool")
+	ErrInUse         = errors.New("address is leased to another client")
+)
+
+type LeaseState byte
+
+const (
+	LeaseOffered LeaseState = 1 + iota
+	LeaseBound
+	LeaseDeclined
+)
+
+func (s LeaseState) String() string {
+	switch s {
+	case LeaseOffered:
+		return "offered"
+	case LeaseBound:
+		return "bound"
+	case LeaseDeclined:
+		return "declined"
+	}
+	return "N/A"
+}
+
+// Lease is an address held by the pool.
+// ClientID is empty for addresses declined by clients.
+type Lease struct {
+	IP       net.IP
+	ClientID string
+	State    LeaseState
+	Expire   time.Time
+}
+
+// Pool is an in-memory pool of the addresses in a range.
+// It is safe for concurrent use.
+type Pool struct {
+	// OfferHold is how long an offered address is reserved for the client.
+	OfferHold time.Duration
+	// DeclineHold is how long a declined address is not offered.
+	DeclineHold time.Duration
+	// Now returns the current time. time.Now is used if nil.
+	Now func() time.Time
+
+	mu         sync.Mutex
+	start, end uint32
+	leases     map[uint32]*Lease
+}
+
+// NewPool returns the pool of the addresses from start to end inclusive.
+func NewPool(start, end net.IP) (*Pool, error) {
+	s, e := start.To4(), end.To4()
+	if s == nil || e == nil {
+		return nil, errors.New("pool range must be IPv4 addresses")
+	}
+	p := &Pool{
+		OfferHold:   30 * time.Second,
+		DeclineHold: 10 * time.Minute,
+		start:       binary.BigEndian.Uint32(s),
+		end:         binary.BigEndian.Uint32(e),
+		leases:      make(map[uint32]*Lease),
+	}
+	if p.start > p.end {
+		return nil, errors.New("pool range is reversed")
+	}
+	return p, nil
+}
+
+func (p *Pool) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func ipToUint32(ip net.IP) (uint32, bool) {
+	ip = ip.To4()
+	if ip == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(ip), true
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// Contains returns whether the address is in the range of the pool.
+func (p *Pool) Contains(ip net.IP) bool {
+	n, ok := ipToUint32(ip)
+	return ok && p.start <= n && n <= p.end
+}
+
+// lease returns the unexpired lease of the address.
+func (p *Pool) lease(n uint32, now time.Time) *Lease {
+	l, ok := p.leases[n]
+	if !ok {
+		return nil
+	}
+	if !now.Before(l.Expire) {
+		delete(p.leases, n)
+		return nil
+	}
+	return l
+}
+
+// leaseOf returns the unexpired lease of the client.
+func (p *Pool) leaseOf(client string, now time.Time) (uint32, *Lease) {
+	for n := range p.leases {
+		if l := p.lease(n, now); l != nil && l.ClientID == client {
+			return n, l
+		}
+	}
+	return 0, nil
+}
+
+// Offer reserves an address for the client and returns it.
+// The address which the client already holds is returned first, then the requested address if it is free,
+// and then the lowest free address.
+func (p *Pool) Offer(client string, requested net.IP) (net.IP, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if n, l := p.leaseOf(client, now); l != nil {
+		if l.State == LeaseOffered {
+			l.Expire = now.Add(p.OfferHold)
+		}
+		return uint32ToIP(n), nil
+	}
+	n, ok := ipToUint32(requested)
+	if !ok || n < p.start || n > p.end || p.lease(n, now) != nil {
+		ok = false
+		for i := p.start; ; i++ {
+			if p.lease(i, now) == nil {
+				n, ok = i, true
+				break
+			}
+			if i == p.end {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, ErrPoolExhausted
+	}
+	ip := uint32ToIP(n)
+	p.leases[n] = &Lease{
+		IP:       ip,
+		ClientID: client,
+		State:    LeaseOffered,
+		Expire:   now.Add(p.OfferHold),
+	}
+	return ip, nil
+}
+
+// Bind leases the address to the client for the duration.
+// Other addresses held by the client are freed.
+func (p *Pool) Bind(client string, ip net.IP, d time.Duration) error {
+	n, ok := ipToUint32(ip)
+	if !ok || n < p.start || n > p.end {
+		return ErrOutOfRange
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if l := p.lease(n, now); l != nil && l.ClientID != client {
+		return ErrInUse
+	}
+	if m, l := p.leaseOf(client, now); l != nil && m != n {
+		delete(p.leases, m)
+	}
+	p.leases[n] = &Lease{
+		IP:       uint32ToIP(n),
+		ClientID: client,
+		State:    LeaseBound,
+		Expire:   now.Add(d),
+	}
+	return nil
+}
+
+// Release frees the address if it is held by the client.
+func (p *Pool) Release(client string, ip net.IP) {
+	n, ok := ipToUint32(ip)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l := p.lease(n, p.now()); l != nil && l.ClientID == client {
+		delete(p.leases, n)
+	}
+}
+
+// Decline marks the address as in use by an unknown host for DeclineHold
+// if it is offered or leased to the client.
+func (p *Pool) Decline(client string, ip net.IP) {
+	n, ok := ipToUint32(ip)
+	if !ok || n < p.start || n > p.end {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if l := p.lease(n, now); l == nil || l.ClientID != client {
+		return
+	}
+	p.leases[n] = &Lease{
+		IP:     uint32ToIP(n),
+		State:  LeaseDeclined,
+		Expire: now.Add(p.DeclineHold),
+	}
+}
+
+// Allocate offers an address to the client of the message for Allocator.
+func (p *Pool) Allocate(req *dhop.Message, d time.Duration) (net.IP, time.Duration, error) {
+	ip, err := p.Offer(ClientID(req), req.RequestedIPAddress())
+	return ip, d, err
+}
+
+// Commit binds the address to the client of the message for Allocator.
+func (p *Pool) Commit(req *dhop.Message, ip net.IP, d time.Duration) (time.Duration, error) {
+	return d, p.Bind(ClientID(req), ip, d)
+}
+
+// Free releases the address of the client of the message for Allocator.
+func (p *Pool) Free(req *dhop.Message, ip net.IP) {
+	p.Release(ClientID(req), ip)
+}
+
+// Quarantine declines the address of the client of the message for Allocator.
+func (p *Pool) Quarantine(req *dhop.Message, ip net.IP) {
+	p.Decline(ClientID(req), ip)
+}
+
+// Leases returns the unexpired leases in the order of the addresses.
+func (p *Pool) Leases() []Lease {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	leases := make([]Lease, 0, len(p.leases))
+	for n := range p.leases {
+		if l := p.lease(n, now); l != nil {
+			leases = append(leases, *l)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		a, _ := ipToUint32(leases[i].IP)
+		b, _ := ipToUint32(leases[j].IP)
+		return a < b
+	})
+	return leases
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	now := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	p, err := NewPool(net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Now = func() time.Time { return now }
+	ip, err := p.Offer("a", nil)
+	if err != nil || !ip.Equal(net.IPv4(10, 0, 0, 10)) {
+		t.Error(ip, err)
+	}
+	if ip, err := p.Offer("a", net.IPv4(10, 0, 0, 11)); err != nil || !ip.Equal(net.IPv4(10, 0, 0, 10)) {
+		t.Error("offer again:", ip, err)
+	}
+	if ip, err := p.Offer("b", net.IPv4(10, 0, 0, 10)); err != nil || !ip.Equal(net.IPv4(10, 0, 0, 11)) {
+		t.Error("requested address in use:", ip, err)
+	}
+	if _, err := p.Offer("c", nil); err != ErrPoolExhausted {
+		t.Error("exhausted:", err)
+	}
+	if err := p.Bind("b", net.IPv4(10, 0, 0, 10), time.Hour); err != ErrInUse {
+		t.Error("bind in use:", err)
+	}
+	if err := p.Bind("a", net.IPv4(10, 0, 0, 1), time.Hour); err != ErrOutOfRange {
+		t.Error("bind out of range:", err)
+	}
+	if err := p.Bind("a", ip, time.Hour); err != nil {
+		t.Error(err)
+	}
+	p.Decline("a", net.IPv4(10, 0, 0, 11))
+	if leases := p.Leases(); len(leases) != 2 || leases[1].State != LeaseOffered {
+		t.Error("declined by another client:", leases)
+	}
+	p.Decline("b", net.IPv4(10, 0, 0, 11))
+	leases := p.Leases()
+	if len(leases) != 2 || leases[0].State != LeaseBound || leases[1].State != LeaseDeclined || leases[1].ClientID != "" {
+		t.Error(leases)
+	}
+
+	now = now.Add(p.OfferHold)
+	if ip, err := p.Offer("c", nil); err != ErrPoolExhausted {
+		t.Error("declined address is offered:", ip, err)
+	}
+	now = now.Add(p.DeclineHold)
+	if ip, err := p.Offer("c", nil); err != nil || !ip.Equal(net.IPv4(10, 0, 0, 11)) {
+		t.Error("declined address is not freed:", ip, err)
+	}
+	now = now.Add(time.Hour)
+	if leases := p.Leases(); len(leases) != 0 {
+		t.Error("leases are not expired:", leases)
+	}
+}
@@ -0,0 +1,88 @@
+// Package server is a small DHCPv4 server which serves on any net.PacketConn,
+// so tests can run it on loopback UDP sockets without privileges.
+package server
+
+import (
+	"encoding/hex"
+	"log"
+	"net"
+
+	"github.com/bgpat/dhop"
+)
+
+// Handler replies to DHCP requests.
+// ServeDHCP returns the reply to the request, or nil to send nothing.
+type Handler interface {
+	ServeDHCP(req *dhop.Message) *dhop.Message
+}
+
+// HandlerFunc is an adapter to use a function as Handler.
+type HandlerFunc func(req *dhop.Message) *dhop.Message
+
+func (f HandlerFunc) ServeDHCP(req *dhop.Message) *dhop.Message {
+	return f(req)
+}
+
+// Server reads requests from a PacketConn and writes the replies of Handler.
+// Unlike a server on a link, replies are sent back to the address which the request comes from
+// instead of the broadcast address or the client port, so clients and relays on loopback receive them.
+type Server struct {
+	Handler Handler
+	// ErrorLog logs requests which cannot be decoded and failures of writing replies.
+	// Nothing is logged if nil.
+	ErrorLog *log.Logger
+}
+
+// Serve handles requests from the conn in the order of arrival until reading fails,
+// and returns the error. Close the conn to stop the server.
+// Replies are encoded within the Maximum DHCP Message Size of the requests.
+func (s *Server) Serve(conn net.PacketConn) error {
+	b := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			return err
+		}
+		req := &dhop.Message{}
+		if err := req.Decode(b[:n]); err != nil {
+			s.logf("dhop: invalid message from %s: %s", addr, err)
+			continue
+		}
+		if req.Op != dhop.OpRequest {
+			continue
+		}
+		reply := s.Handler.ServeDHCP(req)
+		if reply == nil {
+			continue
+		}
+		p, _, err := reply.EncodeWithBudget(req.MaxMessageSize(), req.ParameterRequestList())
+		if err != nil {
+			s.logf("dhop: failed to encode the reply to %s: %s", addr, err)
+			continue
+		}
+		if _, err := conn.WriteTo(p, addr); err != nil {
+			s.logf("dhop: failed to reply to %s: %s", addr, err)
+		}
+	}
+}
+
+func (s *Server) logf(format string, v ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, v...)
+	}
+}
+
+// Serve serves the conn with the handler.
+func Serve(conn net.PacketConn, handler Handler) error {
+	s := &Server{Handler: handler}
+	return s.Serve(conn)
+}
+
+// ClientID returns the key which identifies the client of the message,
+// the Client Identifier option in hex, or the hardware type and address if the option is absent.
+func ClientID(m *dhop.Message) string {
+	if o, ok := m.Option(61); ok {
+		return hex.EncodeToString(o.Encode())
+	}
+	return hex.EncodeToString(append([]byte{m.HType}, m.CHAddr...))
+}
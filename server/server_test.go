@@ -0,0 +1,153 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bgpat/dhop"
+)
+
+var (
+	testServerID = net.IPv4(127, 0, 0, 1)
+	testCHAddr   = net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}
+)
+
+func newTestServer(t *testing.T) (net.PacketConn, *Pool) {
+	p, err := NewPool(net.IPv4(10, 0, 0, 100), net.IPv4(10, 0, 0, 199))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask := dhop.IPv4(net.IPv4(255, 255, 255, 0).To4())
+	h := &PoolHandler{
+		ServerID:  testServerID,
+		Pool:      p,
+		LeaseTime: time.Hour,
+		Options:   []dhop.Option{{OptionData: &mask, Code: 1}},
+	}
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go Serve(conn, h)
+	return conn, p
+}
+
+func newRequest(t dhop.MessageType, options ...dhop.Option) *dhop.Message {
+	typ := dhop.Byte(t)
+	return &dhop.Message{
+		Op:      dhop.OpRequest,
+		HType:   1,
+		HLen:    6,
+		XID:     0x12345678,
+		CIAddr:  net.IPv4zero,
+		YIAddr:  net.IPv4zero,
+		SIAddr:  net.IPv4zero,
+		GIAddr:  net.IPv4zero,
+		CHAddr:  testCHAddr,
+		Options: append([]dhop.Option{{OptionData: &typ, Code: 53}}, options...),
+	}
+}
+
+func exchange(t *testing.T, server net.Addr, m *dhop.Message) *dhop.Message {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.WriteTo(m.Encode(), server); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	b := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		return nil
+	}
+	reply := &dhop.Message{}
+	if err := reply.Decode(b[:n]); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func ipv4Option(code dhop.Code, ip net.IP) dhop.Option {
+	v := dhop.IPv4(ip.To4())
+	return dhop.Option{OptionData: &v, Code: code}
+}
+
+func TestServe(t *testing.T) {
+	conn, p := newTestServer(t)
+	defer conn.Close()
+
+	offer := exchange(t, conn.LocalAddr(), newRequest(dhop.MessageTypeDiscover))
+	if offer == nil {
+		t.Fatal("no DHCPOFFER")
+	}
+	if offer.Type() != dhop.MessageTypeOffer || offer.XID != 0x12345678 || !offer.YIAddr.Equal(net.IPv4(10, 0, 0, 100)) {
+		t.Error(offer.Type(), offer.XID, offer.YIAddr)
+	}
+	if !offer.ServerIdentifier().Equal(testServerID) {
+		t.Error(offer.ServerIdentifier())
+	}
+	if o, ok := offer.Option(51); !ok || time.Duration(*o.OptionData.(*dhop.TimeDuration)) != time.Hour {
+		t.Error("lease time:", o)
+	}
+	if _, ok := offer.Option(1); !ok {
+		t.Error("no subnet mask")
+	}
+
+	req := newRequest(dhop.MessageTypeRequest, ipv4Option(50, offer.YIAddr), ipv4Option(54, testServerID))
+	ack := exchange(t, conn.LocalAddr(), req)
+	if ack == nil || ack.Type() != dhop.MessageTypeAck || !ack.YIAddr.Equal(offer.YIAddr) {
+		t.Fatal("DHCPACK:", ack)
+	}
+	if leases := p.Leases(); len(leases) != 1 || leases[0].State != LeaseBound {
+		t.Error(leases)
+	}
+
+	renew := newRequest(dhop.MessageTypeRequest)
+	renew.CIAddr = ack.YIAddr
+	if ack := exchange(t, conn.LocalAddr(), renew); ack == nil || ack.Type() != dhop.MessageTypeAck {
+		t.Error("renewing:", ack)
+	}
+
+	other := newRequest(dhop.MessageTypeRequest, ipv4Option(50, net.IPv4(192, 168, 0, 1)))
+	if nak := exchange(t, conn.LocalAddr(), other); nak == nil || nak.Type() != dhop.MessageTypeNak {
+		t.Error("init-reboot on another network:", nak)
+	}
+
+	inform := newRequest(dhop.MessageTypeInform)
+	inform.CIAddr = net.IPv4(10, 0, 0, 5)
+	ack = exchange(t, conn.LocalAddr(), inform)
+	if ack == nil || ack.Type() != dhop.MessageTypeAck || !ack.YIAddr.IsUnspecified() {
+		t.Fatal("DHCPINFORM:", ack)
+	}
+	if _, ok := ack.Option(51); ok {
+		t.Error("lease time in reply to DHCPINFORM")
+	}
+
+	release := newRequest(dhop.MessageTypeRelease, ipv4Option(54, testServerID))
+	release.CIAddr = offer.YIAddr
+	if reply := exchange(t, conn.LocalAddr(), release); reply != nil {
+		t.Error("reply to DHCPRELEASE:", reply)
+	}
+	if leases := p.Leases(); len(leases) != 0 {
+		t.Error("not released:", leases)
+	}
+
+	decline := newRequest(dhop.MessageTypeDecline, ipv4Option(50, offer.YIAddr), ipv4Option(54, testServerID))
+	exchange(t, conn.LocalAddr(), decline)
+	if leases := p.Leases(); len(leases) != 0 {
+		t.Error("released address is declined:", leases)
+	}
+	offer = exchange(t, conn.LocalAddr(), newRequest(dhop.MessageTypeDiscover))
+	if offer == nil {
+		t.Fatal("no DHCPOFFER")
+	}
+	decline = newRequest(dhop.MessageTypeDecline, ipv4Option(50, offer.YIAddr))
+	exchange(t, conn.LocalAddr(), decline)
+	if leases := p.Leases(); len(leases) != 1 || leases[0].State != LeaseDeclined {
+		t.Error("not declined:", leases)
+	}
+}
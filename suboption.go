@@ -0,0 +1,36 @@
+package dhop
+
+// SubOption is a sub-option encoded in the data of an option,
+// such as Relay Agent Information (RFC 3046) or Vendor Specific Information.
+type SubOption struct {
+	Code byte
+	Data []byte
+}
+
+func DecodeSubOptions(b []byte) ([]SubOption, error) {
+	a := make([]SubOption, 0, 2)
+	for len(b) > 0 {
+		if err := validateMinimumSize(b, 2); err != nil {
+			return nil, err
+		}
+		l := int(b[1])
+		if err := validateMinimumSize(b[2:], l); err != nil {
+			return nil, err
+		}
+		a = append(a, SubOption{
+			Code: b[0],
+			Data: b[2 : 2+l],
+		})
+		b = b[2+l:]
+	}
+	return a, nil
+}
+
+func EncodeSubOptions(a []SubOption) []byte {
+	b := make([]byte, 0, 16)
+	for _, o := range a {
+		b = append(b, o.Code, byte(len(o.Data)))
+		b = append(b, o.Data...)
+	}
+	return b
+}
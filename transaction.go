@@ -0,0 +1,258 @@
+package dhop
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// CapturedMessage is a message observed on the network.
+type CapturedMessage struct {
+	Timestamp time.Time
+	Src       net.IP
+	Dst       net.IP
+	Message   *Message
+}
+
+// ServerIdentifier returns the DHCP Server Identifier option, or nil.
+func (m *Message) ServerIdentifier() net.IP {
+	return m.ipv4Option(54)
+}
+
+// RequestedIPAddress returns the Requested IP Address option, or nil.
+func (m *Message) RequestedIPAddress() net.IP {
+	return m.ipv4Option(50)
+}
+
+func (m *Message) ipv4Option(code Code) net.IP {
+	o, ok := m.Option(code)
+	if !ok {
+		return nil
+	}
+	ip, ok := o.OptionData.(*IPv4)
+	if !ok {
+		return nil
+	}
+	return net.IP(*ip)
+}
+
+type TransactionState byte
+
+const (
+	TransactionIncomplete TransactionState = iota
+	TransactionBound
+	TransactionNak
+	TransactionDeclined
+	TransactionReleased
+	TransactionInformed
+)
+
+func (s TransactionState) String() string {
+	switch s {
+	case TransactionIncomplete:
+		return "incomplete"
+	case TransactionBound:
+		return "bound"
+	case TransactionNak:
+		return "nak"
+	case TransactionDeclined:
+		return "declined"
+	case TransactionReleased:
+		return "released"
+	case TransactionInformed:
+		return "informed"
+	}
+	return fmt.Sprintf("N/A (%d)", byte(s))
+}
+
+// Offer is an OFFER received in a transaction.
+type Offer struct {
+	Timestamp time.Time
+	Server    net.IP
+	YIAddr    net.IP
+}
+
+// Transaction is an exchange of messages which share the xid and the client hardware address.
+type Transaction struct {
+	XID      uint32
+	CHAddr   net.HardwareAddr
+	Messages []*CapturedMessage
+	State    TransactionState
+	// Failure describes why the transaction did not end in the bound state.
+	Failure string
+	Start   time.Time
+	End     time.Time
+	// OfferLatency is the time from the first DISCOVER to the first OFFER.
+	OfferLatency time.Duration
+	// AckLatency is the time from the first REQUEST to the ACK or the NAK.
+	AckLatency time.Duration
+	// Latency is the time from the first message to the ACK or the NAK.
+	Latency         time.Duration
+	Retransmissions int
+	Offers          []Offer
+	Server          net.IP
+	YIAddr          net.IP
+}
+
+// Servers returns the distinct servers which sent OFFERs.
+func (t *Transaction) Servers() []net.IP {
+	servers := make([]net.IP, 0, len(t.Offers))
+	for _, o := range t.Offers {
+		found := false
+		for _, s := range servers {
+			if s.Equal(o.Server) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			servers = append(servers, o.Server)
+		}
+	}
+	return servers
+}
+
+type transactionKey struct {
+	xid    uint32
+	chaddr string
+}
+
+// Analyzer groups captured messages into transactions.
+type Analyzer struct {
+	transactions map[transactionKey]*Transaction
+	order        []*Transaction
+}
+
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		transactions: make(map[transactionKey]*Transaction),
+	}
+}
+
+func (a *Analyzer) Add(m *CapturedMessage) {
+	key := transactionKey{
+		xid:    m.Message.XID,
+		chaddr: m.Message.CHAddr.String(),
+	}
+	t, ok := a.transactions[key]
+	if !ok {
+		t = &Transaction{
+			XID:    m.Message.XID,
+			CHAddr: m.Message.CHAddr,
+		}
+		a.transactions[key] = t
+		a.order = append(a.order, t)
+	}
+	t.Messages = append(t.Messages, m)
+}
+
+// Transactions analyzes the transactions ordered by the first message.
+func (a *Analyzer) Transactions() []*Transaction {
+	transactions := make([]*Transaction, len(a.order))
+	copy(transactions, a.order)
+	for _, t := range transactions {
+		t.analyze()
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Start.Before(transactions[j].Start)
+	})
+	return transactions
+}
+
+func (t *Transaction) analyze() {
+	sort.SliceStable(t.Messages, func(i, j int) bool {
+		return t.Messages[i].Timestamp.Before(t.Messages[j].Timestamp)
+	})
+	*t = Transaction{
+		XID:      t.XID,
+		CHAddr:   t.CHAddr,
+		Messages: t.Messages,
+	}
+	if len(t.Messages) == 0 {
+		return
+	}
+	t.Start = t.Messages[0].Timestamp
+	t.End = t.Messages[len(t.Messages)-1].Timestamp
+	var firstDiscover, firstRequest time.Time
+	counts := make(map[MessageType]int)
+	for _, c := range t.Messages {
+		m := c.Message
+		typ := m.Type()
+		if m.Op == OpRequest {
+			counts[typ]++
+			if counts[typ] > 1 {
+				t.Retransmissions++
+			}
+		}
+		switch typ {
+		case MessageTypeDiscover:
+			if firstDiscover.IsZero() {
+				firstDiscover = c.Timestamp
+			}
+		case MessageTypeOffer:
+			if len(t.Offers) == 0 && !firstDiscover.IsZero() {
+				t.OfferLatency = c.Timestamp.Sub(firstDiscover)
+			}
+			t.Offers = append(t.Offers, Offer{
+				Timestamp: c.Timestamp,
+				Server:    serverOf(c),
+				YIAddr:    m.YIAddr,
+			})
+		case MessageTypeRequest:
+			if firstRequest.IsZero() {
+				firstRequest = c.Timestamp
+			}
+		case MessageTypeAck, MessageTypeNak:
+			if !firstRequest.IsZero() {
+				t.AckLatency = c.Timestamp.Sub(firstRequest)
+			}
+			t.Latency = c.Timestamp.Sub(t.Start)
+			t.Server = serverOf(c)
+			if typ == MessageTypeNak {
+				t.State = TransactionNak
+				t.Failure = "server sent DHCPNAK"
+				if o, ok := m.Option(56); ok {
+					t.Failure += ": " + string(o.Marshal())
+				}
+			} else if counts[MessageTypeInform] > 0 {
+				t.State = TransactionInformed
+				t.Failure = ""
+			} else {
+				t.State = TransactionBound
+				t.Failure = ""
+				t.YIAddr = m.YIAddr
+			}
+		case MessageTypeDecline:
+			t.State = TransactionDeclined
+			t.Failure = "client declined the address"
+			if ip := m.RequestedIPAddress(); ip != nil {
+				t.Failure += " " + ip.String()
+			}
+		case MessageTypeRelease:
+			t.State = TransactionReleased
+		}
+	}
+	if t.State != TransactionIncomplete {
+		return
+	}
+	switch {
+	case counts[MessageTypeDiscover] > 0 && len(t.Offers) == 0:
+		t.Failure = "no DHCPOFFER received"
+	case counts[MessageTypeDiscover] > 0 && counts[MessageTypeRequest] == 0:
+		t.Failure = "no DHCPREQUEST sent after DHCPOFFER"
+	case counts[MessageTypeRequest] > 0:
+		t.Failure = "no DHCPACK received"
+	case counts[MessageTypeInform] > 0:
+		t.Failure = "no DHCPACK received for DHCPINFORM"
+	default:
+		t.Failure = "no request from the client"
+	}
+}
+
+func serverOf(c *CapturedMessage) net.IP {
+	if ip := c.Message.ServerIdentifier(); ip != nil {
+		return ip
+	}
+	return c.Src
+}
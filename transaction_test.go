@@ -0,0 +1,90 @@
+package dhop
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+var transactionStart = time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+
+func capturedMessage(d time.Duration, op byte, typ MessageType, server net.IP, options ...Option) *CapturedMessage {
+	m := &Message{
+		Op:     op,
+		XID:    0x12345678,
+		CHAddr: net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		YIAddr: net.IPv4zero,
+		Options: append([]Option{
+			{OptionData: newByte(byte(typ)), Code: 53},
+		}, options...),
+	}
+	if server != nil {
+		id := IPv4(server.To4())
+		m.Options = append(m.Options, Option{OptionData: &id, Code: 54})
+	}
+	if op == OpReply {
+		m.YIAddr = net.IPv4(10, 0, 0, 100)
+	}
+	return &CapturedMessage{
+		Timestamp: transactionStart.Add(d),
+		Src:       net.IPv4zero,
+		Dst:       net.IPv4bcast,
+		Message:   m,
+	}
+}
+
+func TestAnalyzerBound(t *testing.T) {
+	server1 := net.IPv4(10, 0, 0, 1)
+	server2 := net.IPv4(10, 0, 0, 2)
+	a := NewAnalyzer()
+	for _, m := range []*CapturedMessage{
+		capturedMessage(0, OpRequest, MessageTypeDiscover, nil),
+		capturedMessage(4*time.Second, OpRequest, MessageTypeDiscover, nil),
+		capturedMessage(5*time.Second, OpReply, MessageTypeOffer, server1),
+		capturedMessage(5500*time.Millisecond, OpReply, MessageTypeOffer, server2),
+		capturedMessage(6*time.Second, OpRequest, MessageTypeRequest, server1),
+		capturedMessage(8*time.Second, OpReply, MessageTypeAck, server1),
+	} {
+		a.Add(m)
+	}
+	transactions := a.Transactions()
+	if len(transactions) != 1 {
+		t.Fatal(transactions)
+	}
+	tr := transactions[0]
+	if tr.State != TransactionBound || tr.Failure != "" {
+		t.Error(tr.State, tr.Failure)
+	}
+	if tr.Latency != 8*time.Second || tr.OfferLatency != 5*time.Second || tr.AckLatency != 2*time.Second {
+		t.Error(tr.Latency, tr.OfferLatency, tr.AckLatency)
+	}
+	if tr.Retransmissions != 1 || len(tr.Servers()) != 2 || !tr.Server.Equal(server1) {
+		t.Error(tr.Retransmissions, tr.Servers(), tr.Server)
+	}
+	if !tr.YIAddr.Equal(net.IPv4(10, 0, 0, 100)) {
+		t.Error(tr.YIAddr)
+	}
+}
+
+func TestAnalyzerNak(t *testing.T) {
+	message := String("wrong network")
+	a := NewAnalyzer()
+	a.Add(capturedMessage(time.Second, OpReply, MessageTypeNak, net.IPv4(10, 0, 0, 1), Option{OptionData: &message, Code: 56}))
+	a.Add(capturedMessage(0, OpRequest, MessageTypeRequest, nil))
+	tr := a.Transactions()[0]
+	if tr.State != TransactionNak || tr.Failure != "server sent DHCPNAK: wrong network" {
+		t.Error(tr.State, tr.Failure)
+	}
+	if tr.Messages[0].Message.Type() != MessageTypeRequest {
+		t.Error("messages must be sorted by timestamp")
+	}
+}
+
+func TestAnalyzerNoOffer(t *testing.T) {
+	a := NewAnalyzer()
+	a.Add(capturedMessage(0, OpRequest, MessageTypeDiscover, nil))
+	tr := a.Transactions()[0]
+	if tr.State != TransactionIncomplete || tr.Failure != "no DHCPOFFER received" {
+		t.Error(tr.State, tr.Failure)
+	}
+}